@@ -0,0 +1,227 @@
+// Package auth checks clients of HTTP and WebSocket API. It's opt-in, with auth.mode none everyone is let in.
+//
+// Clients send a token in "Authorization: Bearer" header, pulse_remote_token cookie or ?token= query param,
+// browsers can't set headers on WebSocket. Valid tokens are the shared auth.token and per-device tokens,
+// issued by login with shared secret or by pairing confirmed on the desktop.
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/undg/pulse-remote/api/config"
+	"github.com/undg/pulse-remote/api/logger"
+)
+
+// CookieName keeps device token in browser, set by login and pairing
+const CookieName = "pulse_remote_token"
+
+type Auth struct {
+	mode          string
+	token         string
+	password      string
+	allowLoopback bool
+	defaultRole   Role
+	tokens        []roleToken
+	networks      []roleNetwork
+
+	devices *devices
+	pairing *pairing
+	limiter *limiter
+
+	// Notify is called for every new pairing request, fe. to show desktop notification. Optional.
+	Notify func(req PairRequest)
+	// WebURL is web app address for other devices, fe. http://192.168.1.10:8448. It's encoded in QR codes.
+	WebURL string
+}
+
+// New loads paired devices from dataDir. Empty dataDir keeps them in memory only.
+func New(cfg config.Auth, dataDir string) (*Auth, error) {
+	path := ""
+	if dataDir != "" {
+		path = filepath.Join(dataDir, "devices.json")
+	}
+
+	// Empty when config.Auth isn't made by config.Load, fe. in tests
+	defaultRole := RoleAdmin
+	if cfg.DefaultRole != "" {
+		defaultRole = mustParseRole(cfg.DefaultRole)
+	}
+
+	a := &Auth{
+		mode:          cfg.Mode,
+		token:         cfg.Token,
+		password:      cfg.Password,
+		allowLoopback: cfg.AllowLoopback,
+		defaultRole:   defaultRole,
+		pairing:       newPairing(),
+		limiter:       newLimiter(),
+	}
+
+	for _, t := range cfg.Tokens {
+		a.tokens = append(a.tokens, roleToken{name: t.Name, token: t.Token, role: mustParseRole(t.Role)})
+	}
+	for _, n := range cfg.Networks {
+		network, err := config.ParseNetwork(n.Network)
+		if err != nil {
+			return nil, err
+		}
+		a.networks = append(a.networks, roleNetwork{network: network, role: mustParseRole(n.Role)})
+	}
+
+	devices, err := loadDevices(path, a.defaultRole)
+	if err != nil {
+		return nil, err
+	}
+	a.devices = devices
+
+	return a, nil
+}
+
+type roleToken struct {
+	name  string
+	token string
+	role  Role
+}
+
+type roleNetwork struct {
+	network *net.IPNet
+	role    Role
+}
+
+func (a *Auth) Enabled() bool {
+	return a.mode != config.AuthNone
+}
+
+// PairURL opens web app on another device, fe. scanned from QR code.
+// With auth enabled it carries one-time token, that logs device in.
+func (a *Auth) PairURL() string {
+	if !a.Enabled() {
+		return a.WebURL + "/"
+	}
+	return a.WebURL + "/login?pair=" + a.pairing.addOneTime()
+}
+
+// Role of client, false when it's not authenticated. Token wins over network the client is in.
+func (a *Auth) Role(r *http.Request) (Role, bool) {
+	if a.allowLoopback && isLoopback(r) {
+		return RoleAdmin, true
+	}
+
+	if token := credential(r); token != "" {
+		if a.mode == config.AuthToken && equal(token, a.token) {
+			return a.defaultRole, true
+		}
+		for _, t := range a.tokens {
+			if equal(token, t.token) {
+				return t.role, true
+			}
+		}
+		if device, ok := a.devices.find(token); ok {
+			return device.Role, true
+		}
+	}
+
+	if ip := net.ParseIP(clientIP(r)); ip != nil {
+		for _, n := range a.networks {
+			if n.network.Contains(ip) {
+				return n.role, true
+			}
+		}
+	}
+
+	if !a.Enabled() {
+		return a.defaultRole, true
+	}
+	return RoleNone, false
+}
+
+// Authenticate tells if client can use the API, at least as viewer
+func (a *Auth) Authenticate(r *http.Request) bool {
+	_, ok := a.Role(r)
+	return ok
+}
+
+// Require responds with 401 to clients without valid token
+func (a *Auth) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Authenticate(r) {
+			logger.Warn().Str("client_ip", r.RemoteAddr).Str("path", r.URL.Path).Msg("Unauthorized")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pulse-remote"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireDesktop lets in only clients on the same machine, fe. to confirm pairing.
+// Without allow_loopback, fe. behind reverse proxy, they need a token as well.
+//
+// Host and Origin are checked too, otherwise any website opened on the desktop
+// could approve pairing requested by someone else.
+func (a *Auth) requireDesktop(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin, err := url.Parse(r.Header.Get("Origin"))
+		crossOrigin := err != nil || (origin.Host != "" && origin.Host != r.Host)
+
+		if !isLoopback(r) || !isLoopbackHost(r.Host) || crossOrigin || !a.Authenticate(r) {
+			logger.Warn().Str("client_ip", r.RemoteAddr).Str("path", r.URL.Path).Msg("Desktop only")
+			http.Error(w, "Forbidden, available only on the desktop", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// credential is token sent by client, empty when there is none
+func credential(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if cookie, err := r.Cookie(CookieName); err == nil {
+		return cookie.Value
+	}
+	return r.URL.Query().Get("token")
+}
+
+func equal(a string, b string) bool {
+	return b != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isLoopback(r *http.Request) bool {
+	ip := net.ParseIP(clientIP(r))
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackHost is false for DNS rebinding, fe. evil.example resolving to 127.0.0.1
+func isLoopbackHost(hostPort string) bool {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error().Err(err).Msg("json.NewEncoder(w).Encode(v)")
+	}
+}
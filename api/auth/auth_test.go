@@ -0,0 +1,389 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/undg/pulse-remote/api/config"
+	prJSON "github.com/undg/pulse-remote/api/json"
+)
+
+const lanAddr = "192.168.1.20:50000"
+
+func newTestAuth(t *testing.T, cfg config.Auth) (*Auth, *http.ServeMux) {
+	t.Helper()
+
+	a, err := New(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("[Err] New: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	a.Register(mux)
+	mux.Handle("/api/v1/status", a.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	return a, mux
+}
+
+// do sends request from remoteAddr, token is sent as bearer when not empty
+func do(mux *http.ServeMux, method string, target string, body string, remoteAddr string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	r.Host = "localhost:8448"
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Cfg    config.Auth
+		Remote string
+		Token  string
+		Want   int
+	}{
+		{"Disabled", config.Auth{Mode: config.AuthNone}, lanAddr, "", http.StatusOK},
+		{"NoToken", config.Auth{Mode: config.AuthToken, Token: "secret"}, lanAddr, "", http.StatusUnauthorized},
+		{"WrongToken", config.Auth{Mode: config.AuthToken, Token: "secret"}, lanAddr, "nope", http.StatusUnauthorized},
+		{"SharedToken", config.Auth{Mode: config.AuthToken, Token: "secret"}, lanAddr, "secret", http.StatusOK},
+		{"PasswordIsNotToken", config.Auth{Mode: config.AuthPassword, Password: "secret"}, lanAddr, "secret", http.StatusUnauthorized},
+		{"Loopback", config.Auth{Mode: config.AuthToken, Token: "secret", AllowLoopback: true}, "127.0.0.1:50000", "", http.StatusOK},
+		{"LoopbackNotAllowed", config.Auth{Mode: config.AuthToken, Token: "secret"}, "[::1]:50000", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, mux := newTestAuth(t, tt.Cfg)
+
+			w := do(mux, "GET", "/api/v1/status", "", tt.Remote, tt.Token)
+			if w.Code != tt.Want {
+				t.Errorf("[Err] Expected %d, got %d", tt.Want, w.Code)
+			}
+		})
+	}
+}
+
+func TestCredential(t *testing.T) {
+	_, mux := newTestAuth(t, config.Auth{Mode: config.AuthToken, Token: "secret"})
+
+	r := httptest.NewRequest("GET", "/api/v1/status?token=secret", nil)
+	r.RemoteAddr = lanAddr
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("[Err] Expected query token to work, got %d", w.Code)
+	}
+
+	r = httptest.NewRequest("GET", "/api/v1/status", nil)
+	r.RemoteAddr = lanAddr
+	r.AddCookie(&http.Cookie{Name: CookieName, Value: "secret"})
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("[Err] Expected cookie token to work, got %d", w.Code)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	dir := t.TempDir()
+	a, err := New(config.Auth{Mode: config.AuthPassword, Password: "hunter2"}, dir)
+	if err != nil {
+		t.Fatalf("[Err] New: %v", err)
+	}
+	mux := http.NewServeMux()
+	a.Register(mux)
+
+	w := do(mux, "POST", "/api/v1/auth/login", `{"password":"hunter2","name":"Phone"}`, lanAddr, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("[Err] Expected login OK, got %d %s", w.Code, w.Body)
+	}
+
+	var issued Issued
+	json.Unmarshal(w.Body.Bytes(), &issued)
+	if issued.Token == "" || issued.Device.Name != "Phone" || issued.Device.Via != viaLogin {
+		t.Errorf("[Err] Unexpected issued token %+v", issued)
+	}
+	if cookie := w.Result().Cookies(); len(cookie) != 1 || cookie[0].Value != issued.Token || !cookie[0].HttpOnly {
+		t.Errorf("[Err] Expected HttpOnly cookie with token, got %v", cookie)
+	}
+
+	// Device tokens survive restart
+	restarted, err := New(config.Auth{Mode: config.AuthPassword, Password: "hunter2"}, dir)
+	if err != nil {
+		t.Fatalf("[Err] New: %v", err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = lanAddr
+	r.Header.Set("Authorization", "Bearer "+issued.Token)
+	if !restarted.Authenticate(r) {
+		t.Errorf("[Err] Expected token to be valid after restart")
+	}
+	if info, err := os.Stat(filepath.Join(dir, "devices.json")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("[Err] Expected devices.json readable only by owner: %v %v", info, err)
+	}
+
+	w = do(mux, "POST", "/api/v1/auth/logout", "", lanAddr, issued.Token)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("[Err] Expected logout 204, got %d", w.Code)
+	}
+	if a.Authenticate(r) {
+		t.Errorf("[Err] Expected token to be revoked by logout")
+	}
+}
+
+func TestLoginLimit(t *testing.T) {
+	_, mux := newTestAuth(t, config.Auth{Mode: config.AuthToken, Token: "secret"})
+
+	for range maxFailures {
+		if w := do(mux, "POST", "/api/v1/auth/login", `{"token":"nope"}`, lanAddr, ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("[Err] Expected 401, got %d", w.Code)
+		}
+	}
+
+	w := do(mux, "POST", "/api/v1/auth/login", `{"token":"secret"}`, lanAddr, "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("[Err] Expected 429 with Retry-After, got %d", w.Code)
+	}
+
+	w = do(mux, "POST", "/api/v1/auth/login", `{"token":"secret"}`, "192.168.1.21:50000", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("[Err] Expected other client to log in, got %d", w.Code)
+	}
+}
+
+func TestPairing(t *testing.T) {
+	_, mux := newTestAuth(t, config.Auth{Mode: config.AuthPassword, Password: "hunter2", AllowLoopback: true})
+	const desktop = "127.0.0.1:50000"
+
+	request := func(t *testing.T) PairRequest {
+		t.Helper()
+
+		w := do(mux, "POST", "/api/v1/auth/pair", `{"name":"Tablet"}`, lanAddr, "")
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("[Err] Expected 202, got %d %s", w.Code, w.Body)
+		}
+
+		var req PairRequest
+		json.Unmarshal(w.Body.Bytes(), &req)
+		if req.ID == "" || len(req.Code) != 6 || req.Status != PairPending {
+			t.Fatalf("[Err] Unexpected pairing request %+v", req)
+		}
+		return req
+	}
+
+	t.Run("Approve", func(t *testing.T) {
+		req := request(t)
+
+		if w := do(mux, "GET", "/api/v1/auth/pair/"+req.ID, "", lanAddr, ""); w.Code != http.StatusAccepted {
+			t.Errorf("[Err] Expected pending 202, got %d", w.Code)
+		}
+
+		w := do(mux, "GET", "/api/v1/auth/pair", "", desktop, "")
+		if !strings.Contains(w.Body.String(), req.Code) {
+			t.Errorf("[Err] Expected request on the desktop, got %d %s", w.Code, w.Body)
+		}
+
+		if w := do(mux, "POST", "/api/v1/auth/pair/"+req.ID+"/approve", "", lanAddr, ""); w.Code != http.StatusForbidden {
+			t.Errorf("[Err] Expected approve from LAN to be forbidden, got %d", w.Code)
+		}
+		if w := do(mux, "POST", "/api/v1/auth/pair/"+req.ID+"/approve", "", desktop, ""); w.Code != http.StatusNoContent {
+			t.Fatalf("[Err] Expected approve 204, got %d %s", w.Code, w.Body)
+		}
+
+		w = do(mux, "GET", "/api/v1/auth/pair/"+req.ID, "", lanAddr, "")
+		var issued Issued
+		json.Unmarshal(w.Body.Bytes(), &issued)
+		if w.Code != http.StatusOK || issued.Device.Via != viaPairing || issued.Device.Name != "Tablet" {
+			t.Fatalf("[Err] Expected device token, got %d %s", w.Code, w.Body)
+		}
+
+		if w := do(mux, "GET", "/api/v1/status", "", lanAddr, issued.Token); w.Code == http.StatusUnauthorized {
+			t.Errorf("[Err] Expected paired token to work")
+		}
+		if w := do(mux, "GET", "/api/v1/auth/pair/"+req.ID, "", lanAddr, ""); w.Code != http.StatusNotFound {
+			t.Errorf("[Err] Expected token to be given only once, got %d", w.Code)
+		}
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		req := request(t)
+
+		do(mux, "POST", "/api/v1/auth/pair/"+req.ID+"/deny", "", desktop, "")
+		if w := do(mux, "GET", "/api/v1/auth/pair/"+req.ID, "", lanAddr, ""); w.Code != http.StatusForbidden {
+			t.Errorf("[Err] Expected denied 403, got %d", w.Code)
+		}
+	})
+
+	t.Run("CrossOrigin", func(t *testing.T) {
+		req := request(t)
+
+		r := httptest.NewRequest("POST", "/api/v1/auth/pair/"+req.ID+"/approve", nil)
+		r.RemoteAddr = desktop
+		r.Host = "localhost:8448"
+		r.Header.Set("Origin", "https://evil.example")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("[Err] Expected cross origin approve to be forbidden, got %d", w.Code)
+		}
+
+		r = httptest.NewRequest("POST", "/api/v1/auth/pair/"+req.ID+"/approve", nil)
+		r.RemoteAddr = desktop
+		r.Host = "evil.example:8448"
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("[Err] Expected DNS rebinding to be forbidden, got %d", w.Code)
+		}
+	})
+}
+
+func TestPairQR(t *testing.T) {
+	a, mux := newTestAuth(t, config.Auth{Mode: config.AuthToken, Token: "secret", AllowLoopback: true})
+	a.WebURL = "http://192.168.1.10:8448"
+	const desktop = "127.0.0.1:50000"
+
+	t.Run("Formats", func(t *testing.T) {
+		tests := []struct {
+			Query       string
+			Code        int
+			ContentType string
+		}{
+			{"", http.StatusOK, "image/png"},
+			{"?format=png&size=512", http.StatusOK, "image/png"},
+			{"?format=svg", http.StatusOK, "image/svg+xml"},
+			{"?format=gif", http.StatusBadRequest, ""},
+			{"?size=10", http.StatusBadRequest, ""},
+		}
+
+		for _, tt := range tests {
+			w := do(mux, "GET", "/api/v1/pair"+tt.Query, "", desktop, "")
+			if w.Code != tt.Code || (tt.ContentType != "" && w.Header().Get("Content-Type") != tt.ContentType) {
+				t.Errorf("[Err] %q: expected %d %s, got %d %s", tt.Query, tt.Code, tt.ContentType, w.Code, w.Header().Get("Content-Type"))
+			}
+		}
+
+		if w := do(mux, "GET", "/api/v1/pair", "", lanAddr, ""); w.Code != http.StatusForbidden {
+			t.Errorf("[Err] Expected QR code only on the desktop, got %d", w.Code)
+		}
+	})
+
+	t.Run("OneTime", func(t *testing.T) {
+		url := a.PairURL()
+		target, ok := strings.CutPrefix(url, a.WebURL)
+		if !ok || !strings.HasPrefix(target, "/login?pair=") {
+			t.Fatalf("[Err] Unexpected pair URL %s", url)
+		}
+
+		w := do(mux, "GET", target, "", lanAddr, "")
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "/" {
+			t.Fatalf("[Err] Expected redirect to web app, got %d %s", w.Code, w.Header().Get("Location"))
+		}
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("[Err] Expected token cookie, got %v", cookies)
+		}
+		r := httptest.NewRequest("GET", "/api/v1/status", nil)
+		r.RemoteAddr = lanAddr
+		r.AddCookie(cookies[0])
+		if !a.Authenticate(r) {
+			t.Errorf("[Err] Expected cookie from QR code to be valid")
+		}
+
+		w = do(mux, "GET", target, "", lanAddr, "")
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "/login?expired=1" || len(w.Result().Cookies()) != 0 {
+			t.Errorf("[Err] Expected used QR code to be rejected, got %d %s", w.Code, w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		open, _ := newTestAuth(t, config.Auth{Mode: config.AuthNone})
+		open.WebURL = a.WebURL
+		if url := open.PairURL(); url != a.WebURL+"/" {
+			t.Errorf("[Err] Expected plain web app URL without auth, got %s", url)
+		}
+	})
+}
+
+func TestRole(t *testing.T) {
+	cfg := config.Auth{
+		Mode:        config.AuthToken,
+		Token:       "secret",
+		DefaultRole: config.RoleOperator,
+		Tokens:      []config.RoleToken{{Name: "TV", Token: "tv", Role: config.RoleViewer}},
+		Networks: []config.RoleNetwork{
+			{Network: "192.168.2.0/24", Role: config.RoleViewer},
+			{Network: "192.168.1.5", Role: config.RoleAdmin},
+		},
+	}
+
+	tests := []struct {
+		Name   string
+		Remote string
+		Token  string
+		Role   Role
+		OK     bool
+	}{
+		{"SharedToken", lanAddr, "secret", RoleOperator, true},
+		{"RoleToken", lanAddr, "tv", RoleViewer, true},
+		{"Network", "192.168.2.7:50000", "", RoleViewer, true},
+		{"SingleAddress", "192.168.1.5:50000", "", RoleAdmin, true},
+		{"TokenWinsOverNetwork", "192.168.1.5:50000", "tv", RoleViewer, true},
+		{"NoTokenOutsideNetworks", lanAddr, "", RoleNone, false},
+	}
+
+	a, err := New(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("[Err] New: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.Remote
+			if tt.Token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.Token)
+			}
+
+			role, ok := a.Role(r)
+			if role != tt.Role || ok != tt.OK {
+				t.Errorf("[Err] Expected %s %v, got %s %v", tt.Role, tt.OK, role, ok)
+			}
+		})
+	}
+
+	t.Run("AuthNone", func(t *testing.T) {
+		open, _ := New(config.Auth{Mode: config.AuthNone, DefaultRole: config.RoleViewer, Tokens: cfg.Tokens[:0]}, "")
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = lanAddr
+		if role, ok := open.Role(r); role != RoleViewer || !ok {
+			t.Errorf("[Err] Expected default role without auth, got %s %v", role, ok)
+		}
+	})
+}
+
+func TestRequiredRole(t *testing.T) {
+	for _, action := range prJSON.AvailableCommands {
+		if _, ok := actionRoles[action]; !ok {
+			t.Errorf("[Err] Action %s has no role, it's admin only by accident", action)
+		}
+	}
+
+	if !RoleOperator.Allows(prJSON.ActionSetSinkMuted) || RoleOperator.Allows(prJSON.ActionSetCardProfile) || !RoleViewer.Allows(prJSON.ActionGetStatus) {
+		t.Errorf("[Err] Unexpected role permissions")
+	}
+	if RequiredRole(prJSON.Action("Unknown")) != RoleAdmin {
+		t.Errorf("[Err] Expected unknown action to be admin only")
+	}
+}
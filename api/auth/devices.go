@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	viaLogin   = "login"
+	viaPairing = "pairing"
+	viaQR      = "qr"
+)
+
+// Device got its own token by login or pairing. Token itself is never stored, only its hash.
+type Device struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// login, pairing or qr
+	Via     string    `json:"via"`
+	Role    Role      `json:"role"`
+	Created time.Time `json:"created"`
+}
+
+type storedDevice struct {
+	Device
+	TokenHash string `json:"token_hash"`
+}
+
+// devices are kept in JSON file, so clients stay logged in after restart
+type devices struct {
+	path string
+
+	mu     sync.Mutex
+	byHash map[string]storedDevice
+}
+
+// loadDevices reads devices from path. Missing file is no devices yet, empty path is in memory only.
+// Devices saved before roles existed get defaultRole.
+func loadDevices(path string, defaultRole Role) (*devices, error) {
+	d := &devices{path: path, byHash: make(map[string]storedDevice)}
+	if path == "" {
+		return d, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("devices: %w", err)
+	}
+
+	var stored []storedDevice
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("devices %s: %w", path, err)
+	}
+	for _, s := range stored {
+		if s.Role == RoleNone {
+			s.Role = defaultRole
+		}
+		d.byHash[s.TokenHash] = s
+	}
+
+	return d, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (d *devices) find(token string) (Device, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.byHash[hashToken(token)]
+	return s.Device, ok
+}
+
+// add issues new token for device. Token is returned only here, it can't be read again.
+func (d *devices) add(name string, via string, role Role) (Device, string, error) {
+	token := randomString(32)
+	device := Device{
+		ID:      randomString(6),
+		Name:    name,
+		Via:     via,
+		Role:    role,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.byHash[hashToken(token)] = storedDevice{Device: device, TokenHash: hashToken(token)}
+	return device, token, d.save()
+}
+
+func (d *devices) list() []Device {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := make([]Device, 0, len(d.byHash))
+	for _, s := range d.byHash {
+		list = append(list, s.Device)
+	}
+	slices.SortFunc(list, func(a, b Device) int { return a.Created.Compare(b.Created) })
+	return list
+}
+
+// remove revokes device by ID, false when there is no such device
+func (d *devices) remove(id string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for hash, s := range d.byHash {
+		if s.ID == id {
+			delete(d.byHash, hash)
+			return true, d.save()
+		}
+	}
+	return false, nil
+}
+
+// setRole changes role of device by ID, false when there is no such device
+func (d *devices) setRole(id string, role Role) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for hash, s := range d.byHash {
+		if s.ID == id {
+			s.Role = role
+			d.byHash[hash] = s
+			return true, d.save()
+		}
+	}
+	return false, nil
+}
+
+// removeToken revokes device that uses token, fe. on logout
+func (d *devices) removeToken(token string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	hash := hashToken(token)
+	if _, ok := d.byHash[hash]; !ok {
+		return nil
+	}
+	delete(d.byHash, hash)
+	return d.save()
+}
+
+// save writes all devices at once, mu must be held
+func (d *devices) save() error {
+	if d.path == "" {
+		return nil
+	}
+
+	stored := make([]storedDevice, 0, len(d.byHash))
+	for _, s := range d.byHash {
+		stored = append(stored, s)
+	}
+	slices.SortFunc(stored, func(a, b storedDevice) int { return a.Created.Compare(b.Created) })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o700); err != nil {
+		return fmt.Errorf("devices: %w", err)
+	}
+
+	// Rename is atomic, crash in the middle doesn't log out every device
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("devices: %w", err)
+	}
+	if err := os.Rename(tmp, d.path); err != nil {
+		return fmt.Errorf("devices: %w", err)
+	}
+	return nil
+}
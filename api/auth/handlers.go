@@ -0,0 +1,349 @@
+package auth
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/undg/pulse-remote/api/config"
+	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/qr"
+)
+
+//go:embed pair.html
+var pairPage []byte
+
+//go:embed login.html
+var loginPage []byte
+
+// State tells web app if it has to show login, and which controls to disable
+type State struct {
+	Enabled       bool   `json:"enabled"`
+	Mode          string `json:"mode"`
+	Authenticated bool   `json:"authenticated"`
+	Role          Role   `json:"role,omitempty"`
+}
+
+// RoleChange is sent by the desktop, empty role is auth.default_role
+type RoleChange struct {
+	Role string `json:"role"`
+}
+
+// Login is sent by web app, with password or token depending on auth.mode
+type Login struct {
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	// Device name for the desktop, fe. "Kitchen tablet". User-Agent when empty.
+	Name string `json:"name,omitempty"`
+}
+
+// Issued is the device token, returned only once. It's also set as cookie.
+type Issued struct {
+	Token  string `json:"token"`
+	Device Device `json:"device"`
+}
+
+// Register adds login, logout and pairing endpoints to mux. They are public, except desktop only ones.
+func (a *Auth) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/auth", a.handleState)
+	mux.HandleFunc("GET /login", a.enabled(a.handleLoginPage))
+	mux.HandleFunc("POST /api/v1/auth/login", a.enabled(a.handleLogin))
+	mux.HandleFunc("POST /api/v1/auth/logout", a.enabled(a.handleLogout))
+
+	mux.HandleFunc("POST /api/v1/auth/pair", a.enabled(a.handlePairRequest))
+	mux.HandleFunc("GET /api/v1/auth/pair/{id}", a.enabled(a.handlePairClaim))
+
+	// Desktop
+	mux.HandleFunc("GET /pair", a.enabled(a.requireDesktop(a.handlePairPage)))
+	mux.HandleFunc("GET /api/v1/auth/pair", a.enabled(a.requireDesktop(a.handlePairList)))
+	mux.HandleFunc("POST /api/v1/auth/pair/{id}/approve", a.enabled(a.requireDesktop(a.handlePairDecision(PairApproved))))
+	mux.HandleFunc("POST /api/v1/auth/pair/{id}/deny", a.enabled(a.requireDesktop(a.handlePairDecision(PairDenied))))
+	mux.HandleFunc("GET /api/v1/auth/devices", a.enabled(a.requireDesktop(a.handleDevices)))
+	// QR code works without auth too, it's web app address then
+	mux.HandleFunc("GET /api/v1/pair", a.requireDesktop(a.handlePairQR))
+	mux.HandleFunc("PUT /api/v1/auth/devices/{id}/role", a.enabled(a.requireDesktop(a.handleDeviceRole)))
+	mux.HandleFunc("DELETE /api/v1/auth/devices/{id}", a.enabled(a.requireDesktop(a.handleDeviceRemove)))
+}
+
+func (a *Auth) enabled(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			http.Error(w, "Auth is disabled", http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// decodeBody reads small JSON body. Empty body leaves v as it is.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func setCookie(w http.ResponseWriter, r *http.Request, token string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// issue creates device and sets its token as cookie
+func (a *Auth) issue(w http.ResponseWriter, r *http.Request, name string, via string, role Role) Issued {
+	if name == "" {
+		name = r.UserAgent()
+	}
+
+	device, token, err := a.devices.add(name, via, role)
+	if err != nil {
+		// Device works until restart anyway
+		logger.Error().Err(err).Msg("Can't save devices")
+	}
+
+	logger.Info().Str("client_ip", r.RemoteAddr).Str("device", device.Name).Str("via", via).Stringer("role", role).Msg("Device token issued")
+
+	setCookie(w, r, token, 365*24*60*60)
+	return Issued{Token: token, Device: device}
+}
+
+func (a *Auth) handleState(w http.ResponseWriter, r *http.Request) {
+	role, ok := a.Role(r)
+	writeJSON(w, http.StatusOK, State{
+		Enabled:       a.Enabled(),
+		Mode:          a.mode,
+		Authenticated: ok,
+		Role:          role,
+	})
+}
+
+// readRole reads RoleChange body, false when response is already sent
+func (a *Auth) readRole(w http.ResponseWriter, r *http.Request) (Role, bool) {
+	var body RoleChange
+	if !decodeBody(w, r, &body) {
+		return RoleNone, false
+	}
+	if body.Role == "" {
+		return a.defaultRole, true
+	}
+
+	role, err := ParseRole(body.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return RoleNone, false
+	}
+	return role, true
+}
+
+func (a *Auth) handleLogin(w http.ResponseWriter, r *http.Request) {
+	ip := clientIP(r)
+	if wait := a.limiter.retryAfter(ip); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "Too many failed logins, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	var login Login
+	if !decodeBody(w, r, &login) {
+		return
+	}
+
+	ok := equal(login.Password, a.password)
+	if a.mode == config.AuthToken {
+		ok = equal(login.Token, a.token)
+	}
+
+	if !ok {
+		a.limiter.fail(ip)
+		logger.Warn().Str("client_ip", r.RemoteAddr).Msg("Login FAIL")
+		http.Error(w, "Wrong "+a.mode, http.StatusUnauthorized)
+		return
+	}
+
+	a.limiter.reset(ip)
+	writeJSON(w, http.StatusOK, a.issue(w, r, login.Name, viaLogin, a.defaultRole))
+}
+
+func (a *Auth) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if token := credential(r); token != "" {
+		if err := a.devices.removeToken(token); err != nil {
+			logger.Error().Err(err).Msg("Can't save devices")
+		}
+	}
+
+	setCookie(w, r, "", -1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Auth) handlePairRequest(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	if body.Name == "" {
+		body.Name = r.UserAgent()
+	}
+
+	req, ok := a.pairing.add(body.Name, clientIP(r))
+	if !ok {
+		http.Error(w, "Too many pairing requests waiting for confirmation", http.StatusTooManyRequests)
+		return
+	}
+
+	logger.Warn().Str("code", req.Code).Str("device", req.Name).Str("client_ip", req.ClientIP).Msg("Pairing request, confirm it on the desktop")
+	if a.Notify != nil {
+		a.Notify(req)
+	}
+
+	writeJSON(w, http.StatusAccepted, req)
+}
+
+// handlePairClaim is polled by device until request is decided. Approved request gives token once.
+func (a *Auth) handlePairClaim(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.pairing.get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "No such pairing request, it could expire", http.StatusNotFound)
+		return
+	}
+
+	switch req.Status {
+	case PairPending:
+		writeJSON(w, http.StatusAccepted, req)
+	case PairDenied:
+		a.pairing.finish(req.ID)
+		writeJSON(w, http.StatusForbidden, req)
+	case PairApproved:
+		a.pairing.finish(req.ID)
+		writeJSON(w, http.StatusOK, a.issue(w, r, req.Name, viaPairing, req.Role))
+	}
+}
+
+// handleLoginPage logs in right away with one-time token from QR code
+func (a *Auth) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if token := r.URL.Query().Get("pair"); token != "" {
+		if !a.pairing.redeemOneTime(token) {
+			logger.Warn().Str("client_ip", r.RemoteAddr).Msg("Expired or used QR code")
+			http.Redirect(w, r, "/login?expired=1", http.StatusFound)
+			return
+		}
+
+		a.issue(w, r, "", viaQR, a.defaultRole)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(loginPage)
+}
+
+func (a *Auth) handlePairPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(pairPage)
+}
+
+func (a *Auth) handlePairList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.pairing.pending())
+}
+
+// handlePairDecision approves with role from RoleChange body, or denies
+func (a *Auth) handlePairDecision(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, ok := a.readRole(w, r)
+		if !ok {
+			return
+		}
+
+		if !a.pairing.decide(r.PathValue("id"), status, role) {
+			http.Error(w, "No pending pairing request", http.StatusNotFound)
+			return
+		}
+
+		logger.Info().Str("id", r.PathValue("id")).Str("status", status).Stringer("role", role).Msg("Pairing request decided")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (a *Auth) handleDevices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.devices.list())
+}
+
+func (a *Auth) handleDeviceRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := a.readRole(w, r)
+	if !ok {
+		return
+	}
+
+	ok, err := a.devices.setRole(r.PathValue("id"), role)
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't save devices")
+	}
+	if !ok {
+		http.Error(w, "No such device", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Auth) handleDeviceRemove(w http.ResponseWriter, r *http.Request) {
+	ok, err := a.devices.remove(r.PathValue("id"))
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't save devices")
+	}
+	if !ok {
+		http.Error(w, "No such device", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePairQR serves PairURL as QR code, ?format=png (default) or svg, PNG ?size in pixels.
+// Every request gives new one-time token.
+func (a *Auth) handlePairQR(w http.ResponseWriter, r *http.Request) {
+	size := 256
+	if s := r.URL.Query().Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 64 || n > 2048 {
+			http.Error(w, "Invalid size, expected 64 to 2048", http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	var data []byte
+	var err error
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "png":
+		w.Header().Set("Content-Type", "image/png")
+		data, err = qr.PNG(a.PairURL(), size)
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		data, err = qr.SVG(a.PairURL())
+	default:
+		http.Error(w, "Invalid format, expected png or svg", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't render QR code")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Token is one-time, cached image would be useless
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
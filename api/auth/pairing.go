@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	PairPending  = "pending"
+	PairApproved = "approved"
+	PairDenied   = "denied"
+
+	// Unconfirmed request is forgotten after that
+	pairTTL = 5 * time.Minute
+	// Nobody confirms more at once, it's most likely someone spamming
+	maxPending = 10
+
+	// One-time tokens from QR codes, scanned right after they are shown
+	oneTimeTTL = 15 * time.Minute
+	maxOneTime = 20
+)
+
+// PairRequest waits for confirmation on the desktop. Device polls it with ID, that is known only to them,
+// Code is shown on both screens so it's clear which request to approve.
+type PairRequest struct {
+	ID       string `json:"id"`
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	ClientIP string `json:"client_ip"`
+	Status   string `json:"status"`
+	// Given by the desktop on approval
+	Role    Role      `json:"role,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+type pairing struct {
+	mu       sync.Mutex
+	requests map[string]*PairRequest
+	// One-time token hash to its expiry
+	oneTime map[string]time.Time
+}
+
+func newPairing() *pairing {
+	return &pairing{requests: make(map[string]*PairRequest), oneTime: make(map[string]time.Time)}
+}
+
+func pairCode() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	return fmt.Sprintf("%06d", n.Int64())
+}
+
+// expire removes old requests and one-time tokens, mu must be held
+func (p *pairing) expire() {
+	now := time.Now()
+	for id, req := range p.requests {
+		if now.After(req.Expires) {
+			delete(p.requests, id)
+		}
+	}
+	for hash, expires := range p.oneTime {
+		if now.After(expires) {
+			delete(p.oneTime, hash)
+		}
+	}
+}
+
+// add creates request, false when too many are waiting already
+func (p *pairing) add(name string, clientIP string) (PairRequest, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire()
+	if len(p.requests) >= maxPending {
+		return PairRequest{}, false
+	}
+
+	req := &PairRequest{
+		ID:       randomString(16),
+		Code:     pairCode(),
+		Name:     name,
+		ClientIP: clientIP,
+		Status:   PairPending,
+		Expires:  time.Now().Add(pairTTL).UTC().Truncate(time.Second),
+	}
+	p.requests[req.ID] = req
+	return *req, true
+}
+
+func (p *pairing) get(id string) (PairRequest, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire()
+	req, ok := p.requests[id]
+	if !ok {
+		return PairRequest{}, false
+	}
+	return *req, true
+}
+
+// pending requests for the desktop, oldest first
+func (p *pairing) pending() []PairRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire()
+	list := []PairRequest{}
+	for _, req := range p.requests {
+		if req.Status == PairPending {
+			list = append(list, *req)
+		}
+	}
+	slices.SortFunc(list, func(a, b PairRequest) int { return a.Expires.Compare(b.Expires) })
+	return list
+}
+
+// decide approves or denies pending request, false when there is no such request
+func (p *pairing) decide(id string, status string, role Role) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire()
+	req, ok := p.requests[id]
+	if !ok || req.Status != PairPending {
+		return false
+	}
+	req.Status = status
+	req.Role = role
+	return true
+}
+
+// finish removes decided request, so its token is given only once
+func (p *pairing) finish(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.requests, id)
+}
+
+// addOneTime creates token that pairs device without confirmation, it's shown only on the desktop.
+// The oldest one is dropped when there are too many.
+func (p *pairing) addOneTime() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire()
+	if len(p.oneTime) >= maxOneTime {
+		oldest := ""
+		for hash, expires := range p.oneTime {
+			if oldest == "" || expires.Before(p.oneTime[oldest]) {
+				oldest = hash
+			}
+		}
+		delete(p.oneTime, oldest)
+	}
+
+	token := randomString(16)
+	p.oneTime[hashToken(token)] = time.Now().Add(oneTimeTTL)
+	return token
+}
+
+// redeemOneTime is true only for the first use of valid token
+func (p *pairing) redeemOneTime(token string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire()
+	hash := hashToken(token)
+	if _, ok := p.oneTime[hash]; !ok {
+		return false
+	}
+	delete(p.oneTime, hash)
+	return true
+}
+
+const (
+	maxFailures  = 5
+	blockedFor   = time.Minute
+	limiterSweep = 1000
+)
+
+// limiter blocks client after too many wrong passwords
+type limiter struct {
+	mu       sync.Mutex
+	failures map[string]int
+	blocked  map[string]time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{failures: make(map[string]int), blocked: make(map[string]time.Time)}
+}
+
+// retryAfter is zero when client can try again
+func (l *limiter) retryAfter(ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until, ok := l.blocked[ip]
+	if !ok {
+		return 0
+	}
+	if wait := time.Until(until); wait > 0 {
+		return wait
+	}
+	delete(l.blocked, ip)
+	return 0
+}
+
+func (l *limiter) fail(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Don't grow forever when someone walks through many addresses
+	if len(l.failures) > limiterSweep {
+		clear(l.failures)
+	}
+
+	l.failures[ip]++
+	if l.failures[ip] >= maxFailures {
+		delete(l.failures, ip)
+		l.blocked[ip] = time.Now().Add(blockedFor)
+	}
+}
+
+func (l *limiter) reset(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, ip)
+}
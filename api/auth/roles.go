@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/undg/pulse-remote/api/config"
+	"github.com/undg/pulse-remote/api/json"
+)
+
+// Role limits actions of client. Every role can do everything the former ones can.
+type Role int
+
+const (
+	// RoleNone is not authenticated client
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleViewer:   config.RoleViewer,
+	RoleOperator: config.RoleOperator,
+	RoleAdmin:    config.RoleAdmin,
+}
+
+// actionRoles is the least role allowed to run action. Not listed actions are for admin.
+var actionRoles = map[json.Action]Role{
+	json.ActionGetStatus:    RoleViewer,
+	json.ActionGetBuildInfo: RoleViewer,
+
+	json.ActionSetSinkVolume:         RoleOperator,
+	json.ActionChangeSinkVolume:      RoleOperator,
+	json.ActionSetSinkBalance:        RoleOperator,
+	json.ActionSetSinkChannelVolumes: RoleOperator,
+	json.ActionSetSinkMuted:          RoleOperator,
+	json.ActionSetDefaultSink:        RoleAdmin,
+	json.ActionSetSinkPort:           RoleAdmin,
+
+	json.ActionSetSinkInputVolume:    RoleOperator,
+	json.ActionChangeSinkInputVolume: RoleOperator,
+	json.ActionSetSinkInputMuted:     RoleOperator,
+	json.ActionMoveSinkInput:         RoleAdmin,
+
+	json.ActionSetSourceVolume:    RoleOperator,
+	json.ActionChangeSourceVolume: RoleOperator,
+	json.ActionSetSourceMuted:     RoleOperator,
+	json.ActionSetDefaultSource:   RoleAdmin,
+	json.ActionSetSourcePort:      RoleAdmin,
+
+	json.ActionSetSourceInputVolume: RoleOperator,
+	json.ActionSetSourceInputMuted:  RoleOperator,
+	json.ActionMoveSourceOutput:     RoleAdmin,
+
+	json.ActionSetCardProfile: RoleAdmin,
+
+	json.ActionSubscribe:   RoleViewer,
+	json.ActionUnsubscribe: RoleViewer,
+}
+
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if n == name {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("role %q is not one of viewer, operator, admin", name)
+}
+
+// mustParseRole is for roles already checked by config.Validate
+func mustParseRole(name string) Role {
+	role, err := ParseRole(name)
+	if err != nil {
+		panic(err)
+	}
+	return role
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return "none"
+}
+
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// RequiredRole is the least role allowed to run action
+func RequiredRole(action json.Action) Role {
+	if role, ok := actionRoles[action]; ok {
+		return role
+	}
+	return RoleAdmin
+}
+
+func (r Role) Allows(action json.Action) bool {
+	return r >= RequiredRole(action)
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// AudioBackend is everything WebSocket and REST layers need from the sound server.
+// Pactl talks to real PulseAudio/PipeWire, fake.Backend keeps state in memory for tests.
+//
+// Setters return pactl.ErrInvalidArgument for requests that can't be executed
+// and *pactl.CommandError when the sound server rejects them.
+type AudioBackend interface {
+	GetStatus() pactl.Status
+	GetSinks() ([]pactl.Sink, error)
+	GetSources() ([]pactl.Source, error)
+	GetSinkInputs() ([]pactl.SinkInput, error)
+	GetSourceOutputs() ([]pactl.SourceOutput, error)
+	GetCards() ([]pactl.Card, error)
+
+	// SINKS, e.g. Speakers
+	SetSinkVolume(sinkName string, volume string) error
+	ChangeSinkVolume(sinkName string, delta float64) error
+	SetSinkBalance(sinkName string, balance float64) error
+	SetSinkChannelVolumes(sinkName string, volumes []float64) error
+	SetSinkMuted(sinkName string, muted bool) error
+	SetDefaultSink(sinkName string) error
+	SetSinkPort(sinkName string, port string) error
+
+	// Apps playing audio
+	SetSinkInputVolume(sinkInputID string, volume string) error
+	ChangeSinkInputVolume(sinkInputID string, delta float64) error
+	SetSinkInputMuted(sinkInputID string, muted bool) error
+	MoveSinkInput(sinkInputID string, sinkName string) error
+
+	// SOURCES, e.g. Microphones
+	SetSourceVolume(sourceName string, volume string) error
+	ChangeSourceVolume(sourceName string, delta float64) error
+	SetSourceMuted(sourceName string, muted bool) error
+	SetDefaultSource(sourceName string) error
+	SetSourcePort(sourceName string, port string) error
+
+	// Apps active access to microphones
+	SetSourceOutputVolume(sourceOutputID string, volume string) error
+	SetSourceOutputMuted(sourceOutputID string, muted bool) error
+	MoveSourceOutput(sourceOutputID string, sourceName string) error
+
+	// CARDS, e.g. Bluetooth headset with A2DP and HSP/HFP profiles
+	SetCardProfile(cardName string, profile string) error
+
+	// ListenForChanges calls callback for every change that may affect Status. Blocks until ctx is done.
+	ListenForChanges(ctx context.Context, callback func(pactl.Event))
+}
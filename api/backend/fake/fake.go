@@ -0,0 +1,551 @@
+// Package fake is in-memory AudioBackend. Setters change the state and emit the same
+// events as a real sound server would, so the whole server can be tested without one.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/undg/pulse-remote/api/backend"
+	"github.com/undg/pulse-remote/api/buildinfo"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// Call is a single write operation received by Backend
+type Call struct {
+	Method string
+	Args   []string
+}
+
+var _ backend.AudioBackend = (*Backend)(nil)
+
+type Backend struct {
+	mu        sync.Mutex
+	status    pactl.Status
+	calls     []Call
+	listeners []func(pactl.Event)
+	closed    chan struct{}
+}
+
+// New returns Backend with two sinks, a microphone, one app playing and one recording audio
+// and bluetooth card behind the headset.
+func New() *Backend {
+	return NewWithStatus(pactl.Status{
+		Sinks: []pactl.Sink{
+			{
+				ID: 55, Name: "alsa_output.speakers", Label: "Speakers", IsDefault: true,
+				Volume: 50, Channels: stereo(50),
+				ActivePort: "analog-output-lineout",
+				Ports: []pactl.Port{
+					{Name: "analog-output-lineout", Label: "Line Out", Priority: 9000, Available: pactl.PortAvailableYes},
+					{Name: "analog-output-headphones", Label: "Headphones", Priority: 9900, Available: pactl.PortAvailableNo},
+				},
+			},
+			{ID: 56, Name: "bluez_output.headset", Label: "Headset", Volume: 80, Channels: mono(80)},
+		},
+		SinkInputs: []pactl.SinkInput{
+			{ID: 91, SinkID: 55, Label: "Firefox", Volume: 100, Channels: stereo(100)},
+		},
+		Sources: []pactl.Source{
+			{ID: 57, Name: "alsa_output.speakers.monitor", Label: "Monitor of Speakers", Volume: 100, Channels: stereo(100), Monitor: "alsa_output.speakers", Monitored: true},
+			{
+				ID: 58, Name: "alsa_input.mic", Label: "Microphone", Monitor: "n/a", IsDefault: true,
+				Volume: 70, Channels: mono(70),
+				ActivePort: "analog-input-internal-mic",
+				Ports: []pactl.Port{
+					{Name: "analog-input-internal-mic", Label: "Internal Microphone", Priority: 8900, Available: pactl.PortAvailableUnknown},
+					{Name: "analog-input-headset-mic", Label: "Headset Microphone", Priority: 8800, Available: pactl.PortAvailableNo},
+				},
+			},
+		},
+		SourceOutputs: []pactl.SourceOutput{
+			{ID: 93, SourceID: 58, Label: "Zoom", Volume: 100},
+		},
+		Cards: []pactl.Card{
+			{
+				ID:            60,
+				Name:          "bluez_card.headset",
+				Label:         "Headset",
+				ActiveProfile: "a2dp-sink",
+				Profiles: []pactl.CardProfile{
+					{Name: "a2dp-sink", Label: "High Fidelity Playback (A2DP Sink)", Sinks: 1, Priority: 40, Available: true},
+					{Name: "headset-head-unit", Label: "Headset Head Unit (HSP/HFP)", Sinks: 1, Sources: 1, Priority: 30, Available: true},
+					{Name: "off", Label: "Off", Available: true},
+				},
+			},
+		},
+	})
+}
+
+func stereo(volume int) []pactl.ChannelVolume {
+	return []pactl.ChannelVolume{{Name: "front-left", Volume: volume}, {Name: "front-right", Volume: volume}}
+}
+
+func mono(volume int) []pactl.ChannelVolume {
+	return []pactl.ChannelVolume{{Name: "mono", Volume: volume}}
+}
+
+func NewWithStatus(status pactl.Status) *Backend {
+	status.BuildInfo = *buildinfo.Get()
+
+	return &Backend{
+		status: status,
+		closed: make(chan struct{}),
+	}
+}
+
+// SetStatus replaces whole state, fe. to simulate device plugged in.
+func (b *Backend) SetStatus(status pactl.Status) {
+	b.mu.Lock()
+	status.BuildInfo = b.status.BuildInfo
+	b.status = status
+	b.mu.Unlock()
+
+	b.Emit(pactl.Event{Type: "change", Facility: "server", Index: -1})
+}
+
+// Update modifies state in place and emits event, without recording a Call.
+func (b *Backend) Update(ev pactl.Event, fn func(status *pactl.Status)) {
+	b.mu.Lock()
+	fn(&b.status)
+	b.mu.Unlock()
+
+	b.Emit(ev)
+}
+
+// Emit sends event to every ListenForChanges callback.
+func (b *Backend) Emit(ev pactl.Event) {
+	b.mu.Lock()
+	listeners := slices.Clone(b.listeners)
+	b.mu.Unlock()
+
+	for _, callback := range listeners {
+		callback(ev)
+	}
+}
+
+// Calls returns every write operation in order.
+func (b *Backend) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.calls)
+}
+
+// Close releases ListenForChanges callers.
+func (b *Backend) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-b.closed:
+	default:
+		close(b.closed)
+	}
+}
+
+func (b *Backend) GetStatus() pactl.Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return pactl.Status{
+		Sinks:         slices.Clone(b.status.Sinks),
+		SinkInputs:    slices.Clone(b.status.SinkInputs),
+		Sources:       slices.Clone(b.status.Sources),
+		SourceOutputs: slices.Clone(b.status.SourceOutputs),
+		Cards:         slices.Clone(b.status.Cards),
+		BuildInfo:     b.status.BuildInfo,
+	}
+}
+
+func (b *Backend) GetSinks() ([]pactl.Sink, error) {
+	return b.GetStatus().Sinks, nil
+}
+
+func (b *Backend) GetSources() ([]pactl.Source, error) {
+	return b.GetStatus().Sources, nil
+}
+
+func (b *Backend) GetSinkInputs() ([]pactl.SinkInput, error) {
+	return b.GetStatus().SinkInputs, nil
+}
+
+func (b *Backend) GetSourceOutputs() ([]pactl.SourceOutput, error) {
+	return b.GetStatus().SourceOutputs, nil
+}
+
+func (b *Backend) GetCards() ([]pactl.Card, error) {
+	return b.GetStatus().Cards, nil
+}
+
+func (b *Backend) ListenForChanges(ctx context.Context, callback func(pactl.Event)) {
+	b.mu.Lock()
+	b.listeners = append(b.listeners, callback)
+	b.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+	case <-b.closed:
+	}
+}
+
+// write records the call, applies fn under lock and emits change event when fn found the object.
+// Unknown objects fail with the same error as the real sound server gives.
+func (b *Backend) write(call Call, facility string, fn func(status *pactl.Status) (index int, ok bool)) error {
+	b.mu.Lock()
+	b.calls = append(b.calls, call)
+	index, ok := fn(&b.status)
+	b.mu.Unlock()
+
+	if !ok {
+		return &pactl.CommandError{Command: call.Method, Target: call.Args[0], Message: "No such entity", Err: pactl.ErrNoEntity}
+	}
+
+	b.Emit(pactl.Event{Type: "change", Facility: facility, Index: index})
+	return nil
+}
+
+func checkVolume(volume string) error {
+	if _, err := strconv.ParseFloat(volume, 64); err != nil {
+		return fmt.Errorf("%w: volume %q is not a number", pactl.ErrInvalidArgument, volume)
+	}
+	return nil
+}
+
+func parseVolume(volume string) int {
+	v, _ := strconv.ParseFloat(volume, 64)
+	return int(math.Round(pactl.CapVolume(v)))
+}
+
+// applyVolume works like setVolume() of pactl package, volume with + or - sign is relative
+func applyVolume(current *int, balance *float64, channels *[]pactl.ChannelVolume, volume string) {
+	v, _ := strconv.ParseFloat(volume, 64)
+	relative := strings.HasPrefix(volume, "+") || strings.HasPrefix(volume, "-")
+
+	fallback := pactl.CapVolume(v)
+	if relative {
+		fallback = pactl.CapVolume(float64(*current) + v)
+	}
+
+	setChannels(current, balance, channels, func(c []pactl.ChannelVolume) []pactl.ChannelVolume {
+		if relative {
+			return pactl.WithDelta(c, v)
+		}
+		return pactl.WithVolume(c, v)
+	}, int(math.Round(fallback)))
+}
+
+// setChannels replaces channels and derives Volume and Balance from them, the same way pactl package does.
+// Status without channels only gets Volume.
+func setChannels(volume *int, balance *float64, channels *[]pactl.ChannelVolume, fn func([]pactl.ChannelVolume) []pactl.ChannelVolume, fallback int) {
+	if len(*channels) == 0 {
+		*volume = fallback
+		return
+	}
+	*channels = fn(*channels)
+	*volume, *balance = pactl.VolumeAndBalance(*channels)
+}
+
+func parseID(id string) int {
+	v, err := strconv.Atoi(id)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+func (b *Backend) SetSinkVolume(sinkName string, volume string) error {
+	return b.setSinkVolume(Call{"SetSinkVolume", []string{sinkName, volume}}, sinkName, volume)
+}
+
+func (b *Backend) ChangeSinkVolume(sinkName string, delta float64) error {
+	volume := fmt.Sprintf("%+.2f", delta)
+	return b.setSinkVolume(Call{"ChangeSinkVolume", []string{sinkName, volume}}, sinkName, volume)
+}
+
+func (b *Backend) setSinkVolume(call Call, sinkName string, volume string) error {
+	if err := checkVolume(volume); err != nil {
+		return err
+	}
+
+	return b.write(call, "sink", func(s *pactl.Status) (int, bool) {
+		for i := range s.Sinks {
+			if sink := &s.Sinks[i]; sink.Name == sinkName {
+				applyVolume(&sink.Volume, &sink.Balance, &sink.Channels, volume)
+				return sink.ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetSinkBalance(sinkName string, balance float64) error {
+	if balance < -1 || balance > 1 {
+		return fmt.Errorf("%w: balance %v is not between -1 and 1", pactl.ErrInvalidArgument, balance)
+	}
+
+	return b.write(Call{"SetSinkBalance", []string{sinkName, strconv.FormatFloat(balance, 'f', -1, 64)}}, "sink", func(s *pactl.Status) (int, bool) {
+		for i := range s.Sinks {
+			if sink := &s.Sinks[i]; sink.Name == sinkName {
+				setChannels(&sink.Volume, &sink.Balance, &sink.Channels, func(c []pactl.ChannelVolume) []pactl.ChannelVolume {
+					return pactl.WithBalance(c, balance)
+				}, sink.Volume)
+				return sink.ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetSinkChannelVolumes(sinkName string, volumes []float64) error {
+	args := []string{sinkName}
+	for _, v := range volumes {
+		args = append(args, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	var invalid error
+	err := b.write(Call{"SetSinkChannelVolumes", args}, "sink", func(s *pactl.Status) (int, bool) {
+		for i := range s.Sinks {
+			sink := &s.Sinks[i]
+			if sink.Name != sinkName {
+				continue
+			}
+			if len(sink.Channels) != len(volumes) {
+				invalid = fmt.Errorf("%w: expected %d channel volumes, got %d", pactl.ErrInvalidArgument, len(sink.Channels), len(volumes))
+				return -1, false
+			}
+
+			setChannels(&sink.Volume, &sink.Balance, &sink.Channels, func(c []pactl.ChannelVolume) []pactl.ChannelVolume {
+				channels := slices.Clone(c)
+				for i := range channels {
+					channels[i].Volume = int(math.Round(volumes[i]))
+				}
+				return channels
+			}, sink.Volume)
+			return sink.ID, true
+		}
+		return -1, false
+	})
+
+	if invalid != nil {
+		return invalid
+	}
+	return err
+}
+
+func (b *Backend) SetSinkMuted(sinkName string, muted bool) error {
+	return b.write(Call{"SetSinkMuted", []string{sinkName, strconv.FormatBool(muted)}}, "sink", func(s *pactl.Status) (int, bool) {
+		for i := range s.Sinks {
+			if s.Sinks[i].Name == sinkName {
+				s.Sinks[i].Muted = muted
+				return s.Sinks[i].ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetDefaultSink(sinkName string) error {
+	return b.write(Call{"SetDefaultSink", []string{sinkName}}, "server", func(s *pactl.Status) (int, bool) {
+		if !slices.ContainsFunc(s.Sinks, func(sink pactl.Sink) bool { return sink.Name == sinkName }) {
+			return -1, false
+		}
+		for i := range s.Sinks {
+			s.Sinks[i].IsDefault = s.Sinks[i].Name == sinkName
+		}
+		return -1, true
+	})
+}
+
+func (b *Backend) SetSinkPort(sinkName string, port string) error {
+	return b.write(Call{"SetSinkPort", []string{sinkName, port}}, "sink", func(s *pactl.Status) (int, bool) {
+		for i := range s.Sinks {
+			sink := &s.Sinks[i]
+			if sink.Name == sinkName && slices.ContainsFunc(sink.Ports, func(p pactl.Port) bool { return p.Name == port }) {
+				sink.ActivePort = port
+				return sink.ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetSinkInputVolume(sinkInputID string, volume string) error {
+	return b.setSinkInputVolume(Call{"SetSinkInputVolume", []string{sinkInputID, volume}}, sinkInputID, volume)
+}
+
+func (b *Backend) ChangeSinkInputVolume(sinkInputID string, delta float64) error {
+	volume := fmt.Sprintf("%+.2f", delta)
+	return b.setSinkInputVolume(Call{"ChangeSinkInputVolume", []string{sinkInputID, volume}}, sinkInputID, volume)
+}
+
+func (b *Backend) setSinkInputVolume(call Call, sinkInputID string, volume string) error {
+	if err := checkVolume(volume); err != nil {
+		return err
+	}
+
+	return b.write(call, "sink-input", func(s *pactl.Status) (int, bool) {
+		for i := range s.SinkInputs {
+			if si := &s.SinkInputs[i]; si.ID == parseID(sinkInputID) {
+				applyVolume(&si.Volume, &si.Balance, &si.Channels, volume)
+				return si.ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetSinkInputMuted(sinkInputID string, muted bool) error {
+	return b.write(Call{"SetSinkInputMuted", []string{sinkInputID, strconv.FormatBool(muted)}}, "sink-input", func(s *pactl.Status) (int, bool) {
+		for i := range s.SinkInputs {
+			if s.SinkInputs[i].ID == parseID(sinkInputID) {
+				s.SinkInputs[i].Muted = muted
+				return s.SinkInputs[i].ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) MoveSinkInput(sinkInputID string, sinkName string) error {
+	return b.write(Call{"MoveSinkInput", []string{sinkInputID, sinkName}}, "sink-input", func(s *pactl.Status) (int, bool) {
+		sinkIdx := slices.IndexFunc(s.Sinks, func(sink pactl.Sink) bool { return sink.Name == sinkName })
+		if sinkIdx < 0 {
+			return -1, false
+		}
+		for i := range s.SinkInputs {
+			if s.SinkInputs[i].ID == parseID(sinkInputID) {
+				s.SinkInputs[i].SinkID = s.Sinks[sinkIdx].ID
+				return s.SinkInputs[i].ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetSourceVolume(sourceName string, volume string) error {
+	return b.setSourceVolume(Call{"SetSourceVolume", []string{sourceName, volume}}, sourceName, volume)
+}
+
+func (b *Backend) ChangeSourceVolume(sourceName string, delta float64) error {
+	volume := fmt.Sprintf("%+.2f", delta)
+	return b.setSourceVolume(Call{"ChangeSourceVolume", []string{sourceName, volume}}, sourceName, volume)
+}
+
+func (b *Backend) setSourceVolume(call Call, sourceName string, volume string) error {
+	if err := checkVolume(volume); err != nil {
+		return err
+	}
+
+	return b.write(call, "source", func(s *pactl.Status) (int, bool) {
+		for i := range s.Sources {
+			if source := &s.Sources[i]; source.Name == sourceName {
+				applyVolume(&source.Volume, &source.Balance, &source.Channels, volume)
+				return source.ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetSourceMuted(sourceName string, muted bool) error {
+	return b.write(Call{"SetSourceMuted", []string{sourceName, strconv.FormatBool(muted)}}, "source", func(s *pactl.Status) (int, bool) {
+		for i := range s.Sources {
+			if s.Sources[i].Name == sourceName {
+				s.Sources[i].Muted = muted
+				return s.Sources[i].ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetDefaultSource(sourceName string) error {
+	return b.write(Call{"SetDefaultSource", []string{sourceName}}, "server", func(s *pactl.Status) (int, bool) {
+		if !slices.ContainsFunc(s.Sources, func(source pactl.Source) bool { return source.Name == sourceName }) {
+			return -1, false
+		}
+		for i := range s.Sources {
+			s.Sources[i].IsDefault = s.Sources[i].Name == sourceName
+		}
+		return -1, true
+	})
+}
+
+func (b *Backend) SetSourcePort(sourceName string, port string) error {
+	return b.write(Call{"SetSourcePort", []string{sourceName, port}}, "source", func(s *pactl.Status) (int, bool) {
+		for i := range s.Sources {
+			source := &s.Sources[i]
+			if source.Name == sourceName && slices.ContainsFunc(source.Ports, func(p pactl.Port) bool { return p.Name == port }) {
+				source.ActivePort = port
+				return source.ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetSourceOutputVolume(sourceOutputID string, volume string) error {
+	if err := checkVolume(volume); err != nil {
+		return err
+	}
+
+	return b.write(Call{"SetSourceOutputVolume", []string{sourceOutputID, volume}}, "source-output", func(s *pactl.Status) (int, bool) {
+		for i := range s.SourceOutputs {
+			if s.SourceOutputs[i].ID == parseID(sourceOutputID) {
+				s.SourceOutputs[i].Volume = parseVolume(volume)
+				return s.SourceOutputs[i].ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetSourceOutputMuted(sourceOutputID string, muted bool) error {
+	return b.write(Call{"SetSourceOutputMuted", []string{sourceOutputID, strconv.FormatBool(muted)}}, "source-output", func(s *pactl.Status) (int, bool) {
+		for i := range s.SourceOutputs {
+			if s.SourceOutputs[i].ID == parseID(sourceOutputID) {
+				s.SourceOutputs[i].Muted = muted
+				return s.SourceOutputs[i].ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) MoveSourceOutput(sourceOutputID string, sourceName string) error {
+	return b.write(Call{"MoveSourceOutput", []string{sourceOutputID, sourceName}}, "source-output", func(s *pactl.Status) (int, bool) {
+		sourceIdx := slices.IndexFunc(s.Sources, func(source pactl.Source) bool { return source.Name == sourceName })
+		if sourceIdx < 0 {
+			return -1, false
+		}
+		for i := range s.SourceOutputs {
+			if s.SourceOutputs[i].ID == parseID(sourceOutputID) {
+				s.SourceOutputs[i].SourceID = s.Sources[sourceIdx].ID
+				return s.SourceOutputs[i].ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (b *Backend) SetCardProfile(cardName string, profile string) error {
+	return b.write(Call{"SetCardProfile", []string{cardName, profile}}, "card", func(s *pactl.Status) (int, bool) {
+		for i := range s.Cards {
+			card := &s.Cards[i]
+			if card.Name == cardName && slices.ContainsFunc(card.Profiles, func(p pactl.CardProfile) bool { return p.Name == profile }) {
+				card.ActiveProfile = profile
+				return card.ID, true
+			}
+		}
+		return -1, false
+	})
+}
+
+func (c Call) String() string {
+	return fmt.Sprintf("%s%v", c.Method, c.Args)
+}
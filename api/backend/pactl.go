@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// Pactl is AudioBackend of the real sound server, see pactl package.
+type Pactl struct{}
+
+var _ AudioBackend = Pactl{}
+
+func (Pactl) GetStatus() pactl.Status {
+	return pactl.GetStatus()
+}
+
+func (Pactl) GetSinks() ([]pactl.Sink, error) {
+	return pactl.GetSinks()
+}
+
+func (Pactl) GetSources() ([]pactl.Source, error) {
+	return pactl.GetSources()
+}
+
+func (Pactl) GetSinkInputs() ([]pactl.SinkInput, error) {
+	return pactl.GetSinkInputs()
+}
+
+func (Pactl) GetSourceOutputs() ([]pactl.SourceOutput, error) {
+	return pactl.GetSourceOutputs()
+}
+
+func (Pactl) GetCards() ([]pactl.Card, error) {
+	return pactl.GetCards()
+}
+
+func (Pactl) SetSinkVolume(sinkName string, volume string) error {
+	return pactl.SetSinkVolume(sinkName, volume)
+}
+
+func (Pactl) ChangeSinkVolume(sinkName string, delta float64) error {
+	return pactl.ChangeSinkVolume(sinkName, delta)
+}
+
+func (Pactl) SetSinkBalance(sinkName string, balance float64) error {
+	return pactl.SetSinkBalance(sinkName, balance)
+}
+
+func (Pactl) SetSinkChannelVolumes(sinkName string, volumes []float64) error {
+	return pactl.SetSinkChannelVolumes(sinkName, volumes)
+}
+
+func (Pactl) SetSinkMuted(sinkName string, muted bool) error {
+	return pactl.SetSinkMuted(sinkName, muted)
+}
+
+func (Pactl) SetDefaultSink(sinkName string) error {
+	return pactl.SetDefaultSink(sinkName)
+}
+
+func (Pactl) SetSinkPort(sinkName string, port string) error {
+	return pactl.SetSinkPort(sinkName, port)
+}
+
+func (Pactl) SetSinkInputVolume(sinkInputID string, volume string) error {
+	return pactl.SetSinkInputVolume(sinkInputID, volume)
+}
+
+func (Pactl) ChangeSinkInputVolume(sinkInputID string, delta float64) error {
+	return pactl.ChangeSinkInputVolume(sinkInputID, delta)
+}
+
+func (Pactl) SetSinkInputMuted(sinkInputID string, muted bool) error {
+	return pactl.SetSinkInputMuted(sinkInputID, muted)
+}
+
+func (Pactl) MoveSinkInput(sinkInputID string, sinkName string) error {
+	return pactl.MoveSinkInput(sinkInputID, sinkName)
+}
+
+func (Pactl) SetSourceVolume(sourceName string, volume string) error {
+	return pactl.SetSourceVolume(sourceName, volume)
+}
+
+func (Pactl) ChangeSourceVolume(sourceName string, delta float64) error {
+	return pactl.ChangeSourceVolume(sourceName, delta)
+}
+
+func (Pactl) SetSourceMuted(sourceName string, muted bool) error {
+	return pactl.SetSourceMuted(sourceName, muted)
+}
+
+func (Pactl) SetDefaultSource(sourceName string) error {
+	return pactl.SetDefaultSource(sourceName)
+}
+
+func (Pactl) SetSourcePort(sourceName string, port string) error {
+	return pactl.SetSourcePort(sourceName, port)
+}
+
+func (Pactl) SetSourceOutputVolume(sourceOutputID string, volume string) error {
+	return pactl.SetSourceOutputVolume(sourceOutputID, volume)
+}
+
+func (Pactl) SetSourceOutputMuted(sourceOutputID string, muted bool) error {
+	return pactl.SetSourceOutputMuted(sourceOutputID, muted)
+}
+
+func (Pactl) MoveSourceOutput(sourceOutputID string, sourceName string) error {
+	return pactl.MoveSourceOutput(sourceOutputID, sourceName)
+}
+
+func (Pactl) ListenForChanges(ctx context.Context, callback func(pactl.Event)) {
+	pactl.ListenForChanges(ctx, callback)
+}
+
+func (Pactl) SetCardProfile(cardName string, profile string) error {
+	return pactl.SetCardProfile(cardName, profile)
+}
@@ -0,0 +1,360 @@
+// Package config merges defaults, config file, PULSE_REMOTE_* env vars and CLI flags, in that order.
+// Every later layer overrides the former one.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/undg/pulse-remote/api/logger"
+)
+
+const (
+	AuthNone     = "none"
+	AuthToken    = "token"
+	AuthPassword = "password"
+)
+
+// Roles from the least to the most allowed
+const (
+	// Only receives status
+	RoleViewer = "viewer"
+	// Changes volume and mute
+	RoleOperator = "operator"
+	// Everything, fe. default devices, moving streams, card profiles
+	RoleAdmin = "admin"
+)
+
+var roles = []string{RoleViewer, RoleOperator, RoleAdmin}
+
+// Config of the server. Keys in config file are the same as toml/yaml tags,
+// env vars and flags are derived from them, fe. tls.cert_file is PULSE_REMOTE_TLS_CERT_FILE and --tls-cert-file.
+type Config struct {
+	// Listen address, empty for all interfaces
+	Host string `toml:"host" yaml:"host"`
+	Port int    `toml:"port" yaml:"port"`
+	// Network interface to listen on instead of Host, fe. wlan0
+	Interface string `toml:"interface" yaml:"interface"`
+	// Clients allowed to connect, in CIDR notation. Empty allows everyone.
+	AllowedNetworks []string `toml:"allowed_networks" yaml:"allowed_networks"`
+	// Websites allowed to open WebSocket besides the server itself, fe. https://audio.example.com behind reverse proxy
+	AllowedOrigins []string `toml:"allowed_origins" yaml:"allowed_origins"`
+	// Status is broadcasted at least this often, even without events from the sound server
+	PollInterval Duration `toml:"poll_interval" yaml:"poll_interval"`
+	// WebSocket clients are pinged this often, and dropped without pong or message for PongTimeout
+	PingInterval Duration `toml:"ping_interval" yaml:"ping_interval"`
+	PongTimeout  Duration `toml:"pong_timeout" yaml:"pong_timeout"`
+	// WebSocket client that can't take a message this long is dropped
+	WriteTimeout Duration `toml:"write_timeout" yaml:"write_timeout"`
+	// On SIGINT or SIGTERM requests and WebSocket actions in flight get this long to finish
+	ShutdownTimeout Duration `toml:"shutdown_timeout" yaml:"shutdown_timeout"`
+	// Max volume of any channel in percent, remote clients can't go above it
+	MaxVolume float64 `toml:"max_volume" yaml:"max_volume"`
+	// Same values as DEBUG env var, fe. INFO or TRACE
+	LogLevel string `toml:"log_level" yaml:"log_level"`
+	// Paired devices and other state, $XDG_DATA_HOME/pulse-remote when empty
+	DataDir string `toml:"data_dir" yaml:"data_dir"`
+
+	TLS  TLS  `toml:"tls" yaml:"tls"`
+	Auth Auth `toml:"auth" yaml:"auth"`
+}
+
+type TLS struct {
+	Enabled bool `toml:"enabled" yaml:"enabled"`
+	// PEM files. Without them local CA and server certificate are generated in DataDir.
+	CertFile string `toml:"cert_file" yaml:"cert_file"`
+	KeyFile  string `toml:"key_file" yaml:"key_file"`
+	// Plain HTTP port redirecting to HTTPS, 0 disables it
+	RedirectPort int `toml:"redirect_port" yaml:"redirect_port"`
+}
+
+type Auth struct {
+	// none, token or password
+	Mode     string `toml:"mode" yaml:"mode"`
+	Token    string `toml:"token" yaml:"token"`
+	Password string `toml:"password" yaml:"password"`
+	// Clients on the same machine, fe. desktop app, don't have to log in. They are admins.
+	AllowLoopback bool `toml:"allow_loopback" yaml:"allow_loopback"`
+	// Role of shared token, logged in and paired devices, and everyone with mode none
+	DefaultRole string `toml:"default_role" yaml:"default_role"`
+	// Extra tokens with their own role, fe. for living room tablet
+	Tokens []RoleToken `toml:"tokens" yaml:"tokens"`
+	// Clients from these networks get the role without token, the first matching one wins
+	Networks []RoleNetwork `toml:"networks" yaml:"networks"`
+}
+
+type RoleToken struct {
+	Name  string `toml:"name" yaml:"name"`
+	Token string `toml:"token" yaml:"token"`
+	Role  string `toml:"role" yaml:"role"`
+}
+
+type RoleNetwork struct {
+	// CIDR or single address
+	Network string `toml:"network" yaml:"network"`
+	Role    string `toml:"role" yaml:"role"`
+}
+
+// Duration reads "5s" or "1m" from config file, env vars and flags
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func Default() Config {
+	return Config{
+		Port:            8448,
+		AllowedNetworks: []string{},
+		AllowedOrigins:  []string{},
+		PollInterval:    Duration{5 * time.Second},
+		PingInterval:    Duration{20 * time.Second},
+		PongTimeout:     Duration{45 * time.Second},
+		WriteTimeout:    Duration{10 * time.Second},
+		ShutdownTimeout: Duration{10 * time.Second},
+		MaxVolume:       150,
+		LogLevel:        "INFO",
+		Auth:            Auth{Mode: AuthNone, AllowLoopback: true, DefaultRole: RoleAdmin},
+	}
+}
+
+// Validate returns all problems at once, so they can be fixed in one go
+func (c Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		add("port: %d is not between 1 and 65535", c.Port)
+	}
+	if c.Host != "" && c.Host != "localhost" && net.ParseIP(c.Host) == nil {
+		add("host: %q is not an IP address", c.Host)
+	}
+	if c.Host != "" && c.Interface != "" {
+		add("host and interface can't be used together")
+	}
+	if _, err := c.Networks(); err != nil {
+		add("allowed_networks: %w", err)
+	}
+	if _, err := c.Origins(); err != nil {
+		add("allowed_origins: %w", err)
+	}
+	if c.PollInterval.Duration < 100*time.Millisecond {
+		add("poll_interval: %s is shorter than 100ms", c.PollInterval)
+	}
+	if c.PingInterval.Duration < time.Second {
+		add("ping_interval: %s is shorter than 1s", c.PingInterval)
+	}
+	if c.PongTimeout.Duration <= c.PingInterval.Duration {
+		add("pong_timeout: %s is not longer than ping_interval %s", c.PongTimeout, c.PingInterval)
+	}
+	if c.WriteTimeout.Duration < time.Second {
+		add("write_timeout: %s is shorter than 1s", c.WriteTimeout)
+	}
+	if c.ShutdownTimeout.Duration < time.Second {
+		add("shutdown_timeout: %s is shorter than 1s", c.ShutdownTimeout)
+	}
+	if c.MaxVolume < 1 || c.MaxVolume > 255 {
+		add("max_volume: %v is not between 1 and 255", c.MaxVolume)
+	}
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		add("log_level: %w", err)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		add("tls: cert_file and key_file go together, leave both empty for generated certificate")
+	}
+	if c.TLS.Enabled && c.TLS.CertFile == "" && c.DataDir == "" {
+		add("tls: data_dir is required for generated certificate")
+	}
+	if c.TLS.RedirectPort != 0 {
+		switch {
+		case !c.TLS.Enabled:
+			add("tls: redirect_port needs tls enabled")
+		case c.TLS.RedirectPort < 1 || c.TLS.RedirectPort > 65535:
+			add("tls: redirect_port %d is not between 1 and 65535", c.TLS.RedirectPort)
+		case c.TLS.RedirectPort == c.Port:
+			add("tls: redirect_port can't be the same as port")
+		}
+	}
+
+	switch c.Auth.Mode {
+	case AuthNone:
+	case AuthToken:
+		if c.Auth.Token == "" {
+			add("auth: token is required in token mode")
+		}
+	case AuthPassword:
+		if c.Auth.Password == "" {
+			add("auth: password is required in password mode")
+		}
+	default:
+		add("auth: mode %q is not one of none, token, password", c.Auth.Mode)
+	}
+
+	if !slices.Contains(roles, c.Auth.DefaultRole) {
+		add("auth: default_role %q is not one of %s", c.Auth.DefaultRole, strings.Join(roles, ", "))
+	}
+	for i, t := range c.Auth.Tokens {
+		if t.Token == "" {
+			add("auth.tokens[%d]: token is required", i)
+		}
+		if !slices.Contains(roles, t.Role) {
+			add("auth.tokens[%d]: role %q is not one of %s", i, t.Role, strings.Join(roles, ", "))
+		}
+	}
+	for i, n := range c.Auth.Networks {
+		if _, err := ParseNetwork(n.Network); err != nil {
+			add("auth.networks[%d]: %w", i, err)
+		}
+		if !slices.Contains(roles, n.Role) {
+			add("auth.networks[%d]: role %q is not one of %s", i, n.Role, strings.Join(roles, ", "))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Networks parses AllowedNetworks
+func (c Config) Networks() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, n := range c.AllowedNetworks {
+		network, err := ParseNetwork(n)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Origins parses AllowedOrigins to the form browsers send in Origin header, fe. https://audio.example.com
+func (c Config) Origins() ([]string, error) {
+	var origins []string
+	for _, o := range c.AllowedOrigins {
+		origin, err := ParseOrigin(o)
+		if err != nil {
+			return nil, err
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+// ParseOrigin reads scheme://host[:port] and normalizes it, lower case and without default port
+func ParseOrigin(o string) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(o, "/"))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%q is not http or https origin", o)
+	}
+	if u.Host == "" || u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%q is not scheme://host[:port]", o)
+	}
+
+	host := strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		host = strings.ToLower(u.Hostname())
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+	return u.Scheme + "://" + host, nil
+}
+
+// ParseNetwork reads CIDR network. Single address is a network of one host.
+func ParseNetwork(n string) (*net.IPNet, error) {
+	if ip := net.ParseIP(n); ip != nil {
+		bits := 8 * len(ip.To16())
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(n)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not an IP address or CIDR network", n)
+	}
+	return network, nil
+}
+
+// ListenAddr is host:port for http.Server. With Interface set, host is one of its addresses.
+func (c Config) ListenAddr() (string, error) {
+	host := c.Host
+
+	if c.Interface != "" {
+		iface, err := net.InterfaceByName(c.Interface)
+		if err != nil {
+			return "", fmt.Errorf("interface %q: %w", c.Interface, err)
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return "", fmt.Errorf("interface %q: %w", c.Interface, err)
+		}
+		if host = interfaceHost(addrs); host == "" {
+			return "", fmt.Errorf("interface %q has no address to listen on", c.Interface)
+		}
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(c.Port)), nil
+}
+
+// interfaceHost picks address to listen on, IPv4 first, it's what most phones on home network will use.
+// IPv6 link-local addresses are left out, they can't be bound without zone, empty when nothing is left.
+func interfaceHost(addrs []net.Addr) string {
+	host := ""
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			return ipnet.IP.String()
+		}
+		if host == "" && !ipnet.IP.IsLinkLocalUnicast() {
+			host = ipnet.IP.String()
+		}
+	}
+	return host
+}
+
+// Redacted hides secrets, fe. for --print-config
+func (c Config) Redacted() Config {
+	if c.Auth.Token != "" {
+		c.Auth.Token = "***"
+	}
+	if c.Auth.Password != "" {
+		c.Auth.Password = "***"
+	}
+
+	tokens := make([]RoleToken, len(c.Auth.Tokens))
+	for i, t := range c.Auth.Tokens {
+		tokens[i] = t
+		tokens[i].Token = "***"
+	}
+	if c.Auth.Tokens != nil {
+		c.Auth.Tokens = tokens
+	}
+	return c
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	home := t.TempDir()
+	cfg, opts, err := Load(nil, env(map[string]string{"HOME": home}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want := Default()
+	want.DataDir = filepath.Join(home, ".local", "share", "pulse-remote")
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Expected defaults, got %+v", cfg)
+	}
+	if opts.ConfigFile != "" || opts.PrintConfig {
+		t.Errorf("Expected empty options, got %+v", opts)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	home := t.TempDir()
+	path := filepath.Join(home, ".config", "pulse-remote", "config.toml")
+	os.MkdirAll(filepath.Dir(path), 0o700)
+	os.WriteFile(path, []byte(`
+port = 9000
+max_volume = 100
+poll_interval = "10s"
+allowed_networks = ["192.168.1.0/24"]
+
+[auth]
+mode = "token"
+token = "from-file"
+`), 0o600)
+
+	cfg, opts, err := Load(
+		[]string{"--port", "9002", "--tls-enabled", "--tls-cert-file", "cert.pem", "--tls-key-file", "key.pem"},
+		env(map[string]string{
+			"HOME":                    home,
+			"PULSE_REMOTE_PORT":       "9001",
+			"PULSE_REMOTE_AUTH_TOKEN": "from-env",
+			"DEBUG":                   "trace",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if opts.ConfigFile != path {
+		t.Errorf("Expected config file %s, got %q", path, opts.ConfigFile)
+	}
+	if cfg.Port != 9002 {
+		t.Errorf("Expected port from flag 9002, got %d", cfg.Port)
+	}
+	if cfg.Auth.Token != "from-env" || cfg.Auth.Mode != AuthToken {
+		t.Errorf("Expected token mode with token from env, got %+v", cfg.Auth)
+	}
+	if cfg.MaxVolume != 100 || cfg.PollInterval.Duration != 10*time.Second {
+		t.Errorf("Expected max_volume and poll_interval from file, got %v %v", cfg.MaxVolume, cfg.PollInterval)
+	}
+	if !reflect.DeepEqual(cfg.AllowedNetworks, []string{"192.168.1.0/24"}) {
+		t.Errorf("Expected allowed_networks from file, got %v", cfg.AllowedNetworks)
+	}
+	if !cfg.TLS.Enabled || cfg.TLS.CertFile != "cert.pem" {
+		t.Errorf("Expected TLS from flags, got %+v", cfg.TLS)
+	}
+	if cfg.LogLevel != "trace" {
+		t.Errorf("Expected log level from legacy DEBUG, got %q", cfg.LogLevel)
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+host: 127.0.0.1
+poll_interval: 2s
+allowed_networks:
+  - 10.0.0.0/8
+  - 192.168.1.5
+tls:
+  enabled: false
+`)
+
+	cfg, _, err := Load([]string{"--config", path}, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Host != "127.0.0.1" || cfg.PollInterval.Duration != 2*time.Second || len(cfg.AllowedNetworks) != 2 {
+		t.Errorf("Wrong config from YAML %+v", cfg)
+	}
+
+	networks, err := cfg.Networks()
+	if err != nil || networks[1].String() != "192.168.1.5/32" {
+		t.Errorf("Expected single address as /32 network, got %v %v", networks, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		Name string
+		Args []string
+		Env  map[string]string
+		Want string
+	}{
+		{"UnknownTOMLKey", []string{"--config", "prot = 1"}, nil, "unknown keys"},
+		{"MissingExplicitFile", []string{"--config", "/nonexistent/config.toml"}, nil, "no such file"},
+		{"PortNotNumber", nil, map[string]string{"PULSE_REMOTE_PORT": "http"}, "PULSE_REMOTE_PORT"},
+		{"Validation", []string{"--port", "70000", "--max-volume", "1000", "--auth-mode", "token"}, nil, "port: 70000"},
+		{"ValidationAll", []string{"--port", "70000", "--max-volume", "1000", "--auth-mode", "token"}, nil, "auth: token is required"},
+		{"Network", []string{"--allowed-networks", "192.168.1.0/33"}, nil, "allowed_networks"},
+		{"Origin", []string{"--allowed-origins", "https://ok.example,audio.example.com"}, nil, "allowed_origins"},
+		{"HostAndInterface", []string{"--host", "127.0.0.1", "--interface", "lo"}, nil, "can't be used together"},
+		{"TLSCertWithoutKey", []string{"--tls-enabled", "--tls-cert-file", "cert.pem"}, nil, "cert_file and key_file"},
+		{"TLSGeneratedWithoutDataDir", []string{"--tls-enabled"}, nil, "data_dir is required"},
+		{"TLSRedirectWithoutTLS", []string{"--tls-redirect-port", "8080"}, nil, "needs tls enabled"},
+		{"TLSRedirectSamePort", []string{"--tls-enabled", "--tls-redirect-port", "8448"}, nil, "same as port"},
+		{"LogLevel", []string{"--log-level", "loud"}, nil, "log_level"},
+		{"PongBeforePing", []string{"--ping-interval", "30s", "--pong-timeout", "20s"}, nil, "pong_timeout"},
+		{"ShutdownTimeout", []string{"--shutdown-timeout", "100ms"}, nil, "shutdown_timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := tt.Args
+			if tt.Name == "UnknownTOMLKey" {
+				args = []string{"--config", writeFile(t, "config.toml", args[1])}
+			}
+
+			_, _, err := Load(args, env(tt.Env))
+			if err == nil || !strings.Contains(err.Error(), tt.Want) {
+				t.Errorf("Expected error with %q, got %v", tt.Want, err)
+			}
+		})
+	}
+
+	if _, _, err := Load([]string{"--nope"}, env(nil)); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage for unknown flag, got %v", err)
+	}
+}
+
+func TestParseOrigin(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Origin string
+		Want   string
+		Err    bool
+	}{
+		{"HTTPS", "https://audio.example.com", "https://audio.example.com", false},
+		{"UpperCase", "HTTPS://Audio.Example.com", "https://audio.example.com", false},
+		{"TrailingSlash", "https://audio.example.com/", "https://audio.example.com", false},
+		{"Port", "http://192.168.1.10:5173", "http://192.168.1.10:5173", false},
+		{"DefaultPortHTTP", "http://audio.example.com:80", "http://audio.example.com", false},
+		{"DefaultPortHTTPS", "https://audio.example.com:443", "https://audio.example.com", false},
+		{"IPv6DefaultPort", "https://[fd00::1]:443", "https://[fd00::1]", false},
+		{"NoScheme", "audio.example.com", "", true},
+		{"WebSocketScheme", "wss://audio.example.com", "", true},
+		{"Path", "https://audio.example.com/app", "", true},
+		{"Query", "https://audio.example.com?x=1", "", true},
+		{"User", "https://me@audio.example.com", "", true},
+		{"Wildcard", "*", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := ParseOrigin(tt.Origin)
+			if (err != nil) != tt.Err || got != tt.Want {
+				t.Errorf("ParseOrigin(%q) = %q, %v, want %q, error %v", tt.Origin, got, err, tt.Want, tt.Err)
+			}
+		})
+	}
+}
+
+func TestInterfaceHost(t *testing.T) {
+	ipNet := func(s string) net.Addr { return &net.IPNet{IP: net.ParseIP(s)} }
+
+	tests := []struct {
+		name  string
+		addrs []net.Addr
+		want  string
+	}{
+		{"IPv4First", []net.Addr{ipNet("fd00::5"), ipNet("192.168.1.10")}, "192.168.1.10"},
+		{"IPv6", []net.Addr{ipNet("fe80::1"), ipNet("fd00::5")}, "fd00::5"},
+		{"LinkLocalOnly", []net.Addr{ipNet("fe80::1")}, ""},
+		{"NoAddress", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interfaceHost(tt.addrs); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrintHidesSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Auth = Auth{Mode: AuthPassword, Password: "hunter2"}
+
+	var out bytes.Buffer
+	if err := Print(&out, cfg); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), `poll_interval = "5s"`) {
+		t.Errorf("Unexpected printed config:\n%s", out.String())
+	}
+}
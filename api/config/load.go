@@ -0,0 +1,303 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/undg/pulse-remote/api/logger"
+)
+
+const envPrefix = "PULSE_REMOTE_"
+
+// ErrUsage wraps wrong flags, flag package already printed usage for them
+var ErrUsage = errors.New("usage")
+
+// Options are flags that are not part of Config
+type Options struct {
+	// Config file that was read, empty when there was none
+	ConfigFile  string
+	PrintConfig bool
+}
+
+// setting is a single config key, the same for env var and flag
+type setting struct {
+	key    string
+	usage  string
+	isBool bool
+	set    func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{key: "host", usage: "listen address, empty for all interfaces", set: func(c *Config, v string) error {
+		c.Host = v
+		return nil
+	}},
+	{key: "port", usage: "listen port", set: func(c *Config, v string) (err error) {
+		c.Port, err = strconv.Atoi(v)
+		return err
+	}},
+	{key: "interface", usage: "network interface to listen on instead of host, fe. wlan0", set: func(c *Config, v string) error {
+		c.Interface = v
+		return nil
+	}},
+	{key: "allowed_networks", usage: "comma separated networks allowed to connect, fe. 192.168.1.0/24,10.0.0.5", set: func(c *Config, v string) error {
+		c.AllowedNetworks = splitList(v)
+		return nil
+	}},
+	{key: "allowed_origins", usage: "comma separated websites allowed to open WebSocket besides the server, fe. https://audio.example.com", set: func(c *Config, v string) error {
+		c.AllowedOrigins = splitList(v)
+		return nil
+	}},
+	{key: "poll_interval", usage: "status is broadcasted at least this often, fe. 5s", set: func(c *Config, v string) error {
+		return c.PollInterval.UnmarshalText([]byte(v))
+	}},
+	{key: "ping_interval", usage: "WebSocket clients are pinged this often, fe. 20s", set: func(c *Config, v string) error {
+		return c.PingInterval.UnmarshalText([]byte(v))
+	}},
+	{key: "pong_timeout", usage: "WebSocket client without pong or message this long is dropped, fe. 45s", set: func(c *Config, v string) error {
+		return c.PongTimeout.UnmarshalText([]byte(v))
+	}},
+	{key: "write_timeout", usage: "WebSocket client that can't take a message this long is dropped, fe. 10s", set: func(c *Config, v string) error {
+		return c.WriteTimeout.UnmarshalText([]byte(v))
+	}},
+	{key: "shutdown_timeout", usage: "requests in flight get this long to finish on SIGINT or SIGTERM, fe. 10s", set: func(c *Config, v string) error {
+		return c.ShutdownTimeout.UnmarshalText([]byte(v))
+	}},
+	{key: "max_volume", usage: "max volume of any channel in percent", set: func(c *Config, v string) (err error) {
+		c.MaxVolume, err = strconv.ParseFloat(v, 64)
+		return err
+	}},
+	{key: "log_level", usage: "TRACE, DEBUG, INFO, WARN, ERR, FATAL or PANIC", set: func(c *Config, v string) error {
+		c.LogLevel = v
+		return nil
+	}},
+	{key: "data_dir", usage: "paired devices and other state, default $XDG_DATA_HOME/pulse-remote", set: func(c *Config, v string) error {
+		c.DataDir = v
+		return nil
+	}},
+	{key: "tls.enabled", usage: "serve HTTPS and WSS", isBool: true, set: func(c *Config, v string) (err error) {
+		c.TLS.Enabled, err = strconv.ParseBool(v)
+		return err
+	}},
+	{key: "tls.cert_file", usage: "PEM certificate for TLS, generated when empty", set: func(c *Config, v string) error {
+		c.TLS.CertFile = v
+		return nil
+	}},
+	{key: "tls.key_file", usage: "PEM private key for TLS, generated when empty", set: func(c *Config, v string) error {
+		c.TLS.KeyFile = v
+		return nil
+	}},
+	{key: "tls.redirect_port", usage: "plain HTTP port redirecting to HTTPS, 0 disables it", set: func(c *Config, v string) (err error) {
+		c.TLS.RedirectPort, err = strconv.Atoi(v)
+		return err
+	}},
+	{key: "auth.mode", usage: "none, token or password", set: func(c *Config, v string) error {
+		c.Auth.Mode = v
+		return nil
+	}},
+	{key: "auth.token", usage: "token required from clients in token mode", set: func(c *Config, v string) error {
+		c.Auth.Token = v
+		return nil
+	}},
+	{key: "auth.password", usage: "password required from clients in password mode", set: func(c *Config, v string) error {
+		c.Auth.Password = v
+		return nil
+	}},
+	{key: "auth.default_role", usage: "viewer, operator or admin, role of shared token, logged in and paired devices", set: func(c *Config, v string) error {
+		c.Auth.DefaultRole = v
+		return nil
+	}},
+	{key: "auth.allow_loopback", usage: "clients on the same machine don't have to log in", isBool: true, set: func(c *Config, v string) (err error) {
+		c.Auth.AllowLoopback, err = strconv.ParseBool(v)
+		return err
+	}},
+}
+
+// splitList reads comma separated list, empty for empty string
+func splitList(v string) []string {
+	list := []string{}
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+func (s setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+// Load reads config for server started with args, without program name. lookupEnv is os.LookupEnv outside of tests.
+// Returned Config is validated.
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, Options, error) {
+	var opts Options
+	cfg := Default()
+
+	// Flags are parsed first to know config file, but applied last
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var flagValues []flagValue
+
+	fs := flag.NewFlagSet("pulse-remote-server", flag.ContinueOnError)
+	configFile := fs.String("config", "", "config file, TOML or YAML (env "+envPrefix+"CONFIG)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print effective config as TOML and exit")
+
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env())
+		add := func(v string) error {
+			flagValues = append(flagValues, flagValue{s, v})
+			return nil
+		}
+		if s.isBool {
+			fs.BoolFunc(s.flag(), usage, func(v string) error { return add(v) })
+		} else {
+			fs.Func(s.flag(), usage, add)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return cfg, opts, err
+		}
+		return cfg, opts, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+	if fs.NArg() > 0 {
+		return cfg, opts, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	path, required := *configFile, true
+	if path == "" {
+		path, required = lookupEnv(envPrefix + "CONFIG")
+	}
+	if path == "" {
+		path = findConfigFile(lookupEnv)
+	}
+
+	if path != "" {
+		err := readFile(&cfg, path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !required:
+		case err != nil:
+			return cfg, opts, fmt.Errorf("config file %s: %w", path, err)
+		default:
+			opts.ConfigFile = path
+		}
+	}
+
+	// Legacy DEBUG env var, PULSE_REMOTE_LOG_LEVEL wins. Unknown values were always ignored.
+	if v, ok := lookupEnv("DEBUG"); ok {
+		if _, err := logger.ParseLevel(v); err == nil {
+			cfg.LogLevel = v
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env()); ok {
+			if err := s.set(&cfg, v); err != nil {
+				return cfg, opts, fmt.Errorf("env %s: %w", s.env(), err)
+			}
+		}
+	}
+
+	for _, f := range flagValues {
+		if err := f.setting.set(&cfg, f.value); err != nil {
+			return cfg, opts, fmt.Errorf("flag --%s: %w", f.setting.flag(), err)
+		}
+	}
+
+	if cfg.DataDir == "" {
+		cfg.DataDir = dataDir(lookupEnv)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, opts, fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	return cfg, opts, nil
+}
+
+// dataDir is $XDG_DATA_HOME/pulse-remote, or ~/.local/share/pulse-remote
+func dataDir(lookupEnv func(string) (string, bool)) string {
+	dir, _ := lookupEnv("XDG_DATA_HOME")
+	if dir == "" {
+		home, _ := lookupEnv("HOME")
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "pulse-remote")
+}
+
+// findConfigFile looks for config.toml, config.yaml or config.yml in $XDG_CONFIG_HOME/pulse-remote
+func findConfigFile(lookupEnv func(string) (string, bool)) string {
+	dir, _ := lookupEnv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := lookupEnv("HOME")
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml"} {
+		path := filepath.Join(dir, "pulse-remote", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// readFile decodes TOML or YAML by extension. Unknown keys are errors, they are most likely typos.
+func readFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(path) {
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown keys %v", undecoded)
+		}
+
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown format, use .toml, .yaml or .yml")
+	}
+
+	return nil
+}
+
+// Print writes config as TOML, secrets are hidden
+func Print(w io.Writer, cfg Config) error {
+	return toml.NewEncoder(w).Encode(cfg.Redacted())
+}
@@ -2,6 +2,7 @@ package json
 
 import (
 	"encoding/json"
+	"errors"
 )
 
 type Action string
@@ -17,23 +18,40 @@ const (
 	ActionSetSinkVolume  Action = "SetSinkVolume"
 	ActionSetSinkMuted   Action = "SetSinkMuted"
 	ActionSetDefaultSink Action = "SetDefaultSink"
+	ActionSetSinkPort    Action = "SetSinkPort"
+	// Change volume by signed delta, fe. 5 or -5, up to server's max volume
+	ActionChangeSinkVolume Action = "ChangeSinkVolume"
+	// Left/right balance, average volume stays the same
+	ActionSetSinkBalance Action = "SetSinkBalance"
+	// Volume of every channel separately, fe. [front-left, front-right]
+	ActionSetSinkChannelVolumes Action = "SetSinkChannelVolumes"
 
 	// Apps playing audio
-	ActionSetSinkInputVolume Action = "SetSinkInputVolume"
-	ActionSetSinkInputMuted  Action = "SetSinkInputMuted"
+	ActionSetSinkInputVolume    Action = "SetSinkInputVolume"
+	ActionChangeSinkInputVolume Action = "ChangeSinkInputVolume"
+	ActionSetSinkInputMuted     Action = "SetSinkInputMuted"
 	// Move App to different SINK
 	ActionMoveSinkInput Action = "MoveSinkInput"
 
 	// SOURCES, e.g. Microphones
-	ActionSetSourceVolume  Action = "SetSourceVolume"
-	ActionSetSourceMuted   Action = "SetSourceMuted"
-	ActionSetDefaultSource Action = "SetDefaultSource"
+	ActionSetSourceVolume    Action = "SetSourceVolume"
+	ActionChangeSourceVolume Action = "ChangeSourceVolume"
+	ActionSetSourceMuted     Action = "SetSourceMuted"
+	ActionSetDefaultSource   Action = "SetDefaultSource"
+	ActionSetSourcePort      Action = "SetSourcePort"
 
 	// Apps active access to microphones
 	ActionSetSourceInputVolume Action = "SetSourceInputVolume"
 	ActionSetSourceInputMuted  Action = "SetSourceInputMuted"
 	// Move App to different SOURCE
 	ActionMoveSourceOutput Action = "MoveSourceOutput"
+
+	// CARDS, e.g. switch Bluetooth headset between A2DP and HSP/HFP
+	ActionSetCardProfile Action = "SetCardProfile"
+
+	// Topics of status events sent to this WebSocket connection, without subscription it gets everything
+	ActionSubscribe   Action = "Subscribe"
+	ActionUnsubscribe Action = "Unsubscribe"
 )
 
 var AvailableCommands = []Action{
@@ -47,36 +65,82 @@ var AvailableCommands = []Action{
 	ActionSetSinkVolume,
 	ActionSetSinkMuted,
 	ActionSetDefaultSink,
+	ActionSetSinkPort,
+	// Change volume by signed delta, fe. 5 or -5, up to server's max volume
+	ActionChangeSinkVolume,
+	// Left/right balance, average volume stays the same
+	ActionSetSinkBalance,
+	// Volume of every channel separately, fe. [front-left, front-right]
+	ActionSetSinkChannelVolumes,
 
 	// Apps playing audio
 	ActionSetSinkInputVolume,
+	ActionChangeSinkInputVolume,
 	ActionSetSinkInputMuted,
 	// Move App to different SINK
 	ActionMoveSinkInput,
 
 	// SOURCES, e.g. Microphones
 	ActionSetSourceVolume,
+	ActionChangeSourceVolume,
 	ActionSetSourceMuted,
 	ActionSetDefaultSource,
+	ActionSetSourcePort,
 
 	// Apps active access to microphones
 	ActionSetSourceInputVolume,
 	ActionSetSourceInputMuted,
 	// Move App to different SOURCE
 	ActionMoveSourceOutput,
-}
 
-// @TODO (undg) 2025-02-10: generate enum's. Check https://github.com/danielgtaylor/huma
+	// CARDS, e.g. switch Bluetooth headset between A2DP and HSP/HFP
+	ActionSetCardProfile,
+
+	// Topics of status events sent to this WebSocket connection, without subscription it gets everything
+	ActionSubscribe,
+	ActionUnsubscribe,
+}
 
 // Message is an request from the client
 type Message struct {
-	// Actions listed in availableCommands slice
-	Action Action `json:"action" doc:"Action to perform fe. GetVolume, SetVolume, SetMute..." enum:"GetStatus,GetBuildInfo,SetSinkVolume,SetSinkMuted,SetDefaultSink,SetSinkInputVolume,SetSinkInputMuted,MoveSinkInput,SetSourceVolume,SetSourceMuted,SetDefaultSource,SetSourceInputVolume,SetSourceInputMuted,MoveSourceOutput"`
-	// Paylod send with Set* actions if necessary
+	// Actions listed in AvailableCommands slice, enum of schema is generated from it
+	Action Action `json:"action" doc:"Action to perform fe. GetVolume, SetVolume, SetMute..."`
+	// Paylod send with Set* actions if necessary, typed per action, fe. VolumePayload
 	Payload interface{} `json:"payload,omitempty" doc:"Paylod send with Set* actions if necessary"`
+	// Optional, chosen by client and echoed in reply to match it with request. String or number, kept as received.
+	ID json.RawMessage `json:"id,omitempty" doc:"Optional request ID, echoed in reply to this message"`
 }
 
+// ValidID checks that Message.ID is string or number, the way IDSchema describes it. Missing ID is valid.
+func ValidID(id json.RawMessage) error {
+	if len(id) == 0 {
+		return nil
+	}
+	switch c := id[0]; {
+	case c == '"', c == '-', c >= '0' && c <= '9':
+		return nil
+	}
+	return errors.New("id is not string or number")
+}
+
+// ResponseType tells replies to client's own messages apart from events sent by server on its own
+type ResponseType string
+
+const (
+	// Response to client's Message, with its ID
+	TypeReply ResponseType = "reply"
+	// Sent by server without request, fe. status on connect and broadcast after change
+	TypeEvent ResponseType = "event"
+)
+
+// EventStatusDelta is action of events with pactl.StatusDelta, sent instead of full status to clients connected with ?updates=delta
+const EventStatusDelta = "StatusDelta"
+
 type Response struct {
+	// Reply or event
+	Type ResponseType `json:"type" enum:"reply,event" doc:"reply to client's message or event sent by server"`
+	// ID of Message this is reply to, exactly as client sent it. Empty for events and messages without ID.
+	ID json.RawMessage `json:"id,omitempty" doc:"ID of message this is reply to"`
 	// Action performed by API
 	Action string `json:"action" doc:"Action performed by API"`
 	// Status code
@@ -85,14 +149,20 @@ type Response struct {
 	Payload interface{} `json:"payload" doc:"Response payload"`
 	// Error description if any
 	Error string `json:"error,omitempty" doc:"Error description if any"`
+	// Version of status, grows by one with every broadcast. Gap in it means missed update, GetStatus resyncs.
+	Seq uint64 `json:"seq,omitempty" doc:"Version of status in payload, grows by one with every broadcast"`
 }
 
 const (
-	StatusSuccess          int16 = 4000
-	StatusError            int16 = 4001
-	StatusActionError      int16 = 4002
+	StatusSuccess int16 = 4000
+	StatusError   int16 = 4001
+	// Unknown action, or sound server rejected it, fe. sink doesn't exist
+	StatusActionError int16 = 4002
+	// Payload field missing, of wrong type or out of range
 	StatusPayloadError     int16 = 4003
 	StatusErrorInvalidJSON int16 = 4004
+	// Client's role doesn't allow the action, fe. viewer changing volume
+	StatusUnauthorized int16 = 4005
 )
 
 func (r Response) MarshalJSON() ([]byte, error) {
@@ -101,6 +171,14 @@ func (r Response) MarshalJSON() ([]byte, error) {
 		"status": r.Status,
 	}
 
+	if r.Type != "" {
+		data["type"] = r.Type
+	}
+
+	if len(r.ID) != 0 {
+		data["id"] = r.ID
+	}
+
 	if r.Payload != nil {
 		data["payload"] = r.Payload
 	}
@@ -109,5 +187,9 @@ func (r Response) MarshalJSON() ([]byte, error) {
 		data["error"] = r.Error
 	}
 
+	if r.Seq != 0 {
+		data["seq"] = r.Seq
+	}
+
 	return json.Marshal(data)
 }
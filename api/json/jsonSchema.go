@@ -11,47 +11,73 @@ import (
 	"github.com/undg/pulse-remote/api/pactl"
 )
 
-func serveSchemaJSON(w http.ResponseWriter, t reflect.Type) {
-	w.Header().Set("Content-Type", "application/json")
-
-	s, err := schema.Generate(t)
+func serveSchemaJSON(w http.ResponseWriter, generate func() (*schema.Schema, error)) {
+	s, err := generate()
 	if err != nil {
 		logger.Error().Err(err).Msg("Schema error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
 	b, err := json.Marshal(s)
 	if err != nil {
 		logger.Error().Err(err).Msg("Internal Server Error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprint(w, string(b))
 }
 
-func serveRestJSON(w http.ResponseWriter, restJSON interface{}) {
+func generateSchema(v any) func() (*schema.Schema, error) {
+	return func() (*schema.Schema, error) {
+		return schema.Generate(reflect.TypeOf(v))
+	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
+// IDSchema is schema of Message.ID and Response.ID, string or number. Generated one would be base64 string of RawMessage.
+func IDSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Description: description,
+		OneOf:       []*schema.Schema{{Type: "string"}, {Type: "number"}},
+	}
+}
 
-	if err := json.NewEncoder(w).Encode(restJSON); err != nil {
-		logger.Error().Err(err).Msg("json.NewEncoder(w).Encode(restJSON)")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// MessageSchema is schema of Message, with action enum generated from AvailableCommands
+func MessageSchema() (*schema.Schema, error) {
+	s, err := schema.Generate(reflect.TypeOf(Message{}))
+	if err != nil {
+		return nil, err
+	}
+	s.Properties["id"] = IDSchema(s.Properties["id"].Description)
+
+	enum := make([]interface{}, len(AvailableCommands))
+	for i, action := range AvailableCommands {
+		enum[i] = string(action)
+	}
+	s.Properties["action"].Enum = enum
+	return s, nil
+}
+
+// ResponseSchema is schema of Response with its ID as sent by client
+func ResponseSchema() (*schema.Schema, error) {
+	s, err := schema.Generate(reflect.TypeOf(Response{}))
+	if err != nil {
+		return nil, err
 	}
+	s.Properties["id"] = IDSchema(s.Properties["id"].Description)
+	return s, nil
 }
 
 func ServeStatusSchemaJSON(w http.ResponseWriter, r *http.Request) {
-	serveSchemaJSON(w, reflect.TypeOf(pactl.Status{}))
+	serveSchemaJSON(w, generateSchema(pactl.Status{}))
 }
 
 func ServeMessageSchemaJSON(w http.ResponseWriter, r *http.Request) {
-	serveSchemaJSON(w, reflect.TypeOf(Message{}))
+	serveSchemaJSON(w, MessageSchema)
 }
 
 func ServeResponseSchemaJSON(w http.ResponseWriter, r *http.Request) {
-	serveSchemaJSON(w, reflect.TypeOf(Response{}))
-}
-
-func ServeStatusRestJSON(w http.ResponseWriter, r *http.Request) {
-	serveRestJSON(w, pactl.GetStatus())
+	serveSchemaJSON(w, ResponseSchema)
 }
@@ -30,3 +30,20 @@ func TestServeStatusSchemaJSON(t *testing.T) {
 		t.Errorf("[Err] Response not valid JSON: %v", err)
 	}
 }
+
+func TestMessageSchema(t *testing.T) {
+	s, err := MessageSchema()
+	if err != nil {
+		t.Fatalf("[Err] MessageSchema: %v", err)
+	}
+
+	enum := s.Properties["action"].Enum
+	if len(enum) != len(AvailableCommands) {
+		t.Fatalf("[Err] Expected %d actions in enum, got %v", len(AvailableCommands), enum)
+	}
+	for i, action := range AvailableCommands {
+		if enum[i] != string(action) {
+			t.Errorf("[Err] Expected %s in enum at %d, got %v", action, i, enum[i])
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// Payloads of actions. Fields without omitempty are required, minLength, minimum and maximum tags
+// are checked on decode and end up in generated schemas. Validate checks what tags can't, fe. volume cap.
+
+type VolumePayload struct {
+	Name   string  `json:"name" minLength:"1" doc:"Sink or source name, fe. alsa_output.speakers"`
+	Volume float64 `json:"volume" minimum:"0" doc:"Volume in percent, up to server's max volume"`
+}
+
+func (p VolumePayload) Validate() error {
+	return validVolume(p.Volume)
+}
+
+type VolumeChangePayload struct {
+	Name  string  `json:"name" minLength:"1" doc:"Sink or source name, fe. alsa_output.speakers"`
+	Delta float64 `json:"delta" doc:"Signed change of volume in percent, fe. 5 or -5"`
+}
+
+type BalancePayload struct {
+	Name    string  `json:"name" minLength:"1" doc:"Sink name, fe. alsa_output.speakers"`
+	Balance float64 `json:"balance" minimum:"-1" maximum:"1" doc:"Left/right balance from -1 (left only) through 0 (center) to 1 (right only)"`
+}
+
+type ChannelVolumesPayload struct {
+	Name    string    `json:"name" minLength:"1" doc:"Sink name, fe. alsa_output.speakers"`
+	Volumes []float64 `json:"volumes" doc:"Volume of every channel in percent, in order of sink channels"`
+}
+
+func (p ChannelVolumesPayload) Validate() error {
+	for _, v := range p.Volumes {
+		if err := validVolume(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type MutedPayload struct {
+	Name  string `json:"name" minLength:"1" doc:"Sink or source name, fe. alsa_output.speakers"`
+	Muted bool   `json:"muted" doc:"true mutes, false unmutes"`
+}
+
+type DefaultPayload struct {
+	Name string `json:"name" minLength:"1" doc:"Sink or source name, fe. alsa_output.speakers"`
+}
+
+type PortPayload struct {
+	Name string `json:"name" minLength:"1" doc:"Sink or source name, fe. alsa_output.speakers"`
+	Port string `json:"port" minLength:"1" doc:"Port name, fe. analog-output-headphones"`
+}
+
+type AppVolumePayload struct {
+	ID     uint32  `json:"id" doc:"Sink input or source output ID"`
+	Volume float64 `json:"volume" minimum:"0" doc:"Volume in percent, up to server's max volume"`
+}
+
+func (p AppVolumePayload) Validate() error {
+	return validVolume(p.Volume)
+}
+
+type AppVolumeChangePayload struct {
+	ID    uint32  `json:"id" doc:"Sink input ID"`
+	Delta float64 `json:"delta" doc:"Signed change of volume in percent, fe. 5 or -5"`
+}
+
+type AppMutedPayload struct {
+	ID    uint32 `json:"id" doc:"Sink input or source output ID"`
+	Muted bool   `json:"muted" doc:"true mutes, false unmutes"`
+}
+
+type MoveSinkInputPayload struct {
+	ID   uint32 `json:"id" doc:"Sink input ID"`
+	Name string `json:"name" minLength:"1" doc:"Sink name to move to, fe. bluez_output.headset"`
+}
+
+type MoveSourceOutputPayload struct {
+	OutputID   uint32 `json:"outputId" doc:"Source output ID"`
+	SourceName string `json:"sourceName" minLength:"1" doc:"Source name to move to, fe. alsa_input.mic"`
+}
+
+type CardProfilePayload struct {
+	Name    string `json:"name" minLength:"1" doc:"Card name, fe. bluez_card.headset"`
+	Profile string `json:"profile" minLength:"1" doc:"Profile name, fe. headset-head-unit"`
+}
+
+type TopicsPayload struct {
+	Topics []string `json:"topics" doc:"sinks, sinkInputs, sources, sourceOutputs, cards, or one device, fe. sinks:alsa_output.speakers. There is no levels topic, server has no level meters."`
+}
+
+func (p TopicsPayload) Validate() error {
+	if len(p.Topics) == 0 {
+		return errors.New("no topics")
+	}
+	for _, t := range p.Topics {
+		if err := ValidTopic(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Topics are lists of Status, every one of them can be narrowed to one device with its name, fe. sinks:alsa_output.speakers.
+// Apps have no stable names, so they go whole.
+var (
+	TopicLists   = []string{"sinks", "sinkInputs", "sources", "sourceOutputs", "cards"}
+	TopicDevices = []string{"sinks", "sources", "cards"}
+)
+
+// TopicLevels would be peak meters of devices. Server doesn't record audio to measure them, so there is nothing to subscribe to.
+const TopicLevels = "levels"
+
+func ValidTopic(topic string) error {
+	if topic == TopicLevels {
+		return fmt.Errorf("topic %q is not available, server has no level meters", topic)
+	}
+	list, name, named := strings.Cut(topic, ":")
+	if !named && slices.Contains(TopicLists, list) {
+		return nil
+	}
+	if named && name != "" && slices.Contains(TopicDevices, list) {
+		return nil
+	}
+	return fmt.Errorf("topic %q is not one of %s, or one of %s with device name, fe. sinks:alsa_output.speakers",
+		topic, strings.Join(TopicLists, ", "), strings.Join(TopicDevices, ", "))
+}
+
+// validVolume is between 0 and server's max volume, in percent
+func validVolume(v float64) error {
+	if v < 0 || v > pactl.MaxVolume {
+		return fmt.Errorf("volume %v is not between 0 and %v", v, pactl.MaxVolume)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -12,11 +13,20 @@ var logger zerolog.Logger
 
 // logger based on zerolog
 func init() {
-
 	debug := strings.ToUpper(os.Getenv("DEBUG"))
 
-	level := zerolog.InfoLevel
+	level, err := ParseLevel(debug)
+	if err != nil {
+		level = zerolog.InfoLevel
+		debug = "INFO"
+	}
 
+	setLogger(level)
+	DebugEnv = debug
+}
+
+// ParseLevel reads level in the same format as DEBUG env var, fe. "TRACE" or "-1"
+func ParseLevel(name string) (zerolog.Level, error) {
 	// zerolog allows for logging at the following levels (from highest to lowest):
 	//
 	// panic (zerolog.PanicLevel, 5)
@@ -26,26 +36,38 @@ func init() {
 	// info (zerolog.InfoLevel, 1)
 	// debug (zerolog.DebugLevel, 0)
 	// trace (zerolog.TraceLevel, -1)
-	switch debug {
+	switch strings.ToUpper(name) {
 	case "TRACE", "-1":
-		level = zerolog.TraceLevel
+		return zerolog.TraceLevel, nil
 	case "DEBUG", "0":
-		level = zerolog.DebugLevel
+		return zerolog.DebugLevel, nil
 	case "INFO", "1":
-		level = zerolog.InfoLevel
+		return zerolog.InfoLevel, nil
 	case "WARN", "2":
-		level = zerolog.WarnLevel
+		return zerolog.WarnLevel, nil
 	case "ERR", "3":
-		level = zerolog.ErrorLevel
+		return zerolog.ErrorLevel, nil
 	case "FATAL", "4":
-		level = zerolog.FatalLevel
+		return zerolog.FatalLevel, nil
 	case "PANIC", "5":
-		level = zerolog.PanicLevel
-	default:
-		level = zerolog.InfoLevel
-		debug = "INFO"
+		return zerolog.PanicLevel, nil
+	}
+
+	return zerolog.InfoLevel, fmt.Errorf("unknown log level %q, use TRACE, DEBUG, INFO, WARN, ERR, FATAL or PANIC", name)
+}
+
+// SetLevel changes log level, fe. from config file. Call it before any goroutine logs.
+func SetLevel(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
 	}
 
+	setLogger(level)
+	return nil
+}
+
+func setLogger(level zerolog.Level) {
 	logger = zerolog.New(zerolog.ConsoleWriter{
 		Out:        os.Stderr,
 		TimeFormat: time.RFC3339,
@@ -64,7 +86,6 @@ func init() {
 	Fatal = logger.Fatal
 	Panic = logger.Panic
 	GetLevel = logger.GetLevel
-	DebugEnv = debug
 }
 
 var (
@@ -0,0 +1,211 @@
+package pactl
+
+import (
+	"fmt"
+	"math"
+)
+
+// Channel positions counted as left or right side, same as pa_channel_position_is_left/right()
+var (
+	leftChannels = map[string]bool{
+		"front-left": true, "rear-left": true, "front-left-of-center": true,
+		"side-left": true, "top-front-left": true, "top-rear-left": true,
+	}
+	rightChannels = map[string]bool{
+		"front-right": true, "rear-right": true, "front-right-of-center": true,
+		"side-right": true, "top-front-right": true, "top-rear-right": true,
+	}
+)
+
+// MaxVolume caps volume of every channel, in percent. Remote clients can't go above it.
+var MaxVolume float64 = 150
+
+// Volumes below are in percent, but not rounded. Rounding happens once, in channelsFrom().
+
+func averagePercent(percents []float64) float64 {
+	if len(percents) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, p := range percents {
+		sum += p
+	}
+	return sum / float64(len(percents))
+}
+
+// sidesPercent returns average volume of left and right channels
+func sidesPercent(names []string, percents []float64) (left float64, right float64, ok bool) {
+	var nLeft, nRight int
+	for i, p := range percents {
+		if i >= len(names) {
+			break
+		}
+		if leftChannels[names[i]] {
+			left += p
+			nLeft++
+		}
+		if rightChannels[names[i]] {
+			right += p
+			nRight++
+		}
+	}
+
+	if nLeft == 0 || nRight == 0 {
+		return 0, 0, false
+	}
+	return left / float64(nLeft), right / float64(nRight), true
+}
+
+// balanceOf works like pa_cvolume_get_balance(), -1 left only, 0 center, 1 right only.
+// Channel maps without both sides (fe. mono) are always centered.
+func balanceOf(names []string, percents []float64) float64 {
+	left, right, ok := sidesPercent(names, percents)
+	if !ok || left == right {
+		return 0
+	}
+
+	if left > right {
+		return -1 + right/left
+	}
+	return 1 - left/right
+}
+
+// withBalance works like pa_cvolume_set_balance(), but keeps average volume instead of the loudest channel
+func withBalance(names []string, percents []float64, balance float64) []float64 {
+	balance = max(-1, min(1, balance))
+
+	left, right, ok := sidesPercent(names, percents)
+	if !ok {
+		return percents
+	}
+
+	loudest := max(left, right)
+	newLeft, newRight := loudest, loudest
+	if balance < 0 {
+		newRight = (1 + balance) * loudest
+	} else {
+		newLeft = (1 - balance) * loudest
+	}
+
+	result := make([]float64, len(percents))
+	for i, p := range percents {
+		result[i] = p
+		if i >= len(names) {
+			continue
+		}
+
+		switch {
+		case leftChannels[names[i]]:
+			result[i] = scaleSide(p, left, newLeft)
+		case rightChannels[names[i]]:
+			result[i] = scaleSide(p, right, newRight)
+		}
+	}
+
+	return withAverage(result, averagePercent(percents))
+}
+
+func scaleSide(p float64, from float64, to float64) float64 {
+	if from == 0 {
+		return to
+	}
+	return p * to / from
+}
+
+// withAverage scales all channels to new average volume, keeping balance between them
+func withAverage(percents []float64, average float64) []float64 {
+	current := averagePercent(percents)
+
+	result := make([]float64, len(percents))
+	for i, p := range percents {
+		if current == 0 {
+			result[i] = average
+		} else {
+			result[i] = p * average / current
+		}
+	}
+
+	return result
+}
+
+// withDelta works like pactl's +N%/-N%, the same change on every channel
+func withDelta(percents []float64, delta float64) []float64 {
+	result := make([]float64, len(percents))
+	for i, p := range percents {
+		result[i] = p + delta
+	}
+	return result
+}
+
+// capped clamps every channel between 0 and MaxVolume
+func capped(percents []float64) []float64 {
+	result := make([]float64, len(percents))
+	for i, p := range percents {
+		result[i] = CapVolume(p)
+	}
+	return result
+}
+
+// channelsFrom rounds raw channel volumes for Status. Volume is average of all channels.
+func channelsFrom(names []string, percents []float64) (channels []ChannelVolume, volume int, balance float64) {
+	channels = make([]ChannelVolume, 0, len(percents))
+	for i, p := range percents {
+		name := fmt.Sprintf("aux%d", i)
+		if i < len(names) {
+			name = names[i]
+		}
+		channels = append(channels, ChannelVolume{Name: name, Volume: int(math.Round(p))})
+	}
+
+	volume = int(math.Round(averagePercent(percents)))
+	balance = math.Round(balanceOf(names, percents)*100) / 100
+
+	return channels, volume, balance
+}
+
+func splitChannels(channels []ChannelVolume) (names []string, percents []float64) {
+	for _, c := range channels {
+		names = append(names, c.Name)
+		percents = append(percents, float64(c.Volume))
+	}
+	return names, percents
+}
+
+func joinChannels(names []string, percents []float64) []ChannelVolume {
+	channels := make([]ChannelVolume, len(percents))
+	for i, p := range capped(percents) {
+		channels[i] = ChannelVolume{Name: names[i], Volume: int(math.Round(p))}
+	}
+	return channels
+}
+
+// WithBalance returns channels with new balance and the same average volume
+func WithBalance(channels []ChannelVolume, balance float64) []ChannelVolume {
+	names, percents := splitChannels(channels)
+	return joinChannels(names, withBalance(names, percents, balance))
+}
+
+// WithVolume returns channels scaled to new average volume, with the same balance
+func WithVolume(channels []ChannelVolume, volume float64) []ChannelVolume {
+	names, percents := splitChannels(channels)
+	return joinChannels(names, withAverage(percents, volume))
+}
+
+// WithDelta returns channels changed by delta, fe. +5 or -5
+func WithDelta(channels []ChannelVolume, delta float64) []ChannelVolume {
+	names, percents := splitChannels(channels)
+	return joinChannels(names, withDelta(percents, delta))
+}
+
+// CapVolume clamps volume between 0 and MaxVolume
+func CapVolume(volume float64) float64 {
+	return max(0, min(MaxVolume, volume))
+}
+
+// VolumeAndBalance derives Volume and Balance fields from channels
+func VolumeAndBalance(channels []ChannelVolume) (volume int, balance float64) {
+	names, percents := splitChannels(channels)
+	_, volume, balance = channelsFrom(names, percents)
+	return volume, balance
+}
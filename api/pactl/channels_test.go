@@ -0,0 +1,124 @@
+package pactl
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+var stereo = []string{"front-left", "front-right"}
+
+func TestBalanceOf(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Names    []string
+		Percents []float64
+		Want     float64
+	}{
+		{"Center", stereo, []float64{50, 50}, 0},
+		{"LeftOnly", stereo, []float64{80, 0}, -1},
+		{"RightOnly", stereo, []float64{0, 80}, 1},
+		{"HalfRight", stereo, []float64{40, 80}, 0.5},
+		{"Mono", []string{"mono"}, []float64{70}, 0},
+		{"Silent", stereo, []float64{0, 0}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			if got := balanceOf(tt.Names, tt.Percents); got != tt.Want {
+				t.Errorf("Expected balance %v, got %v", tt.Want, got)
+			}
+		})
+	}
+}
+
+func TestWithBalance(t *testing.T) {
+	got := withBalance(stereo, []float64{60, 60}, 0.5)
+
+	if avg := averagePercent(got); math.Abs(avg-60) > 1e-9 {
+		t.Errorf("Expected average volume 60 to stay, got %v", avg)
+	}
+	if b := balanceOf(stereo, got); math.Abs(b-0.5) > 1e-9 {
+		t.Errorf("Expected balance 0.5, got %v", b)
+	}
+
+	mono := withBalance([]string{"mono"}, []float64{70}, -1)
+	if !reflect.DeepEqual(mono, []float64{70}) {
+		t.Errorf("Expected mono channel untouched, got %v", mono)
+	}
+}
+
+func TestWithAverage(t *testing.T) {
+	got := withAverage([]float64{40, 80}, 30)
+
+	if !reflect.DeepEqual(got, []float64{20, 40}) {
+		t.Errorf("Expected [20 40], got %v", got)
+	}
+	if b := balanceOf(stereo, got); b != 0.5 {
+		t.Errorf("Expected balance 0.5 to stay, got %v", b)
+	}
+
+	if silent := withAverage([]float64{0, 0}, 30); !reflect.DeepEqual(silent, []float64{30, 30}) {
+		t.Errorf("Expected flat volume from silence, got %v", silent)
+	}
+}
+
+func TestChannelsFromText(t *testing.T) {
+	out := `Sink #55
+	Name: alsa_output.speakers
+	Volume: front-left: 39322 /  60% / -13.31 dB,   front-right: 32768 /  50% / -18.06 dB
+	        balance -0.17
+	Base Volume: 65536 / 100% / 0.00 dB`
+
+	channels, volume, balance := channelsFromText(out)
+
+	want := []ChannelVolume{{"front-left", 60}, {"front-right", 50}}
+	if !reflect.DeepEqual(channels, want) {
+		t.Errorf("Expected channels %+v, got %+v", want, channels)
+	}
+	if volume != 55 || balance != -0.17 {
+		t.Errorf("Expected volume 55 and balance -0.17, got %d %v", volume, balance)
+	}
+}
+
+func TestVolumeUpdate(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Volume string
+		Want   []float64
+	}{
+		{"Absolute", "30.00", []float64{20, 40}},
+		{"Up", "+5.00", []float64{45, 85}},
+		{"Down", "-5.00", []float64{35, 75}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			update, _, _, err := volumeUpdate(tt.Volume)
+			if err != nil {
+				t.Fatalf("volumeUpdate: %v", err)
+			}
+			got, _ := update(stereo, []float64{40, 80})
+			if !reflect.DeepEqual(got, tt.Want) {
+				t.Errorf("Expected %v, got %v", tt.Want, got)
+			}
+		})
+	}
+
+	if _, _, _, err := volumeUpdate("loud"); err == nil {
+		t.Errorf("Expected error for invalid volume")
+	}
+}
+
+func TestCapped(t *testing.T) {
+	defer func(prev float64) { MaxVolume = prev }(MaxVolume)
+	MaxVolume = 100
+
+	got := capped(withDelta([]float64{90, 98, 3}, 10))
+	if !reflect.DeepEqual(got, []float64{100, 100, 13}) {
+		t.Errorf("Expected channels capped at 100, got %v", got)
+	}
+	if v := CapVolume(-10); v != 0 {
+		t.Errorf("Expected volume not below 0, got %v", v)
+	}
+}
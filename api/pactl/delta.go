@@ -0,0 +1,63 @@
+package pactl
+
+import "reflect"
+
+// StatusDelta is change between two Status. Changed entities are sent whole and keyed by id,
+// so applying the same delta twice, or on top of newer status, is harmless.
+type StatusDelta struct {
+	Sinks         []Sink         `json:"sinks,omitempty" doc:"Added or changed sinks"`
+	SinkInputs    []SinkInput    `json:"sinkInputs,omitempty" doc:"Added or changed applications that are playing audio"`
+	Sources       []Source       `json:"sources,omitempty" doc:"Added or changed sources"`
+	SourceOutputs []SourceOutput `json:"sourceOutputs,omitempty" doc:"Added or changed applications that are recording audio"`
+	Cards         []Card         `json:"cards,omitempty" doc:"Added or changed sound cards"`
+	Removed       *StatusRemoved `json:"removed,omitempty" doc:"IDs of removed entities"`
+}
+
+type StatusRemoved struct {
+	Sinks         []int `json:"sinks,omitempty" doc:"IDs of removed sinks"`
+	SinkInputs    []int `json:"sinkInputs,omitempty" doc:"IDs of removed sink inputs"`
+	Sources       []int `json:"sources,omitempty" doc:"IDs of removed sources"`
+	SourceOutputs []int `json:"sourceOutputs,omitempty" doc:"IDs of removed source outputs"`
+	Cards         []int `json:"cards,omitempty" doc:"IDs of removed cards"`
+}
+
+// Diff is what changed from prev to next. BuildInfo doesn't change while server runs, it's not compared.
+func Diff(prev Status, next Status) StatusDelta {
+	var d StatusDelta
+	var r StatusRemoved
+
+	d.Sinks, r.Sinks = diff(prev.Sinks, next.Sinks, func(s Sink) int { return s.ID })
+	d.SinkInputs, r.SinkInputs = diff(prev.SinkInputs, next.SinkInputs, func(s SinkInput) int { return s.ID })
+	d.Sources, r.Sources = diff(prev.Sources, next.Sources, func(s Source) int { return s.ID })
+	d.SourceOutputs, r.SourceOutputs = diff(prev.SourceOutputs, next.SourceOutputs, func(s SourceOutput) int { return s.ID })
+	d.Cards, r.Cards = diff(prev.Cards, next.Cards, func(c Card) int { return c.ID })
+
+	if !reflect.DeepEqual(r, StatusRemoved{}) {
+		d.Removed = &r
+	}
+	return d
+}
+
+// diff returns entities of next that are new or changed, and IDs of prev entities missing in next
+func diff[T any](prev []T, next []T, id func(T) int) ([]T, []int) {
+	old := make(map[int]T, len(prev))
+	for _, e := range prev {
+		old[id(e)] = e
+	}
+
+	var upsert []T
+	for _, e := range next {
+		if o, ok := old[id(e)]; !ok || !reflect.DeepEqual(o, e) {
+			upsert = append(upsert, e)
+		}
+		delete(old, id(e))
+	}
+
+	var removed []int
+	for _, e := range prev {
+		if _, ok := old[id(e)]; ok {
+			removed = append(removed, id(e))
+		}
+	}
+	return upsert, removed
+}
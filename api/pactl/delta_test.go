@@ -0,0 +1,33 @@
+package pactl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	prev := Status{
+		Sinks:      []Sink{{ID: 55, Name: "alsa_output.speakers", Volume: 30}, {ID: 56, Name: "bluez_output.headset", Volume: 50}},
+		SinkInputs: []SinkInput{{ID: 91, SinkID: 55, Volume: 100}},
+		Cards:      []Card{{ID: 60, Name: "bluez_card.headset", ActiveProfile: "a2dp-sink"}},
+	}
+	next := Status{
+		Sinks:         []Sink{{ID: 55, Name: "alsa_output.speakers", Volume: 40}, {ID: 56, Name: "bluez_output.headset", Volume: 50}},
+		SourceOutputs: []SourceOutput{{ID: 93, SourceID: 58, Volume: 100}},
+		Cards:         []Card{{ID: 60, Name: "bluez_card.headset", ActiveProfile: "a2dp-sink"}},
+	}
+
+	got := Diff(prev, next)
+	want := StatusDelta{
+		Sinks:         []Sink{{ID: 55, Name: "alsa_output.speakers", Volume: 40}},
+		SourceOutputs: []SourceOutput{{ID: 93, SourceID: 58, Volume: 100}},
+		Removed:       &StatusRemoved{SinkInputs: []int{91}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("[Err] Expected %+v, got %+v", want, got)
+	}
+
+	if d := Diff(next, next); !reflect.DeepEqual(d, StatusDelta{}) {
+		t.Errorf("[Err] Expected empty delta of the same status, got %+v", d)
+	}
+}
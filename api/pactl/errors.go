@@ -0,0 +1,72 @@
+package pactl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/undg/pulse-remote/api/pactl/native"
+)
+
+var (
+	// ErrInvalidArgument is returned before anything is sent to the sound server, fe. for not numeric volume
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrNoEntity matches CommandError when sink, source, app or card doesn't exist
+	ErrNoEntity = errors.New("no such entity")
+	// ErrCardsUnsupported is returned by GetCards with old pactl and no native protocol,
+	// profiles are not parsed from localized text output
+	ErrCardsUnsupported = errors.New("cards need native protocol or pactl 16 or newer")
+)
+
+// CommandError is returned when the sound server rejects a command.
+type CommandError struct {
+	// pactl command, fe. "set-default-sink". Native protocol uses the same names.
+	Command string
+	// Name or ID of sink, source, app or card
+	Target string
+	// pactl's stderr or native protocol error text, fe. "Failure: No such entity"
+	Message string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Command, e.Target, e.Message)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CommandError) Is(target error) bool {
+	if target != ErrNoEntity {
+		return false
+	}
+
+	var nativeErr *native.Error
+	if errors.As(e.Err, &nativeErr) {
+		return nativeErr.Code == native.CodeNoEntity
+	}
+	return strings.Contains(e.Message, "No such entity")
+}
+
+// commandError wraps pactl or native protocol failure. Invalid arguments are returned as they are.
+func commandError(command string, target string, err error) error {
+	if err == nil || errors.Is(err, ErrInvalidArgument) {
+		return err
+	}
+
+	e := &CommandError{Command: command, Target: target, Message: err.Error(), Err: err}
+
+	var exitErr *exec.ExitError
+	var nativeErr *native.Error
+	switch {
+	case errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0:
+		e.Message = string(bytes.TrimSpace(exitErr.Stderr))
+	case errors.As(err, &nativeErr):
+		e.Message = nativeErr.Code.String()
+	}
+
+	return e
+}
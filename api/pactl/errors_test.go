@@ -0,0 +1,50 @@
+package pactl
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/undg/pulse-remote/api/pactl/native"
+)
+
+func TestCommandError(t *testing.T) {
+	_, execErr := exec.Command("sh", "-c", "echo 'Failure: No such entity' >&2; exit 1").Output()
+
+	tests := []struct {
+		Name     string
+		Err      error
+		Message  string
+		NoEntity bool
+	}{
+		{"Stderr", execErr, "Failure: No such entity", true},
+		{"Native", &native.Error{Command: "SET_DEFAULT_SINK", Code: native.CodeNoEntity}, "No such entity", true},
+		{"NativeAccess", &native.Error{Command: "SET_DEFAULT_SINK", Code: native.CodeAccess}, "Access denied", false},
+		{"Other", errors.New("broken pipe"), "broken pipe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := commandError("set-default-sink", "nope", tt.Err)
+
+			var cmdErr *CommandError
+			if !errors.As(err, &cmdErr) {
+				t.Fatalf("Expected CommandError, got %T %v", err, err)
+			}
+			if cmdErr.Message != tt.Message {
+				t.Errorf("Expected message %q, got %q", tt.Message, cmdErr.Message)
+			}
+			if errors.Is(err, ErrNoEntity) != tt.NoEntity {
+				t.Errorf("Expected errors.Is(err, ErrNoEntity) %v for %v", tt.NoEntity, err)
+			}
+		})
+	}
+
+	invalid := commandError("set-sink-volume", "speakers", ErrInvalidArgument)
+	if invalid != ErrInvalidArgument {
+		t.Errorf("Expected invalid argument returned as it is, got %v", invalid)
+	}
+	if commandError("set-sink-volume", "speakers", nil) != nil {
+		t.Errorf("Expected nil for success")
+	}
+}
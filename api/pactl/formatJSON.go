@@ -0,0 +1,332 @@
+package pactl
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/undg/pulse-remote/api/logger"
+	gen "github.com/undg/pulse-remote/api/pactl/generated"
+)
+
+// PA_VOLUME_NORM, 100% volume
+const volumeNorm = 0x10000
+
+var errNoJSONFormat = errors.New("pactl doesn't support --format=json")
+
+// Set after first pactl call that rejected --format flag. Old pactl will not learn it at runtime.
+var noJSONFormat atomic.Bool
+
+func hasJSONFormat() bool {
+	return !noJSONFormat.Load()
+}
+
+// Same shape for every channel in sinks, sources and sink-inputs
+type pactlVolumeJSON = struct {
+	DB           string  `json:"db"`
+	Value        float64 `json:"value"`
+	ValuePercent string  `json:"value_percent"`
+}
+
+// Same shape for ports of sinks and sources
+type pactlPortJSON = struct {
+	Availability      string  `json:"availability"`
+	AvailabilityGroup string  `json:"availability_group"`
+	Description       string  `json:"description"`
+	Name              string  `json:"name"`
+	Priority          float64 `json:"priority"`
+	Type              string  `json:"type"`
+}
+
+type pactlInfoJSON struct {
+	DefaultSinkName   string `json:"default_sink_name"`
+	DefaultSourceName string `json:"default_source_name"`
+}
+
+// pactlJSON runs `pactl --format=json args...` and decodes output into v.
+// Returns errNoJSONFormat when pactl is too old to know the flag.
+func pactlJSON(v any, args ...string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("pactl", append([]string{"--format=json"}, args...)...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		// getopt message is localized, but always quotes the flag itself
+		if strings.Contains(stderr.String(), "--format") {
+			noJSONFormat.Store(true)
+			logger.Warn().Str("stderr", strings.TrimSpace(stderr.String())).Msg("pactl without --format=json, fallback to text parser")
+			return errNoJSONFormat
+		}
+		return fmt.Errorf("pactl %s: %w", strings.Join(args, " "), err)
+	}
+
+	return json.Unmarshal(out, v)
+}
+
+// channelsFromJSON reads channels in channelMap order, fe. "front-left,front-right"
+func channelsFromJSON(channelMap string, volume map[string]pactlVolumeJSON) ([]ChannelVolume, int, float64) {
+	var names []string
+	var percents []float64
+
+	for _, channel := range strings.Split(channelMap, ",") {
+		if v, ok := volume[channel]; ok {
+			names = append(names, channel)
+			percents = append(percents, v.Value*100/volumeNorm)
+		}
+	}
+
+	// Channel map doesn't match volume keys, order is lost anyway
+	if len(names) != len(volume) {
+		names, percents = nil, nil
+		for _, channel := range slices.Sorted(maps.Keys(volume)) {
+			names = append(names, channel)
+			percents = append(percents, volume[channel].Value*100/volumeNorm)
+		}
+	}
+
+	return channelsFrom(names, percents)
+}
+
+func getInfoJSON() (pactlInfoJSON, error) {
+	var info pactlInfoJSON
+	err := pactlJSON(&info, "info")
+	return info, err
+}
+
+func portsFromJSON(raw []pactlPortJSON) []Port {
+	ports := make([]Port, 0, len(raw))
+	for _, p := range raw {
+		// Same strings as in `pactl list` text output
+		available := PortAvailableUnknown
+		switch p.Availability {
+		case "available":
+			available = PortAvailableYes
+		case "not available":
+			available = PortAvailableNo
+		}
+
+		ports = append(ports, Port{
+			Name:      p.Name,
+			Label:     p.Description,
+			Priority:  int(p.Priority),
+			Available: available,
+		})
+	}
+
+	return ports
+}
+
+func sinkFromJSON(s gen.PactlSinkJSON, defaultName string) Sink {
+	channels, volume, balance := channelsFromJSON(s.ChannelMap, s.Volume)
+
+	return Sink{
+		ID:         int(s.Index),
+		Name:       s.Name,
+		Label:      s.Description,
+		Volume:     volume,
+		Channels:   channels,
+		Balance:    balance,
+		Muted:      s.Mute,
+		IsDefault:  s.Name == defaultName,
+		ActivePort: s.ActivePort,
+		Ports:      portsFromJSON(s.Ports),
+	}
+}
+
+func sourceFromJSON(s gen.PactlSourceJSON, defaultName string) Source {
+	// Sources that are not monitors have null here
+	monitor := s.MonitorSource
+	if monitor == "" {
+		monitor = "n/a"
+	}
+
+	channels, volume, balance := channelsFromJSON(s.ChannelMap, s.Volume)
+
+	return Source{
+		ID:         int(s.Index),
+		Name:       s.Name,
+		Label:      s.Description,
+		Volume:     volume,
+		Channels:   channels,
+		Balance:    balance,
+		Muted:      s.Mute,
+		Monitor:    monitor,
+		Monitored:  monitor != "n/a",
+		IsDefault:  s.Name == defaultName,
+		ActivePort: s.ActivePort,
+		Ports:      portsFromJSON(s.Ports),
+	}
+}
+
+// appLabel picks the first non-empty name from most to least human-readable.
+// Not every app sets application.name.
+func appLabel(kind string, index int, names ...string) string {
+	for _, label := range names {
+		if label != "" {
+			return label
+		}
+	}
+
+	return fmt.Sprintf("%s #%d", kind, index)
+}
+
+func sinkInputLabel(a gen.PactlAppsJSON) string {
+	p := a.Properties
+	return appLabel("Sink Input", int(a.Index), p.Application_Name, p.Media_Name, p.Application_Process_Binary, p.Node_Name)
+}
+
+func sinkInputFromJSON(a gen.PactlAppsJSON) SinkInput {
+	channels, volume, balance := channelsFromJSON(a.ChannelMap, a.Volume)
+
+	return SinkInput{
+		ID:       int(a.Index),
+		SinkID:   int(a.Sink),
+		Label:    sinkInputLabel(a),
+		Volume:   volume,
+		Channels: channels,
+		Balance:  balance,
+		Muted:    a.Mute,
+	}
+}
+
+func sourceOutputFromJSON(a gen.PactlSourceOutputJSON) SourceOutput {
+	p := a.Properties
+	_, volume, _ := channelsFromJSON(a.ChannelMap, a.Volume)
+
+	return SourceOutput{
+		ID:       int(a.Index),
+		SourceID: int(a.Source),
+		Label:    appLabel("Source Output", int(a.Index), p.Application_Name, p.Media_Name, p.Application_Process_Binary, p.Node_Name),
+		Volume:   volume,
+		Muted:    a.Mute,
+	}
+}
+
+// sortProfiles puts most preferred profile first. Server and pactl JSON don't keep any order.
+func sortProfiles(profiles []CardProfile) {
+	slices.SortStableFunc(profiles, func(a, b CardProfile) int {
+		if a.Priority != b.Priority {
+			return cmp.Compare(b.Priority, a.Priority)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+func cardFromJSON(c gen.PactlCardJSON) Card {
+	label := c.Properties.Device_Description
+	if label == "" {
+		label = c.Name
+	}
+
+	profiles := make([]CardProfile, 0, len(c.Profiles))
+	for name, p := range c.Profiles {
+		profiles = append(profiles, CardProfile{
+			Name:      name,
+			Label:     p.Description,
+			Sinks:     int(p.Sinks),
+			Sources:   int(p.Sources),
+			Priority:  int(p.Priority),
+			Available: p.Available,
+		})
+	}
+	sortProfiles(profiles)
+
+	return Card{
+		ID:            int(c.Index),
+		Name:          c.Name,
+		Label:         label,
+		ActiveProfile: c.ActiveProfile,
+		Profiles:      profiles,
+	}
+}
+
+func getSinksJSON() ([]Sink, error) {
+	var raw []gen.PactlSinkJSON
+	if err := pactlJSON(&raw, "list", "sinks"); err != nil {
+		return nil, err
+	}
+
+	// Like text output, list without default is better than none
+	info, err := getInfoJSON()
+	if err != nil {
+		logger.Warn().Err(err).Msg("pactl info FAIL in getSinksJSON(), no default sink")
+	}
+
+	sinks := make([]Sink, 0, len(raw))
+	for _, s := range raw {
+		sinks = append(sinks, sinkFromJSON(s, info.DefaultSinkName))
+	}
+
+	return sinks, nil
+}
+
+func getSourcesJSON() ([]Source, error) {
+	var raw []gen.PactlSourceJSON
+	if err := pactlJSON(&raw, "list", "sources"); err != nil {
+		return nil, err
+	}
+
+	// Like text output, list without default is better than none
+	info, err := getInfoJSON()
+	if err != nil {
+		logger.Warn().Err(err).Msg("pactl info FAIL in getSourcesJSON(), no default source")
+	}
+
+	sources := make([]Source, 0, len(raw))
+	for _, s := range raw {
+		sources = append(sources, sourceFromJSON(s, info.DefaultSourceName))
+	}
+
+	return sources, nil
+}
+
+func getSinkInputsJSON() ([]SinkInput, error) {
+	var raw []gen.PactlAppsJSON
+	if err := pactlJSON(&raw, "list", "sink-inputs"); err != nil {
+		return nil, err
+	}
+
+	sinkInputs := make([]SinkInput, 0, len(raw))
+	for _, a := range raw {
+		sinkInputs = append(sinkInputs, sinkInputFromJSON(a))
+	}
+
+	return sinkInputs, nil
+}
+
+func getSourceOutputsJSON() ([]SourceOutput, error) {
+	var raw []gen.PactlSourceOutputJSON
+	if err := pactlJSON(&raw, "list", "source-outputs"); err != nil {
+		return nil, err
+	}
+
+	sourceOutputs := make([]SourceOutput, 0, len(raw))
+	for _, a := range raw {
+		sourceOutputs = append(sourceOutputs, sourceOutputFromJSON(a))
+	}
+
+	return sourceOutputs, nil
+}
+
+func getCardsJSON() ([]Card, error) {
+	var raw []gen.PactlCardJSON
+	if err := pactlJSON(&raw, "list", "cards"); err != nil {
+		return nil, err
+	}
+
+	cards := make([]Card, 0, len(raw))
+	for _, c := range raw {
+		cards = append(cards, cardFromJSON(c))
+	}
+
+	return cards, nil
+}
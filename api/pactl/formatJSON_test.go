@@ -0,0 +1,200 @@
+package pactl
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	gen "github.com/undg/pulse-remote/api/pactl/generated"
+)
+
+const sinkJSON = `{
+	"index": 55,
+	"state": "RUNNING",
+	"name": "alsa_output.pci-0000_0c_00.4.analog-stereo",
+	"description": "Family 17h HD Audio\nAnalog Stereo",
+	"channel_map": "front-left,front-right",
+	"mute": false,
+	"volume": {
+		"front-left": {"value": 39322, "value_percent": "60%", "db": "-13.31 dB"},
+		"front-right": {"value": 32768, "value_percent": "50%", "db": "-18.06 dB"}
+	},
+	"monitor_source": "alsa_output.pci-0000_0c_00.4.analog-stereo.monitor",
+	"active_port": "analog-output-lineout",
+	"ports": [
+		{"name": "analog-output-lineout", "description": "Line Out", "type": "Line", "priority": 9000, "availability_group": "Legacy 1", "availability": "available"},
+		{"name": "analog-output-headphones", "description": "Headphones", "type": "Headphones", "priority": 9900, "availability_group": "Legacy 2", "availability": "not available"},
+		{"name": "hdmi-output-0", "description": "HDMI", "type": "HDMI", "priority": 5900, "availability_group": "", "availability": "availability unknown"}
+	]
+}`
+
+const sourceJSON = `{
+	"index": 56,
+	"name": "alsa_input.pci-0000_0c_00.4.analog-stereo",
+	"description": "Mic",
+	"channel_map": "mono",
+	"mute": true,
+	"volume": {"mono": {"value": 65536, "value_percent": "100%", "db": "0.00 dB"}},
+	"monitor_source": null,
+	"active_port": null,
+	"ports": []
+}`
+
+const sinkInputJSON = `{
+	"index": 91,
+	"sink": 55,
+	"channel_map": "front-left,front-right",
+	"mute": false,
+	"volume": {
+		"front-left": {"value": 19661, "value_percent": "30%", "db": "-31.37 dB"},
+		"front-right": {"value": 19661, "value_percent": "30%", "db": "-31.37 dB"}
+	},
+	"properties": {"media.name": "Playback Stream", "application.process.binary": "paplay"}
+}`
+
+const sourceOutputJSON = `{
+	"index": 93,
+	"source": 58,
+	"channel_map": "mono",
+	"mute": true,
+	"volume": {
+		"mono": {"value": 45875, "value_percent": "70%", "db": "-9.29 dB"}
+	},
+	"properties": {}
+}`
+
+const cardJSON = `{
+	"index": 60,
+	"name": "bluez_card.headset",
+	"driver": "module-bluez5-device.c",
+	"properties": {"device.description": "WH-1000XM3"},
+	"profiles": {
+		"off": {"description": "Off", "sinks": 0, "sources": 0, "priority": 0, "available": true},
+		"a2dp-sink": {"description": "High Fidelity Playback (A2DP Sink)", "sinks": 1, "sources": 0, "priority": 40, "available": true},
+		"headset-head-unit": {"description": "Headset Head Unit (HSP/HFP)", "sinks": 1, "sources": 1, "priority": 30, "available": false}
+	},
+	"active_profile": "a2dp-sink",
+	"ports": {}
+}`
+
+func TestSinkFromJSON(t *testing.T) {
+	var raw gen.PactlSinkJSON
+	if err := json.Unmarshal([]byte(sinkJSON), &raw); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	sink := sinkFromJSON(raw, "alsa_output.pci-0000_0c_00.4.analog-stereo")
+
+	if sink.ID != 55 {
+		t.Errorf("Expected ID 55, got %d", sink.ID)
+	}
+	if sink.Label != "Family 17h HD Audio\nAnalog Stereo" {
+		t.Errorf("Multi-line description not preserved, got %q", sink.Label)
+	}
+	if sink.Volume != 55 {
+		t.Errorf("Expected average volume 55, got %d", sink.Volume)
+	}
+	wantChannels := []ChannelVolume{{"front-left", 60}, {"front-right", 50}}
+	if !reflect.DeepEqual(sink.Channels, wantChannels) {
+		t.Errorf("Expected channels %+v, got %+v", wantChannels, sink.Channels)
+	}
+	if sink.Balance != -0.17 {
+		t.Errorf("Expected balance -0.17, got %v", sink.Balance)
+	}
+	if !sink.IsDefault {
+		t.Errorf("Expected sink to be default")
+	}
+	if sink.ActivePort != "analog-output-lineout" || len(sink.Ports) != 3 {
+		t.Fatalf("Wrong ports %q %+v", sink.ActivePort, sink.Ports)
+	}
+	for i, want := range []string{PortAvailableYes, PortAvailableNo, PortAvailableUnknown} {
+		if sink.Ports[i].Available != want {
+			t.Errorf("Expected port %q available %q, got %q", sink.Ports[i].Name, want, sink.Ports[i].Available)
+		}
+	}
+}
+
+func TestSourceFromJSON(t *testing.T) {
+	var raw gen.PactlSourceJSON
+	if err := json.Unmarshal([]byte(sourceJSON), &raw); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	source := sourceFromJSON(raw, "other")
+
+	if source.Volume != 100 {
+		t.Errorf("Expected volume 100, got %d", source.Volume)
+	}
+	if !source.Muted {
+		t.Errorf("Expected source to be muted")
+	}
+	if source.Monitored || source.Monitor != "n/a" {
+		t.Errorf("Expected not monitored source, got Monitor=%q Monitored=%v", source.Monitor, source.Monitored)
+	}
+	if source.IsDefault {
+		t.Errorf("Expected source not to be default")
+	}
+}
+
+func TestSinkInputFromJSON(t *testing.T) {
+	var raw gen.PactlAppsJSON
+	if err := json.Unmarshal([]byte(sinkInputJSON), &raw); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	app := sinkInputFromJSON(raw)
+
+	if app.ID != 91 || app.SinkID != 55 {
+		t.Errorf("Expected ID 91 on sink 55, got %d on %d", app.ID, app.SinkID)
+	}
+	if app.Label != "Playback Stream" {
+		t.Errorf("Expected label from media.name without application.name, got %q", app.Label)
+	}
+	if app.Volume != 30 {
+		t.Errorf("Expected volume 30, got %d", app.Volume)
+	}
+}
+
+func TestSourceOutputFromJSON(t *testing.T) {
+	var raw gen.PactlSourceOutputJSON
+	if err := json.Unmarshal([]byte(sourceOutputJSON), &raw); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	app := sourceOutputFromJSON(raw)
+
+	if app.ID != 93 || app.SourceID != 58 {
+		t.Errorf("Expected ID 93 on source 58, got %d on %d", app.ID, app.SourceID)
+	}
+	if app.Label != "Source Output #93" {
+		t.Errorf("Expected fallback label without properties, got %q", app.Label)
+	}
+	if app.Volume != 70 || !app.Muted {
+		t.Errorf("Expected muted volume 70, got %d muted %v", app.Volume, app.Muted)
+	}
+}
+
+func TestCardFromJSON(t *testing.T) {
+	var raw gen.PactlCardJSON
+	if err := json.Unmarshal([]byte(cardJSON), &raw); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	card := cardFromJSON(raw)
+
+	if card.ID != 60 || card.Label != "WH-1000XM3" || card.ActiveProfile != "a2dp-sink" {
+		t.Errorf("Wrong card %+v", card)
+	}
+
+	names := make([]string, 0, len(card.Profiles))
+	for _, p := range card.Profiles {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "a2dp-sink,headset-head-unit,off" {
+		t.Errorf("Expected profiles sorted by priority, got %v", names)
+	}
+	if p := card.Profiles[1]; p.Available || p.Sources != 1 || p.Label != "Headset Head Unit (HSP/HFP)" {
+		t.Errorf("Wrong profile %+v", p)
+	}
+}
@@ -0,0 +1,233 @@
+package pactl
+
+import (
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Fallback for pactl versions without --format=json (PulseAudio < 16).
+// Human-readable output is localized and fragile, don't add new features here.
+
+var (
+	// "Base Volume:" is a different line
+	volumeLineRe = regexp.MustCompile(`(?m)^\s*Volume: (.+)$`)
+	// front-left: 39322 /  60% / -13.31 dB
+	channelRe = regexp.MustCompile(`([\w-]+): +(\d+) /`)
+)
+
+// channelsFromText reads raw volume of every channel from "Volume:" line
+func channelsFromText(out string) ([]ChannelVolume, int, float64) {
+	line := volumeLineRe.FindStringSubmatch(out)
+	if line == nil {
+		return channelsFrom(nil, nil)
+	}
+
+	var names []string
+	var percents []float64
+	for _, m := range channelRe.FindAllStringSubmatch(line[1], -1) {
+		raw, _ := strconv.ParseFloat(m[2], 64)
+		names = append(names, m[1])
+		percents = append(percents, raw*100/volumeNorm)
+	}
+
+	return channelsFrom(names, percents)
+}
+
+func parseSink(sinkName string, defaultName string) Sink {
+	idRe, _ := regexp.Compile(`Sink #(\d+)`)
+	nameRe, _ := regexp.Compile(`Name: (.+)`)
+	descRe, _ := regexp.Compile(`Description: (.+)`)
+	muteRe, _ := regexp.Compile(`Mute: (yes|no)`)
+
+	id, _ := strconv.Atoi(idRe.FindStringSubmatch(sinkName)[1])
+	name := nameRe.FindStringSubmatch(sinkName)[1]
+	desc := descRe.FindStringSubmatch(sinkName)[1]
+	channels, volume, balance := channelsFromText(sinkName)
+	mute := muteRe.FindStringSubmatch(sinkName)[1] == "yes"
+
+	return Sink{
+		ID:        id,
+		Name:      name,
+		Label:     desc,
+		Volume:    volume,
+		Channels:  channels,
+		Balance:   balance,
+		Muted:     mute,
+		IsDefault: name == defaultName,
+	}
+}
+
+func getDefaultSinkName() (string, error) {
+	cmd := exec.Command("pactl", "info")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	re, _ := regexp.Compile(`Default Sink: (.+)`)
+	matches := re.FindStringSubmatch(string(out))
+	if len(matches) < 2 {
+		return "", nil
+	}
+
+	return strings.TrimSpace(matches[1]), nil
+}
+
+func getSinksText() ([]Sink, error) {
+	defaultName, _ := getDefaultSinkName()
+
+	cmd := exec.Command("pactl", "list", "sinks")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	sinksNames := strings.Split(string(out), "Sink #")
+	sinks := make([]Sink, 0, len(sinksNames)-1)
+
+	for _, sink := range sinksNames[1:] {
+		sinks = append(sinks, parseSink("Sink #"+sink, defaultName))
+	}
+
+	return sinks, nil
+}
+
+func getSinkInputsText() ([]SinkInput, error) {
+	cmd := exec.Command("pactl", "list", "sink-inputs")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(`Sink Input #(\d+)[\s\S]*?Sink: (\d+)[\s\S]*?Mute: (yes|no)[\s\S]*?(Volume: .*)[\s\S]*?application\.name = "(.*?)"`)
+	if err != nil {
+		return nil, err
+	}
+	matches := re.FindAllStringSubmatch(string(out), -1)
+
+	sinkInputs := make([]SinkInput, len(matches))
+	for i, m := range matches {
+		id, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		sinkID, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, err
+		}
+		channels, volume, balance := channelsFromText(m[4])
+		sinkInputs[i] = SinkInput{
+			ID:       id,
+			SinkID:   sinkID,
+			Label:    m[5],
+			Volume:   volume,
+			Channels: channels,
+			Balance:  balance,
+			Muted:    m[3] == "yes",
+		}
+	}
+
+	return sinkInputs, nil
+}
+
+func getSourceOutputsText() ([]SourceOutput, error) {
+	cmd := exec.Command("pactl", "list", "source-outputs")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(`Source Output #(\d+)[\s\S]*?Source: (\d+)[\s\S]*?Mute: (yes|no)[\s\S]*?(Volume: .*)[\s\S]*?application\.name = "(.*?)"`)
+	if err != nil {
+		return nil, err
+	}
+	matches := re.FindAllStringSubmatch(string(out), -1)
+
+	sourceOutputs := make([]SourceOutput, len(matches))
+	for i, m := range matches {
+		id, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		sourceID, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, err
+		}
+		_, volume, _ := channelsFromText(m[4])
+		sourceOutputs[i] = SourceOutput{
+			ID:       id,
+			SourceID: sourceID,
+			Label:    m[5],
+			Volume:   volume,
+			Muted:    m[3] == "yes",
+		}
+	}
+
+	return sourceOutputs, nil
+}
+
+func parseSources(sourceName string, defaultName string) Source {
+	idRe, _ := regexp.Compile(`Source #(\d+)`)
+	nameRe, _ := regexp.Compile(`Name: (.+)`)
+	descRe, _ := regexp.Compile(`Description: (.+)`)
+	muteRe, _ := regexp.Compile(`Mute: (yes|no)`)
+	monitorRe, _ := regexp.Compile(`Monitor of Sink: (.+)`) // n/a or name of the Sink
+
+	id, _ := strconv.Atoi(idRe.FindStringSubmatch(sourceName)[1])
+	name := nameRe.FindStringSubmatch(sourceName)[1]
+	desc := descRe.FindStringSubmatch(sourceName)[1]
+	channels, volume, balance := channelsFromText(sourceName)
+	muted := muteRe.FindStringSubmatch(sourceName)[1] == "yes"
+	monitored := monitorRe.FindStringSubmatch(sourceName)[1] != "n/a"
+	monitor := monitorRe.FindStringSubmatch(sourceName)[1]
+
+	return Source{
+		ID:        id,
+		Name:      name,
+		Label:     desc,
+		Volume:    volume,
+		Channels:  channels,
+		Balance:   balance,
+		Muted:     muted,
+		Monitor:   monitor,
+		Monitored: monitored,
+		IsDefault: name == defaultName,
+	}
+}
+
+func getDefaultSourceName() (string, error) {
+	cmd := exec.Command("pactl", "info")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	re, _ := regexp.Compile(`Default Source: (.+)`)
+	matches := re.FindStringSubmatch(string(out))
+	if len(matches) < 2 {
+		return "", nil
+	}
+
+	return strings.TrimSpace(matches[1]), nil
+}
+
+func getSourcesText() ([]Source, error) {
+	defaultName, _ := getDefaultSourceName()
+
+	cmd := exec.Command("pactl", "list", "sources")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	source := strings.Split(string(out), "Source #")
+	sources := make([]Source, 0, len(source)-1)
+
+	for _, sink := range source[1:] {
+		sources = append(sources, parseSources("Source #"+sink, defaultName))
+	}
+
+	return sources, nil
+}
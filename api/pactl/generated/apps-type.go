@@ -55,16 +55,10 @@ type PactlAppsJSON struct {
 	SampleSpecification string  `json:"sample_specification"`
 	Sink                float64 `json:"sink"`
 	SinkLatencyUsec     float64 `json:"sink_latency_usec"`
-	Volume              struct {
-		FrontLeft struct {
-			DB           string  `json:"db"`
-			Value        float64 `json:"value"`
-			ValuePercent string  `json:"value_percent"`
-		} `json:"front-left"`
-		FrontRight struct {
-			DB           string  `json:"db"`
-			Value        float64 `json:"value"`
-			ValuePercent string  `json:"value_percent"`
-		} `json:"front-right"`
+	// Keyed by channel position, fe. "front-left", "mono", "aux0"
+	Volume map[string]struct {
+		DB           string  `json:"db"`
+		Value        float64 `json:"value"`
+		ValuePercent string  `json:"value_percent"`
 	} `json:"volume"`
 }
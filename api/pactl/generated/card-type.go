@@ -0,0 +1,47 @@
+//lint:file-ignore ST1003 Ignore underscore naming in generated code
+
+package pactl
+
+type PactlCardJSON struct {
+	ActiveProfile string  `json:"active_profile"`
+	Driver        string  `json:"driver"`
+	Index         float64 `json:"index"`
+	Name          string  `json:"name"`
+	OwnerModule   float64 `json:"owner_module"`
+	// Keyed by port name
+	Ports map[string]struct {
+		Availability      string   `json:"availability"`
+		AvailabilityGroup string   `json:"availability_group"`
+		Description       string   `json:"description"`
+		LatencyOffset     float64  `json:"latency_offset"`
+		Priority          float64  `json:"priority"`
+		Profiles          []string `json:"profiles"`
+		Type              string   `json:"type"`
+	} `json:"ports"`
+	// Keyed by profile name
+	Profiles map[string]struct {
+		Available   bool    `json:"available"`
+		Description string  `json:"description"`
+		Priority    float64 `json:"priority"`
+		Sinks       float64 `json:"sinks"`
+		Sources     float64 `json:"sources"`
+	} `json:"profiles"`
+	Properties struct {
+		Alsa_Card           string `json:"alsa.card"`
+		Alsa_Card_Name      string `json:"alsa.card_name"`
+		Api_Bluez5_Address  string `json:"api.bluez5.address"`
+		Device_Api          string `json:"device.api"`
+		Device_Bus          string `json:"device.bus"`
+		Device_Description  string `json:"device.description"`
+		Device_FormFactor   string `json:"device.form_factor"`
+		Device_IconName     string `json:"device.icon_name"`
+		Device_Name         string `json:"device.name"`
+		Device_Nick         string `json:"device.nick"`
+		Device_Product_Name string `json:"device.product.name"`
+		Device_String       string `json:"device.string"`
+		Device_Vendor_Name  string `json:"device.vendor.name"`
+		Media_Class         string `json:"media.class"`
+		Object_ID           string `json:"object.id"`
+		Object_Serial       string `json:"object.serial"`
+	} `json:"properties"`
+}
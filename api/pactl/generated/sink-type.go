@@ -106,16 +106,10 @@ type PactlSinkJSON struct {
 	} `json:"properties"`
 	SampleSpecification string `json:"sample_specification"`
 	State               string `json:"state"`
-	Volume              struct {
-		FrontLeft struct {
-			DB           string  `json:"db"`
-			Value        float64 `json:"value"`
-			ValuePercent string  `json:"value_percent"`
-		} `json:"front-left"`
-		FrontRight struct {
-			DB           string  `json:"db"`
-			Value        float64 `json:"value"`
-			ValuePercent string  `json:"value_percent"`
-		} `json:"front-right"`
+	// Keyed by channel position, fe. "front-left", "mono", "aux0"
+	Volume map[string]struct {
+		DB           string  `json:"db"`
+		Value        float64 `json:"value"`
+		ValuePercent string  `json:"value_percent"`
 	} `json:"volume"`
 }
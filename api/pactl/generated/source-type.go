@@ -3,7 +3,7 @@
 package pactl
 
 type PactlSourceJSON struct {
-	ActivePort any     `json:"active_port"`
+	ActivePort string  `json:"active_port"`
 	Balance    float64 `json:"balance"`
 	BaseVolume struct {
 		DB           string  `json:"db"`
@@ -24,8 +24,15 @@ type PactlSourceJSON struct {
 	Mute          bool    `json:"mute"`
 	Name          string  `json:"name"`
 	OwnerModule   float64 `json:"owner_module"`
-	Ports         []any   `json:"ports"`
-	Properties    struct {
+	Ports         []struct {
+		Availability      string  `json:"availability"`
+		AvailabilityGroup string  `json:"availability_group"`
+		Description       string  `json:"description"`
+		Name              string  `json:"name"`
+		Priority          float64 `json:"priority"`
+		Type              string  `json:"type"`
+	} `json:"ports"`
+	Properties struct {
 		Alsa_Card                        string `json:"alsa.card"`
 		Alsa_cardName                    string `json:"alsa.card_name"`
 		Alsa_Class                       string `json:"alsa.class"`
@@ -99,11 +106,10 @@ type PactlSourceJSON struct {
 	} `json:"properties"`
 	SampleSpecification string `json:"sample_specification"`
 	State               string `json:"state"`
-	Volume              struct {
-		Aux0 struct {
-			DB           string  `json:"db"`
-			Value        float64 `json:"value"`
-			ValuePercent string  `json:"value_percent"`
-		} `json:"aux0"`
+	// Keyed by channel position, fe. "front-left", "mono", "aux0"
+	Volume map[string]struct {
+		DB           string  `json:"db"`
+		Value        float64 `json:"value"`
+		ValuePercent string  `json:"value_percent"`
 	} `json:"volume"`
 }
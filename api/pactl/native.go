@@ -0,0 +1,398 @@
+package pactl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/pactl/native"
+)
+
+// Sound server socket can't be reached, fe. PULSE_SERVER points to tcp. pactl can still handle it.
+var errNativeUnavailable = errors.New("native protocol unavailable")
+
+// Native path doesn't handle this kind of object, pactl will report its own error
+var errNativeUnsupported = errors.New("not supported by native protocol")
+
+var (
+	pulseMu     sync.Mutex
+	pulseClient *native.Client
+)
+
+// pulse returns shared connection to the sound server. Dead connection is replaced by new one,
+// fe. after pipewire-pulse restart.
+func pulse() (*native.Client, error) {
+	pulseMu.Lock()
+	defer pulseMu.Unlock()
+
+	if pulseClient != nil {
+		select {
+		case <-pulseClient.Done():
+			logger.Warn().Err(pulseClient.Err()).Msg("native connection lost, reconnecting")
+			pulseClient = nil
+		default:
+			return pulseClient, nil
+		}
+	}
+
+	c, err := native.Dial("")
+	if err != nil {
+		logger.Debug().Err(err).Msg("native protocol unavailable, fallback to pactl")
+		return nil, fmt.Errorf("%w: %w", errNativeUnavailable, err)
+	}
+
+	logger.Info().Uint32("protocol_version", c.Version()).Msg("native connection established")
+	pulseClient = c
+	return c, nil
+}
+
+func rawPercents(v native.CVolume) []float64 {
+	percents := make([]float64, len(v))
+	for i, raw := range v {
+		percents[i] = float64(raw) * 100 / native.VolumeNorm
+	}
+	return percents
+}
+
+func channelsFromNative(m native.ChannelMap, v native.CVolume) ([]ChannelVolume, int, float64) {
+	return channelsFrom(m.Names(), rawPercents(v))
+}
+
+func parseIndex(id string) (uint32, error) {
+	index, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: index %q: %w", ErrInvalidArgument, id, err)
+	}
+	return uint32(index), nil
+}
+
+func portsFromNative(raw []native.PortInfo) []Port {
+	ports := make([]Port, 0, len(raw))
+	for _, p := range raw {
+		available := PortAvailableUnknown
+		switch p.Available {
+		case native.PortAvailableYes:
+			available = PortAvailableYes
+		case native.PortAvailableNo:
+			available = PortAvailableNo
+		}
+
+		ports = append(ports, Port{
+			Name:      p.Name,
+			Label:     p.Description,
+			Priority:  int(p.Priority),
+			Available: available,
+		})
+	}
+
+	return ports
+}
+
+func sinkFromNative(s native.SinkInfo, defaultName string) Sink {
+	channels, volume, balance := channelsFromNative(s.ChannelMap, s.Volume)
+
+	return Sink{
+		ID:         int(s.Index),
+		Name:       s.Name,
+		Label:      s.Description,
+		Volume:     volume,
+		Channels:   channels,
+		Balance:    balance,
+		Muted:      s.Mute,
+		IsDefault:  s.Name == defaultName,
+		ActivePort: s.ActivePort,
+		Ports:      portsFromNative(s.Ports),
+	}
+}
+
+func sourceFromNative(s native.SourceInfo, defaultName string) Source {
+	monitor := s.MonitorOfSinkName
+	if monitor == "" {
+		monitor = "n/a"
+	}
+
+	channels, volume, balance := channelsFromNative(s.ChannelMap, s.Volume)
+
+	return Source{
+		ID:         int(s.Index),
+		Name:       s.Name,
+		Label:      s.Description,
+		Volume:     volume,
+		Channels:   channels,
+		Balance:    balance,
+		Muted:      s.Mute,
+		Monitor:    monitor,
+		Monitored:  monitor != "n/a",
+		IsDefault:  s.Name == defaultName,
+		ActivePort: s.ActivePort,
+		Ports:      portsFromNative(s.Ports),
+	}
+}
+
+func sinkInputFromNative(s native.SinkInputInfo) SinkInput {
+	p := s.Props
+	channels, volume, balance := channelsFromNative(s.ChannelMap, s.Volume)
+
+	return SinkInput{
+		ID:       int(s.Index),
+		SinkID:   int(s.Sink),
+		Label:    appLabel("Sink Input", int(s.Index), p["application.name"], p["media.name"], p["application.process.binary"], p["node.name"], s.Name),
+		Volume:   volume,
+		Channels: channels,
+		Balance:  balance,
+		Muted:    s.Mute,
+	}
+}
+
+func sourceOutputFromNative(s native.SourceOutputInfo) SourceOutput {
+	p := s.Props
+	_, volume, _ := channelsFromNative(s.ChannelMap, s.Volume)
+
+	return SourceOutput{
+		ID:       int(s.Index),
+		SourceID: int(s.Source),
+		Label:    appLabel("Source Output", int(s.Index), p["application.name"], p["media.name"], p["application.process.binary"], p["node.name"], s.Name),
+		Volume:   volume,
+		Muted:    s.Mute,
+	}
+}
+
+func cardFromNative(c native.CardInfo) Card {
+	label := c.Props["device.description"]
+	if label == "" {
+		label = c.Name
+	}
+
+	profiles := make([]CardProfile, 0, len(c.Profiles))
+	for _, p := range c.Profiles {
+		profiles = append(profiles, CardProfile{
+			Name:      p.Name,
+			Label:     p.Description,
+			Sinks:     int(p.Sinks),
+			Sources:   int(p.Sources),
+			Priority:  int(p.Priority),
+			Available: p.Available,
+		})
+	}
+	sortProfiles(profiles)
+
+	return Card{
+		ID:            int(c.Index),
+		Name:          c.Name,
+		Label:         label,
+		ActiveProfile: c.ActiveProfile,
+		Profiles:      profiles,
+	}
+}
+
+func getSinksNative(c *native.Client) ([]Sink, error) {
+	info, err := c.ServerInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := c.Sinks()
+	if err != nil {
+		return nil, err
+	}
+
+	sinks := make([]Sink, 0, len(raw))
+	for _, s := range raw {
+		sinks = append(sinks, sinkFromNative(s, info.DefaultSinkName))
+	}
+
+	return sinks, nil
+}
+
+func getSourcesNative(c *native.Client) ([]Source, error) {
+	info, err := c.ServerInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := c.Sources()
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]Source, 0, len(raw))
+	for _, s := range raw {
+		sources = append(sources, sourceFromNative(s, info.DefaultSourceName))
+	}
+
+	return sources, nil
+}
+
+func getSinkInputsNative(c *native.Client) ([]SinkInput, error) {
+	raw, err := c.SinkInputs()
+	if err != nil {
+		return nil, err
+	}
+
+	sinkInputs := make([]SinkInput, 0, len(raw))
+	for _, s := range raw {
+		sinkInputs = append(sinkInputs, sinkInputFromNative(s))
+	}
+
+	return sinkInputs, nil
+}
+
+func getSourceOutputsNative(c *native.Client) ([]SourceOutput, error) {
+	raw, err := c.SourceOutputs()
+	if err != nil {
+		return nil, err
+	}
+
+	sourceOutputs := make([]SourceOutput, 0, len(raw))
+	for _, s := range raw {
+		sourceOutputs = append(sourceOutputs, sourceOutputFromNative(s))
+	}
+
+	return sourceOutputs, nil
+}
+
+func getCardsNative(c *native.Client) ([]Card, error) {
+	raw, err := c.Cards()
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]Card, 0, len(raw))
+	for _, card := range raw {
+		cards = append(cards, cardFromNative(card))
+	}
+
+	return cards, nil
+}
+
+// updateChannelsNative reads volume of every channel and writes back what update returns
+func updateChannelsNative(c *native.Client, kind string, nameOrID string, update channelUpdate) error {
+	apply := func(m native.ChannelMap, v native.CVolume, set func(native.CVolume) error) error {
+		percents, err := update(m.Names(), rawPercents(v))
+		if err != nil {
+			return err
+		}
+		if percents == nil {
+			return nil
+		}
+
+		volume := make(native.CVolume, len(percents))
+		for i, p := range capped(percents) {
+			volume[i] = native.FromPercent(p)
+		}
+		return set(volume)
+	}
+
+	switch kind {
+	case "sink":
+		s, err := c.SinkByName(nameOrID)
+		if err != nil {
+			return err
+		}
+		return apply(s.ChannelMap, s.Volume, func(v native.CVolume) error { return c.SetSinkVolume(nameOrID, v) })
+
+	case "source":
+		s, err := c.SourceByName(nameOrID)
+		if err != nil {
+			return err
+		}
+		return apply(s.ChannelMap, s.Volume, func(v native.CVolume) error { return c.SetSourceVolume(nameOrID, v) })
+
+	case "sink-input":
+		index, err := parseIndex(nameOrID)
+		if err != nil {
+			return err
+		}
+		s, err := c.SinkInput(index)
+		if err != nil {
+			return err
+		}
+		return apply(s.ChannelMap, s.Volume, func(v native.CVolume) error { return c.SetSinkInputVolume(index, v) })
+
+	case "source-output":
+		index, err := parseIndex(nameOrID)
+		if err != nil {
+			return err
+		}
+		s, err := c.SourceOutput(index)
+		if err != nil {
+			return err
+		}
+		// Before protocol 22 recording streams have no volume, pactl can't do it either
+		if len(s.Volume) == 0 {
+			return errNativeUnsupported
+		}
+		return apply(s.ChannelMap, s.Volume, func(v native.CVolume) error { return c.SetSourceOutputVolume(index, v) })
+	}
+
+	return errNativeUnsupported
+}
+
+func setMutedNative(c *native.Client, kind string, nameOrID string, muted bool) error {
+	switch kind {
+	case "sink":
+		return c.SetSinkMute(nameOrID, muted)
+
+	case "source":
+		return c.SetSourceMute(nameOrID, muted)
+
+	case "sink-input":
+		index, err := parseIndex(nameOrID)
+		if err != nil {
+			return err
+		}
+		return c.SetSinkInputMute(index, muted)
+
+	case "source-output":
+		index, err := parseIndex(nameOrID)
+		if err != nil {
+			return err
+		}
+		return c.SetSourceOutputMute(index, muted)
+	}
+
+	return errNativeUnsupported
+}
+
+func moveAppNative(c *native.Client, kind string, appID string, deviceName string) error {
+	index, err := parseIndex(appID)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case "sink-input":
+		return c.MoveSinkInput(index, deviceName)
+
+	case "source-output":
+		return c.MoveSourceOutput(index, deviceName)
+	}
+
+	return errNativeUnsupported
+}
+
+func setPortNative(c *native.Client, kind string, name string, port string) error {
+	switch kind {
+	case "sink":
+		return c.SetSinkPort(name, port)
+
+	case "source":
+		return c.SetSourcePort(name, port)
+	}
+
+	return errNativeUnsupported
+}
+
+func setDefaultNative(c *native.Client, kind string, name string) error {
+	switch kind {
+	case "sink":
+		return c.SetDefaultSink(name)
+
+	case "source":
+		return c.SetDefaultSource(name)
+	}
+
+	return errNativeUnsupported
+}
@@ -0,0 +1,330 @@
+// Package native is a client for PulseAudio native protocol, the same one libpulse speaks.
+// PipeWire serves it too with pipewire-pulse, so it works on both without spawning pactl.
+package native
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Highest protocol version we understand. Server may speak older one, see Client.Version().
+const ProtocolVersion = 35
+
+// proplist in SET_CLIENT_NAME is required from here, older servers are not worth the effort
+const minProtocolVersion = 13
+
+const (
+	dialTimeout    = 2 * time.Second
+	requestTimeout = 5 * time.Second
+	cookieSize     = 256
+)
+
+type reply struct {
+	r   *tagReader
+	err error
+}
+
+// Client is single connection to the sound server. Safe for concurrent use.
+type Client struct {
+	conn    net.Conn
+	version uint32
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextTag uint32
+	pending map[uint32]chan reply
+	err     error
+
+	events chan Event
+	closed chan struct{}
+}
+
+// DefaultSocketPath follows libpulse lookup: $PULSE_SERVER, $PULSE_RUNTIME_PATH, $XDG_RUNTIME_DIR.
+func DefaultSocketPath() (string, error) {
+	if server := os.Getenv("PULSE_SERVER"); server != "" {
+		switch {
+		case len(server) > 5 && server[:5] == "unix:":
+			return server[5:], nil
+		case filepath.IsAbs(server):
+			return server, nil
+		default:
+			return "", fmt.Errorf("native: unsupported PULSE_SERVER %q", server)
+		}
+	}
+
+	if dir := os.Getenv("PULSE_RUNTIME_PATH"); dir != "" {
+		return filepath.Join(dir, "native"), nil
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "pulse", "native"), nil
+	}
+
+	return filepath.Join("/run/user", strconv.Itoa(os.Getuid()), "pulse", "native"), nil
+}
+
+// readCookie returns auth cookie, or zeros when there is none.
+// PipeWire and PulseAudio with same-user socket don't check it.
+func readCookie() []byte {
+	var paths []string
+
+	if p := os.Getenv("PULSE_COOKIE"); p != "" {
+		paths = append(paths, p)
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "pulse", "cookie"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".pulse-cookie"))
+	}
+
+	cookie := make([]byte, cookieSize)
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err == nil && len(b) >= cookieSize {
+			copy(cookie, b)
+			break
+		}
+	}
+
+	return cookie
+}
+
+// Dial connects to unix socket at path, or DefaultSocketPath() when path is empty,
+// and does the AUTH and SET_CLIENT_NAME handshake.
+func Dial(path string) (*Client, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultSocketPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    conn,
+		version: ProtocolVersion,
+		pending: map[uint32]chan reply{},
+		events:  make(chan Event, 64),
+		closed:  make(chan struct{}),
+	}
+
+	go c.readLoop()
+
+	if err := c.handshake(); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Client) handshake() error {
+	r, err := c.requestWithCreds(commandAuth, func(w *tagWriter) {
+		w.u32(ProtocolVersion)
+		w.arbitrary(readCookie())
+	})
+	if err != nil {
+		return err
+	}
+
+	// Upper bits are shm/memfd flags
+	serverVersion := r.u32() & 0xFFFF
+	if r.err != nil {
+		return r.err
+	}
+
+	c.version = min(serverVersion, ProtocolVersion)
+	if c.version < minProtocolVersion {
+		return fmt.Errorf("native: protocol version %d is too old", serverVersion)
+	}
+
+	binary, _ := os.Executable()
+	_, err = c.request(commandSetClientName, func(w *tagWriter) {
+		w.proplist(map[string]string{
+			"application.name":           "pulse-remote",
+			"application.process.id":     strconv.Itoa(os.Getpid()),
+			"application.process.binary": filepath.Base(binary),
+		})
+	})
+
+	return err
+}
+
+// Version is negotiated protocol version.
+func (c *Client) Version() uint32 {
+	return c.version
+}
+
+// Events delivers subscription events after Subscribe(). Closed when connection dies.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Done is closed when connection dies.
+func (c *Client) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Err returns reason why connection died, nil while it's alive.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) Close() error {
+	c.shutdown(ErrClosed)
+	return c.conn.Close()
+}
+
+// shutdown fails all pending requests. Only first reason is kept.
+func (c *Client) shutdown(reason error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+
+	c.err = reason
+	close(c.closed)
+}
+
+func (c *Client) readLoop() {
+	defer close(c.events)
+
+	for {
+		channel, payload, err := readPacket(c.conn)
+		if err != nil {
+			c.shutdown(fmt.Errorf("native: %w", err))
+			c.conn.Close()
+			return
+		}
+
+		// Audio data, we don't create streams
+		if channel != channelControl {
+			continue
+		}
+
+		r := &tagReader{buf: payload}
+		command := r.u32()
+		tag := r.u32()
+		if r.err != nil {
+			c.shutdown(r.err)
+			c.conn.Close()
+			return
+		}
+
+		switch command {
+		case commandReply:
+			c.resolve(tag, reply{r: r})
+		case commandError:
+			c.resolve(tag, reply{err: &Error{Code: ErrorCode(r.u32())}})
+		case commandSubscribeEvent:
+			event := eventFromWire(r.u32(), r.u32())
+			select {
+			case c.events <- event:
+			default:
+				// Nobody is reading fast enough. Events only say "something changed", next one will do.
+			}
+		}
+	}
+}
+
+func (c *Client) resolve(tag uint32, rep reply) {
+	c.mu.Lock()
+	ch, ok := c.pending[tag]
+	delete(c.pending, tag)
+	c.mu.Unlock()
+
+	if ok {
+		ch <- rep
+	}
+}
+
+func (c *Client) request(command uint32, args func(*tagWriter)) (*tagReader, error) {
+	return c.send(command, args, false)
+}
+
+// requestWithCreds attaches SCM_CREDENTIALS, server may authorize same uid without cookie.
+func (c *Client) requestWithCreds(command uint32, args func(*tagWriter)) (*tagReader, error) {
+	return c.send(command, args, true)
+}
+
+func (c *Client) send(command uint32, args func(*tagWriter), creds bool) (*tagReader, error) {
+	ch := make(chan reply, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	tag := c.nextTag
+	c.nextTag++
+	c.pending[tag] = ch
+	c.mu.Unlock()
+
+	w := &tagWriter{}
+	w.u32(command)
+	w.u32(tag)
+	if args != nil {
+		args(w)
+	}
+
+	if err := c.write(packetBytes(w.buf), creds); err != nil {
+		c.forget(tag)
+		return nil, err
+	}
+
+	timeout := time.NewTimer(requestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case rep := <-ch:
+		if e, ok := rep.err.(*Error); ok {
+			e.Command = commandName(command)
+		}
+		return rep.r, rep.err
+	case <-c.closed:
+		return nil, c.Err()
+	case <-timeout.C:
+		c.forget(tag)
+		return nil, fmt.Errorf("%s: %w", commandName(command), ErrTimeout)
+	}
+}
+
+func (c *Client) forget(tag uint32) {
+	c.mu.Lock()
+	delete(c.pending, tag)
+	c.mu.Unlock()
+}
+
+func (c *Client) write(frame []byte, creds bool) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+
+	if unixConn, ok := c.conn.(*net.UnixConn); ok && creds {
+		_, _, err := unixConn.WriteMsgUnix(frame, credentials(), nil)
+		return err
+	}
+
+	_, err := c.conn.Write(frame)
+	if errors.Is(err, net.ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
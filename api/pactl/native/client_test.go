@@ -0,0 +1,350 @@
+package native
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagstructRoundTrip(t *testing.T) {
+	w := &tagWriter{}
+	w.u32(42)
+	w.str("alsa_output.speakers")
+	w.nameOrNull("")
+	w.boolean(true)
+	w.boolean(false)
+	w.arbitrary([]byte{1, 2, 3})
+	w.cvolume(CVolume{VolumeNorm, VolumeNorm / 2})
+	w.proplist(map[string]string{"application.name": "pulse-remote", "media.name": "Zażółć"})
+
+	r := &tagReader{buf: w.buf}
+
+	if got := r.u32(); got != 42 {
+		t.Errorf("u32: got %d", got)
+	}
+	if got := r.str(); got != "alsa_output.speakers" {
+		t.Errorf("str: got %q", got)
+	}
+	if got := r.str(); got != "" {
+		t.Errorf("NULL str: got %q", got)
+	}
+	if !r.boolean() || r.boolean() {
+		t.Errorf("boolean: wrong values")
+	}
+	if got := r.arbitrary(); !reflect.DeepEqual(got, []byte{1, 2, 3}) {
+		t.Errorf("arbitrary: got %v", got)
+	}
+	if got := r.cvolume(); !reflect.DeepEqual(got, CVolume{VolumeNorm, VolumeNorm / 2}) {
+		t.Errorf("cvolume: got %v", got)
+	}
+	if got := r.proplist(); got["media.name"] != "Zażółć" || got["application.name"] != "pulse-remote" {
+		t.Errorf("proplist: got %v", got)
+	}
+	if r.err != nil || !r.done() {
+		t.Errorf("Expected all data consumed without error, err=%v left=%d", r.err, len(r.buf))
+	}
+
+	t.Run("WrongTag", func(t *testing.T) {
+		r := &tagReader{buf: w.buf}
+		r.str()
+		if r.err == nil {
+			t.Errorf("Expected error reading u32 as string")
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		r := &tagReader{buf: w.buf[:3]}
+		r.u32()
+		if r.err == nil {
+			t.Errorf("Expected error on truncated data")
+		}
+	})
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		Percent float64
+		Raw     uint32
+	}{
+		{0, 0},
+		{50, VolumeNorm / 2},
+		{100, VolumeNorm},
+		{150, VolumeNorm * 3 / 2},
+	}
+
+	for _, tt := range tests {
+		if got := FromPercent(tt.Percent); got != tt.Raw {
+			t.Errorf("FromPercent(%v) = %d, want %d", tt.Percent, got, tt.Raw)
+		}
+		if got := Percent(tt.Raw); got != int(tt.Percent) {
+			t.Errorf("Percent(%d) = %d, want %v", tt.Raw, got, tt.Percent)
+		}
+	}
+}
+
+func TestDial(t *testing.T) {
+	t.Run("Negotiate", func(t *testing.T) {
+		c := dialFake(t, newFakeServer(t, 32))
+		if c.Version() != 32 {
+			t.Errorf("Expected protocol version 32, got %d", c.Version())
+		}
+	})
+
+	t.Run("NewerServer", func(t *testing.T) {
+		c := dialFake(t, newFakeServer(t, ProtocolVersion+5))
+		if c.Version() != ProtocolVersion {
+			t.Errorf("Expected protocol version %d, got %d", ProtocolVersion, c.Version())
+		}
+	})
+
+	t.Run("TooOld", func(t *testing.T) {
+		s := newFakeServer(t, 12)
+		if _, err := Dial(s.path); err == nil {
+			t.Errorf("Expected error for protocol version 12")
+		}
+	})
+
+	t.Run("NoSocket", func(t *testing.T) {
+		if _, err := Dial(t.TempDir() + "/nothing"); err == nil {
+			t.Errorf("Expected error without socket")
+		}
+	})
+}
+
+func TestSinks(t *testing.T) {
+	for _, version := range []uint32{23, 32, ProtocolVersion} {
+		c := dialFake(t, newFakeServer(t, version))
+
+		sinks, err := c.Sinks()
+		if err != nil {
+			t.Fatalf("v%d Sinks: %v", version, err)
+		}
+		if len(sinks) != 2 {
+			t.Fatalf("v%d Expected 2 sinks, got %d", version, len(sinks))
+		}
+
+		s := sinks[0]
+		if s.Name != "alsa_output.speakers" || s.Description != "Speakers" {
+			t.Errorf("v%d Wrong sink %q %q", version, s.Name, s.Description)
+		}
+		if !reflect.DeepEqual(s.ChannelMap.Names(), []string{"front-left", "front-right"}) {
+			t.Errorf("v%d Wrong channel map %v", version, s.ChannelMap.Names())
+		}
+		if Percent(s.Volume[0]) != 50 {
+			t.Errorf("v%d Expected volume 50%%, got %d%%", version, Percent(s.Volume[0]))
+		}
+		if len(s.Ports) != 2 || s.ActivePort != "analog-output-headphones" {
+			t.Errorf("v%d Wrong ports %+v active %q", version, s.Ports, s.ActivePort)
+		}
+		if s.Props["device.description"] != "Speakers" {
+			t.Errorf("v%d Wrong props %v", version, s.Props)
+		}
+		if !sinks[1].Mute || sinks[1].ChannelMap.Names()[0] != "mono" {
+			t.Errorf("v%d Wrong second sink %+v", version, sinks[1])
+		}
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, ProtocolVersion))
+
+	info, err := c.ServerInfo()
+	if err != nil {
+		t.Fatalf("ServerInfo: %v", err)
+	}
+	if info.DefaultSinkName != "alsa_output.speakers" || info.DefaultSourceName != "alsa_input.mic" {
+		t.Errorf("Wrong defaults %+v", info)
+	}
+}
+
+func TestSinkInputs(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, ProtocolVersion))
+
+	if err := c.MoveSinkInput(91, "bluez_output.headset"); err != nil {
+		t.Fatalf("MoveSinkInput: %v", err)
+	}
+
+	sinkInputs, err := c.SinkInputs()
+	if err != nil {
+		t.Fatalf("SinkInputs: %v", err)
+	}
+	if len(sinkInputs) != 1 || sinkInputs[0].Sink != 56 || sinkInputs[0].Props["application.name"] != "Firefox" {
+		t.Errorf("Wrong sink inputs %+v", sinkInputs)
+	}
+}
+
+func TestSourceOutputs(t *testing.T) {
+	t.Run("NoVolume", func(t *testing.T) {
+		c := dialFake(t, newFakeServer(t, 21))
+
+		sourceOutputs, err := c.SourceOutputs()
+		if err != nil {
+			t.Fatalf("SourceOutputs: %v", err)
+		}
+		if len(sourceOutputs) != 1 || sourceOutputs[0].Volume != nil || sourceOutputs[0].Props["application.name"] != "Zoom" {
+			t.Errorf("Wrong source outputs %+v", sourceOutputs)
+		}
+	})
+
+	c := dialFake(t, newFakeServer(t, ProtocolVersion))
+
+	if err := c.SetSourceOutputVolume(93, CVolume{VolumeNorm / 2}); err != nil {
+		t.Fatalf("SetSourceOutputVolume: %v", err)
+	}
+	if err := c.SetSourceOutputMute(93, true); err != nil {
+		t.Fatalf("SetSourceOutputMute: %v", err)
+	}
+
+	so, err := c.SourceOutput(93)
+	if err != nil {
+		t.Fatalf("SourceOutput: %v", err)
+	}
+	if so.Source != 58 || Percent(so.Volume[0]) != 50 || !so.Mute {
+		t.Errorf("Wrong source output %+v", so)
+	}
+
+	if _, err := c.SourceOutput(1); asError(err) == nil || asError(err).Code != CodeNoEntity {
+		t.Errorf("Expected NoEntity for missing source output, got %v", err)
+	}
+}
+
+func TestCards(t *testing.T) {
+	for _, version := range []uint32{23, 29, ProtocolVersion} {
+		c := dialFake(t, newFakeServer(t, version))
+
+		cards, err := c.Cards()
+		if err != nil {
+			t.Fatalf("v%d Cards: %v", version, err)
+		}
+		if len(cards) != 1 {
+			t.Fatalf("v%d Expected 1 card, got %d", version, len(cards))
+		}
+
+		card := cards[0]
+		if card.Name != "bluez_card.headset" || card.ActiveProfile != "a2dp-sink" || card.Props["device.description"] != "Headset" {
+			t.Errorf("v%d Wrong card %+v", version, card)
+		}
+		if len(card.Profiles) != 3 || card.Profiles[1].Sources != 1 {
+			t.Errorf("v%d Wrong profiles %+v", version, card.Profiles)
+		}
+		// Availability is only known since protocol 29
+		if card.Profiles[1].Available != (version < 29) {
+			t.Errorf("v%d Wrong availability of %q", version, card.Profiles[1].Name)
+		}
+	}
+
+	c := dialFake(t, newFakeServer(t, ProtocolVersion))
+
+	if err := c.SetCardProfile("bluez_card.headset", "headset-head-unit"); err != nil {
+		t.Fatalf("SetCardProfile: %v", err)
+	}
+	card, err := c.CardByName("bluez_card.headset")
+	if err != nil {
+		t.Fatalf("CardByName: %v", err)
+	}
+	if card.ActiveProfile != "headset-head-unit" || card.Ports[0].Profiles[1] != "headset-head-unit" {
+		t.Errorf("Profile not applied %+v", card)
+	}
+
+	if err := c.SetCardProfile("bluez_card.headset", "surround-71"); asError(err) == nil {
+		t.Errorf("Expected error for unknown profile, got %v", err)
+	}
+}
+
+func TestSetSinkVolume(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, ProtocolVersion))
+
+	if err := c.SetSinkVolume("alsa_output.speakers", CVolume{VolumeNorm / 4, VolumeNorm}); err != nil {
+		t.Fatalf("SetSinkVolume: %v", err)
+	}
+	if err := c.SetSinkMute("alsa_output.speakers", true); err != nil {
+		t.Fatalf("SetSinkMute: %v", err)
+	}
+	if err := c.SetSinkPort("alsa_output.speakers", "analog-output-lineout"); err != nil {
+		t.Fatalf("SetSinkPort: %v", err)
+	}
+
+	sink, err := c.SinkByName("alsa_output.speakers")
+	if err != nil {
+		t.Fatalf("SinkByName: %v", err)
+	}
+	if Percent(sink.Volume[0]) != 25 || Percent(sink.Volume[1]) != 100 || !sink.Mute {
+		t.Errorf("Volume not applied, got %v mute %v", sink.Volume, sink.Mute)
+	}
+	if sink.ActivePort != "analog-output-lineout" {
+		t.Errorf("Port not applied, got %q", sink.ActivePort)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := dialFake(t, newFakeServer(t, ProtocolVersion))
+
+	err := c.SetDefaultSink("does.not.exist")
+	e := asError(err)
+	if e == nil {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if e.Code != CodeNoEntity || e.Command != "SET_DEFAULT_SINK" {
+		t.Errorf("Wrong error %+v", e)
+	}
+	if e.Error() != "SET_DEFAULT_SINK: No such entity" {
+		t.Errorf("Wrong message %q", e.Error())
+	}
+
+	// Connection is still usable after error reply
+	if _, err := c.Sinks(); err != nil {
+		t.Errorf("Sinks after error: %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := newFakeServer(t, ProtocolVersion)
+	listener := dialFake(t, s)
+	c := dialFake(t, s)
+
+	if err := listener.Subscribe(SubscribeSink | SubscribeServer); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	c.SetSinkMute("bluez_output.headset", false)
+	c.SetDefaultSink("bluez_output.headset")
+
+	want := []Event{
+		{Facility: "sink", Type: "change", Index: 56},
+		{Facility: "server", Type: "change", Index: InvalidIndex},
+	}
+
+	for _, w := range want {
+		select {
+		case ev := <-listener.Events():
+			if ev != w {
+				t.Errorf("Expected %+v, got %+v", w, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for %+v", w)
+		}
+	}
+}
+
+func TestConnectionLost(t *testing.T) {
+	s := newFakeServer(t, ProtocolVersion)
+	c := dialFake(t, s)
+
+	s.dropConnections()
+
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Done() to be closed")
+	}
+
+	if _, err := c.Sinks(); err == nil {
+		t.Errorf("Expected error on dead connection")
+	}
+	if c.Err() == nil {
+		t.Errorf("Expected Err() on dead connection")
+	}
+	if _, ok := <-c.Events(); ok {
+		t.Errorf("Expected Events() to be closed")
+	}
+}
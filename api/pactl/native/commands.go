@@ -0,0 +1,579 @@
+package native
+
+import "fmt"
+
+// Commands from pulsecore/native-common.h, only the ones we use
+const (
+	commandError              uint32 = 0
+	commandReply              uint32 = 2
+	commandAuth               uint32 = 8
+	commandSetClientName      uint32 = 9
+	commandGetServerInfo      uint32 = 20
+	commandGetSinkInfo        uint32 = 21
+	commandGetSinkInfoList    uint32 = 22
+	commandGetSourceInfo      uint32 = 23
+	commandGetSourceInfoList  uint32 = 24
+	commandGetSinkInputInfo   uint32 = 29
+	commandGetSinkInputList   uint32 = 30
+	commandGetSourceOutput    uint32 = 31
+	commandGetSourceOutputs   uint32 = 32
+	commandSubscribe          uint32 = 35
+	commandSetSinkVolume      uint32 = 36
+	commandSetSinkInputVolume uint32 = 37
+	commandSetSourceVolume    uint32 = 38
+	commandSetSinkMute        uint32 = 39
+	commandSetSourceMute      uint32 = 40
+	commandSetDefaultSink     uint32 = 44
+	commandSetDefaultSource   uint32 = 45
+	commandSubscribeEvent     uint32 = 66
+	commandMoveSinkInput      uint32 = 67
+	commandMoveSourceOutput   uint32 = 68
+	commandSetSinkInputMute   uint32 = 69
+	commandGetCardInfo        uint32 = 88
+	commandGetCardInfoList    uint32 = 89
+	commandSetCardProfile     uint32 = 90
+	commandSetSinkPort        uint32 = 96
+	commandSetSourcePort      uint32 = 97
+	commandSetSourceOutVolume uint32 = 98
+	commandSetSourceOutMute   uint32 = 99
+)
+
+var commandNames = map[uint32]string{
+	commandAuth:               "AUTH",
+	commandSetClientName:      "SET_CLIENT_NAME",
+	commandGetServerInfo:      "GET_SERVER_INFO",
+	commandGetSinkInfo:        "GET_SINK_INFO",
+	commandGetSinkInfoList:    "GET_SINK_INFO_LIST",
+	commandGetSourceInfo:      "GET_SOURCE_INFO",
+	commandGetSourceInfoList:  "GET_SOURCE_INFO_LIST",
+	commandGetSinkInputInfo:   "GET_SINK_INPUT_INFO",
+	commandGetSinkInputList:   "GET_SINK_INPUT_INFO_LIST",
+	commandGetSourceOutput:    "GET_SOURCE_OUTPUT_INFO",
+	commandGetSourceOutputs:   "GET_SOURCE_OUTPUT_INFO_LIST",
+	commandSubscribe:          "SUBSCRIBE",
+	commandSetSinkVolume:      "SET_SINK_VOLUME",
+	commandSetSinkInputVolume: "SET_SINK_INPUT_VOLUME",
+	commandSetSourceVolume:    "SET_SOURCE_VOLUME",
+	commandSetSinkMute:        "SET_SINK_MUTE",
+	commandSetSourceMute:      "SET_SOURCE_MUTE",
+	commandSetDefaultSink:     "SET_DEFAULT_SINK",
+	commandSetDefaultSource:   "SET_DEFAULT_SOURCE",
+	commandMoveSinkInput:      "MOVE_SINK_INPUT",
+	commandMoveSourceOutput:   "MOVE_SOURCE_OUTPUT",
+	commandSetSinkInputMute:   "SET_SINK_INPUT_MUTE",
+	commandGetCardInfo:        "GET_CARD_INFO",
+	commandGetCardInfoList:    "GET_CARD_INFO_LIST",
+	commandSetCardProfile:     "SET_CARD_PROFILE",
+	commandSetSinkPort:        "SET_SINK_PORT",
+	commandSetSourcePort:      "SET_SOURCE_PORT",
+	commandSetSourceOutVolume: "SET_SOURCE_OUTPUT_VOLUME",
+	commandSetSourceOutMute:   "SET_SOURCE_OUTPUT_MUTE",
+}
+
+func commandName(command uint32) string {
+	if name, ok := commandNames[command]; ok {
+		return name
+	}
+	return fmt.Sprintf("COMMAND_%d", command)
+}
+
+func (c *Client) ServerInfo() (ServerInfo, error) {
+	r, err := c.request(commandGetServerInfo, nil)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+
+	info := ServerInfo{
+		PackageName:    r.str(),
+		PackageVersion: r.str(),
+		UserName:       r.str(),
+		HostName:       r.str(),
+	}
+	r.sampleSpec()
+	info.DefaultSinkName = r.str()
+	info.DefaultSourceName = r.str()
+
+	return info, r.err
+}
+
+func (c *Client) readPorts(r *tagReader) ([]PortInfo, string) {
+	ports := make([]PortInfo, r.count())
+	for i := range ports {
+		p := &ports[i]
+		p.Name = r.str()
+		p.Description = r.str()
+		p.Priority = r.u32()
+		if c.version >= 24 {
+			p.Available = r.u32()
+		}
+		if c.version >= 34 {
+			p.AvailabilityGroup = r.str()
+			p.Type = r.u32()
+		}
+	}
+
+	return ports, r.str()
+}
+
+func (c *Client) readSink(r *tagReader) SinkInfo {
+	s := SinkInfo{
+		Index:       r.u32(),
+		Name:        r.str(),
+		Description: r.str(),
+		SampleSpec:  r.sampleSpec(),
+		ChannelMap:  r.channelMap(),
+		OwnerModule: r.u32(),
+		Volume:      r.cvolume(),
+		Mute:        r.boolean(),
+	}
+	s.MonitorSourceIndex = r.u32()
+	s.MonitorSourceName = r.str()
+	r.usec() // latency
+	s.Driver = r.str()
+	s.Flags = r.u32()
+
+	if c.version >= 13 {
+		s.Props = r.proplist()
+		r.usec() // configured latency
+	}
+	if c.version >= 15 {
+		s.BaseVolume = r.volume()
+		s.State = r.u32()
+		r.u32() // volume steps
+		s.Card = r.u32()
+	}
+	if c.version >= 16 {
+		s.Ports, s.ActivePort = c.readPorts(r)
+	}
+	if c.version >= 21 {
+		for range r.u8() {
+			r.formatInfo()
+		}
+	}
+
+	return s
+}
+
+func (c *Client) readSource(r *tagReader) SourceInfo {
+	s := SourceInfo{
+		Index:       r.u32(),
+		Name:        r.str(),
+		Description: r.str(),
+		SampleSpec:  r.sampleSpec(),
+		ChannelMap:  r.channelMap(),
+		OwnerModule: r.u32(),
+		Volume:      r.cvolume(),
+		Mute:        r.boolean(),
+	}
+	s.MonitorOfSinkIndex = r.u32()
+	s.MonitorOfSinkName = r.str()
+	r.usec() // latency
+	s.Driver = r.str()
+	s.Flags = r.u32()
+
+	if c.version >= 13 {
+		s.Props = r.proplist()
+		r.usec() // configured latency
+	}
+	if c.version >= 15 {
+		s.BaseVolume = r.volume()
+		s.State = r.u32()
+		r.u32() // volume steps
+		s.Card = r.u32()
+	}
+	if c.version >= 16 {
+		s.Ports, s.ActivePort = c.readPorts(r)
+	}
+	if c.version >= 22 {
+		for range r.u8() {
+			r.formatInfo()
+		}
+	}
+
+	return s
+}
+
+func (c *Client) readSinkInput(r *tagReader) SinkInputInfo {
+	s := SinkInputInfo{
+		Index:       r.u32(),
+		Name:        r.str(),
+		OwnerModule: r.u32(),
+		Client:      r.u32(),
+		Sink:        r.u32(),
+		SampleSpec:  r.sampleSpec(),
+		ChannelMap:  r.channelMap(),
+		Volume:      r.cvolume(),
+	}
+	r.usec() // buffer latency
+	r.usec() // sink latency
+	r.str()  // resample method
+	s.Driver = r.str()
+
+	if c.version >= 11 {
+		s.Mute = r.boolean()
+	}
+	if c.version >= 13 {
+		s.Props = r.proplist()
+	}
+	if c.version >= 19 {
+		s.Corked = r.boolean()
+	}
+	if c.version >= 20 {
+		s.HasVolume = r.boolean()
+		s.VolumeWritable = r.boolean()
+	}
+	if c.version >= 21 {
+		r.formatInfo()
+	}
+
+	return s
+}
+
+func (c *Client) readSourceOutput(r *tagReader) SourceOutputInfo {
+	s := SourceOutputInfo{
+		Index:       r.u32(),
+		Name:        r.str(),
+		OwnerModule: r.u32(),
+		Client:      r.u32(),
+		Source:      r.u32(),
+		SampleSpec:  r.sampleSpec(),
+		ChannelMap:  r.channelMap(),
+	}
+	r.usec() // buffer latency
+	r.usec() // source latency
+	r.str()  // resample method
+	s.Driver = r.str()
+
+	if c.version >= 13 {
+		s.Props = r.proplist()
+	}
+	if c.version >= 19 {
+		s.Corked = r.boolean()
+	}
+	// Recording streams have no volume before protocol 22
+	if c.version >= 22 {
+		s.Volume = r.cvolume()
+		s.Mute = r.boolean()
+		s.HasVolume = r.boolean()
+		s.VolumeWritable = r.boolean()
+		r.formatInfo()
+	}
+
+	return s
+}
+
+func (c *Client) readCard(r *tagReader) CardInfo {
+	card := CardInfo{
+		Index:       r.u32(),
+		Name:        r.str(),
+		OwnerModule: r.u32(),
+		Driver:      r.str(),
+	}
+
+	card.Profiles = make([]CardProfileInfo, r.count())
+	for i := range card.Profiles {
+		p := &card.Profiles[i]
+		p.Name = r.str()
+		p.Description = r.str()
+		p.Sinks = r.u32()
+		p.Sources = r.u32()
+		p.Priority = r.u32()
+		p.Available = true
+		if c.version >= 29 {
+			p.Available = r.u32() != 0
+		}
+	}
+	card.ActiveProfile = r.str()
+	card.Props = r.proplist()
+
+	if c.version >= 26 {
+		card.Ports = make([]CardPortInfo, r.count())
+		for i := range card.Ports {
+			p := &card.Ports[i]
+			p.Name = r.str()
+			p.Description = r.str()
+			p.Priority = r.u32()
+			p.Available = r.u32()
+			p.Direction = r.u8()
+			r.proplist()
+			p.Profiles = make([]string, r.count())
+			for j := range p.Profiles {
+				p.Profiles[j] = r.str()
+			}
+			if c.version >= 27 {
+				r.s64() // latency offset
+			}
+			if c.version >= 34 {
+				p.AvailabilityGroup = r.str()
+				p.Type = r.u32()
+			}
+		}
+	}
+
+	return card
+}
+
+// byIndexOrName fills (index, name) pair that most commands use to address object
+func byIndexOrName(w *tagWriter, index uint32, name string) {
+	if name != "" {
+		index = InvalidIndex
+	}
+	w.u32(index)
+	w.nameOrNull(name)
+}
+
+func (c *Client) Sinks() ([]SinkInfo, error) {
+	r, err := c.request(commandGetSinkInfoList, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinks []SinkInfo
+	for !r.done() {
+		sinks = append(sinks, c.readSink(r))
+	}
+
+	return sinks, r.err
+}
+
+func (c *Client) SinkByName(name string) (SinkInfo, error) {
+	r, err := c.request(commandGetSinkInfo, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+	})
+	if err != nil {
+		return SinkInfo{}, err
+	}
+
+	s := c.readSink(r)
+	return s, r.err
+}
+
+func (c *Client) Sources() ([]SourceInfo, error) {
+	r, err := c.request(commandGetSourceInfoList, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []SourceInfo
+	for !r.done() {
+		sources = append(sources, c.readSource(r))
+	}
+
+	return sources, r.err
+}
+
+func (c *Client) SourceByName(name string) (SourceInfo, error) {
+	r, err := c.request(commandGetSourceInfo, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+	})
+	if err != nil {
+		return SourceInfo{}, err
+	}
+
+	s := c.readSource(r)
+	return s, r.err
+}
+
+func (c *Client) SinkInputs() ([]SinkInputInfo, error) {
+	r, err := c.request(commandGetSinkInputList, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinkInputs []SinkInputInfo
+	for !r.done() {
+		sinkInputs = append(sinkInputs, c.readSinkInput(r))
+	}
+
+	return sinkInputs, r.err
+}
+
+func (c *Client) SinkInput(index uint32) (SinkInputInfo, error) {
+	r, err := c.request(commandGetSinkInputInfo, func(w *tagWriter) {
+		w.u32(index)
+	})
+	if err != nil {
+		return SinkInputInfo{}, err
+	}
+
+	s := c.readSinkInput(r)
+	return s, r.err
+}
+
+func (c *Client) SourceOutputs() ([]SourceOutputInfo, error) {
+	r, err := c.request(commandGetSourceOutputs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceOutputs []SourceOutputInfo
+	for !r.done() {
+		sourceOutputs = append(sourceOutputs, c.readSourceOutput(r))
+	}
+
+	return sourceOutputs, r.err
+}
+
+func (c *Client) SourceOutput(index uint32) (SourceOutputInfo, error) {
+	r, err := c.request(commandGetSourceOutput, func(w *tagWriter) {
+		w.u32(index)
+	})
+	if err != nil {
+		return SourceOutputInfo{}, err
+	}
+
+	s := c.readSourceOutput(r)
+	return s, r.err
+}
+
+func (c *Client) Cards() ([]CardInfo, error) {
+	r, err := c.request(commandGetCardInfoList, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []CardInfo
+	for !r.done() {
+		cards = append(cards, c.readCard(r))
+	}
+
+	return cards, r.err
+}
+
+func (c *Client) CardByName(name string) (CardInfo, error) {
+	r, err := c.request(commandGetCardInfo, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+	})
+	if err != nil {
+		return CardInfo{}, err
+	}
+
+	card := c.readCard(r)
+	return card, r.err
+}
+
+func (c *Client) SetSinkVolume(name string, volume CVolume) error {
+	_, err := c.request(commandSetSinkVolume, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+		w.cvolume(volume)
+	})
+	return err
+}
+
+func (c *Client) SetSourceVolume(name string, volume CVolume) error {
+	_, err := c.request(commandSetSourceVolume, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+		w.cvolume(volume)
+	})
+	return err
+}
+
+func (c *Client) SetSinkInputVolume(index uint32, volume CVolume) error {
+	_, err := c.request(commandSetSinkInputVolume, func(w *tagWriter) {
+		w.u32(index)
+		w.cvolume(volume)
+	})
+	return err
+}
+
+func (c *Client) SetSourceOutputVolume(index uint32, volume CVolume) error {
+	_, err := c.request(commandSetSourceOutVolume, func(w *tagWriter) {
+		w.u32(index)
+		w.cvolume(volume)
+	})
+	return err
+}
+
+func (c *Client) SetSinkMute(name string, mute bool) error {
+	_, err := c.request(commandSetSinkMute, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+		w.boolean(mute)
+	})
+	return err
+}
+
+func (c *Client) SetSourceMute(name string, mute bool) error {
+	_, err := c.request(commandSetSourceMute, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+		w.boolean(mute)
+	})
+	return err
+}
+
+func (c *Client) SetSinkInputMute(index uint32, mute bool) error {
+	_, err := c.request(commandSetSinkInputMute, func(w *tagWriter) {
+		w.u32(index)
+		w.boolean(mute)
+	})
+	return err
+}
+
+func (c *Client) SetSourceOutputMute(index uint32, mute bool) error {
+	_, err := c.request(commandSetSourceOutMute, func(w *tagWriter) {
+		w.u32(index)
+		w.boolean(mute)
+	})
+	return err
+}
+
+func (c *Client) SetDefaultSink(name string) error {
+	_, err := c.request(commandSetDefaultSink, func(w *tagWriter) {
+		w.str(name)
+	})
+	return err
+}
+
+func (c *Client) SetDefaultSource(name string) error {
+	_, err := c.request(commandSetDefaultSource, func(w *tagWriter) {
+		w.str(name)
+	})
+	return err
+}
+
+func (c *Client) MoveSinkInput(index uint32, sinkName string) error {
+	_, err := c.request(commandMoveSinkInput, func(w *tagWriter) {
+		w.u32(index)
+		byIndexOrName(w, InvalidIndex, sinkName)
+	})
+	return err
+}
+
+func (c *Client) MoveSourceOutput(index uint32, sourceName string) error {
+	_, err := c.request(commandMoveSourceOutput, func(w *tagWriter) {
+		w.u32(index)
+		byIndexOrName(w, InvalidIndex, sourceName)
+	})
+	return err
+}
+
+func (c *Client) SetCardProfile(cardName string, profile string) error {
+	_, err := c.request(commandSetCardProfile, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, cardName)
+		w.str(profile)
+	})
+	return err
+}
+
+func (c *Client) SetSinkPort(name string, port string) error {
+	_, err := c.request(commandSetSinkPort, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+		w.str(port)
+	})
+	return err
+}
+
+func (c *Client) SetSourcePort(name string, port string) error {
+	_, err := c.request(commandSetSourcePort, func(w *tagWriter) {
+		byIndexOrName(w, InvalidIndex, name)
+		w.str(port)
+	})
+	return err
+}
+
+// Subscribe asks server for events of given mask, they will arrive in Events().
+func (c *Client) Subscribe(mask uint32) error {
+	_, err := c.request(commandSubscribe, func(w *tagWriter) {
+		w.u32(mask)
+	})
+	return err
+}
@@ -0,0 +1,15 @@
+package native
+
+import (
+	"os"
+	"syscall"
+)
+
+// credentials is SCM_CREDENTIALS ancillary data, server may authorize same uid without cookie
+func credentials() []byte {
+	return syscall.UnixCredentials(&syscall.Ucred{
+		Pid: int32(os.Getpid()),
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	})
+}
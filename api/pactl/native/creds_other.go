@@ -0,0 +1,8 @@
+//go:build !linux
+
+package native
+
+// credentials is empty, SCM_CREDENTIALS exists only on Linux. Cookie alone authenticates elsewhere.
+func credentials() []byte {
+	return nil
+}
@@ -0,0 +1,92 @@
+package native
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode is pa_error_code_t sent by server in ERROR reply
+type ErrorCode uint32
+
+const (
+	CodeOK ErrorCode = iota
+	CodeAccess
+	CodeCommand
+	CodeInvalid
+	CodeExist
+	CodeNoEntity
+	CodeConnectionRefused
+	CodeProtocol
+	CodeTimeout
+	CodeAuthKey
+	CodeInternal
+	CodeConnectionTerminated
+	CodeKilled
+	CodeInvalidServer
+	CodeModInitFailed
+	CodeBadState
+	CodeNoData
+	CodeVersion
+	CodeTooLarge
+	CodeNotSupported
+	CodeUnknown
+	CodeNoExtension
+	CodeObsolete
+	CodeNotImplemented
+	CodeForked
+	CodeIO
+	CodeBusy
+)
+
+// Same wording as pa_strerror()
+var errorTexts = []string{
+	"OK",
+	"Access denied",
+	"Unknown command",
+	"Invalid argument",
+	"Entity exists",
+	"No such entity",
+	"Connection refused",
+	"Protocol error",
+	"Timeout",
+	"No authentication key",
+	"Internal error",
+	"Connection terminated",
+	"Entity killed",
+	"Invalid server",
+	"Module initialization failed",
+	"Bad state",
+	"No data",
+	"Incompatible protocol version",
+	"Too large",
+	"Not supported",
+	"Unknown error code",
+	"No such extension",
+	"Obsolete functionality",
+	"Missing implementation",
+	"Client forked",
+	"Input/Output error",
+	"Device or resource busy",
+}
+
+func (c ErrorCode) String() string {
+	if int(c) < len(errorTexts) {
+		return errorTexts[c]
+	}
+	return fmt.Sprintf("error code %d", uint32(c))
+}
+
+// Error is returned when server rejects a command.
+type Error struct {
+	Command string
+	Code    ErrorCode
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Command, e.Code)
+}
+
+var (
+	ErrClosed  = errors.New("native: connection closed")
+	ErrTimeout = errors.New("native: request timed out")
+)
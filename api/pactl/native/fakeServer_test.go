@@ -0,0 +1,584 @@
+package native
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// Encoders that only fake server needs
+
+func (w *tagWriter) u8(v uint8) {
+	w.buf = append(w.buf, tagU8, v)
+}
+
+func (w *tagWriter) usec(v uint64) {
+	w.buf = append(w.buf, tagUsec)
+	w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+}
+
+func (w *tagWriter) s64(v int64) {
+	w.buf = append(w.buf, tagS64)
+	w.buf = binary.BigEndian.AppendUint64(w.buf, uint64(v))
+}
+
+func (w *tagWriter) volume(v uint32) {
+	w.buf = append(w.buf, tagVolume)
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *tagWriter) sampleSpec(s SampleSpec) {
+	w.buf = append(w.buf, tagSampleSpec, s.Format, s.Channels)
+	w.buf = binary.BigEndian.AppendUint32(w.buf, s.Rate)
+}
+
+func (w *tagWriter) channelMap(m ChannelMap) {
+	w.buf = append(w.buf, tagChannelMap, uint8(len(m)))
+	w.buf = append(w.buf, m...)
+}
+
+func (w *tagWriter) formatInfo() {
+	w.buf = append(w.buf, tagFormatInfo)
+	w.u8(1) // PA_ENCODING_PCM
+	w.proplist(nil)
+}
+
+// fakeServer speaks enough of the native protocol to test Client without sound server.
+type fakeServer struct {
+	t       *testing.T
+	path    string
+	version uint32
+
+	mu          sync.Mutex
+	defaultSink string
+	sinks       []*SinkInfo
+	sinkInputs  []*SinkInputInfo
+	sourceOuts  []*SourceOutputInfo
+	cards       []*CardInfo
+	subscribed  map[net.Conn]uint32
+	conns       []net.Conn
+}
+
+func newFakeServer(t *testing.T, version uint32) *fakeServer {
+	t.Helper()
+
+	s := &fakeServer{
+		t:           t,
+		path:        filepath.Join(t.TempDir(), "native"),
+		version:     version,
+		defaultSink: "alsa_output.speakers",
+		subscribed:  map[net.Conn]uint32{},
+		sinks: []*SinkInfo{
+			{
+				Index:       55,
+				Name:        "alsa_output.speakers",
+				Description: "Speakers",
+				SampleSpec:  SampleSpec{Format: 3, Channels: 2, Rate: 48000},
+				ChannelMap:  ChannelMap{1, 2},
+				Volume:      CVolume{VolumeNorm / 2, VolumeNorm / 2},
+				Props:       map[string]string{"device.description": "Speakers"},
+				Ports: []PortInfo{
+					{Name: "analog-output-lineout", Description: "Line Out", Priority: 9000, Available: PortAvailableNo},
+					{Name: "analog-output-headphones", Description: "Headphones", Priority: 9900, Available: PortAvailableYes},
+				},
+				ActivePort: "analog-output-headphones",
+			},
+			{
+				Index:       56,
+				Name:        "bluez_output.headset",
+				Description: "Headset",
+				SampleSpec:  SampleSpec{Format: 3, Channels: 1, Rate: 16000},
+				ChannelMap:  ChannelMap{0},
+				Volume:      CVolume{VolumeNorm},
+				Mute:        true,
+			},
+		},
+		sinkInputs: []*SinkInputInfo{
+			{
+				Index:      91,
+				Name:       "Playback",
+				Sink:       55,
+				ChannelMap: ChannelMap{1, 2},
+				Volume:     CVolume{VolumeNorm, VolumeNorm},
+				Props:      map[string]string{"application.name": "Firefox"},
+			},
+		},
+		sourceOuts: []*SourceOutputInfo{
+			{
+				Index:      93,
+				Name:       "Capture",
+				Source:     58,
+				ChannelMap: ChannelMap{0},
+				Volume:     CVolume{VolumeNorm},
+				Props:      map[string]string{"application.name": "Zoom"},
+			},
+		},
+		cards: []*CardInfo{
+			{
+				Index:  60,
+				Name:   "bluez_card.headset",
+				Driver: "module-bluez5-device.c",
+				Profiles: []CardProfileInfo{
+					{Name: "a2dp-sink", Description: "High Fidelity Playback (A2DP Sink)", Sinks: 1, Priority: 40, Available: true},
+					{Name: "headset-head-unit", Description: "Headset Head Unit (HSP/HFP)", Sinks: 1, Sources: 1, Priority: 30, Available: false},
+					{Name: "off", Description: "Off", Available: true},
+				},
+				ActiveProfile: "a2dp-sink",
+				Props:         map[string]string{"device.description": "Headset"},
+				Ports: []CardPortInfo{
+					{
+						PortInfo:  PortInfo{Name: "headset-output", Description: "Headset", Available: PortAvailableYes},
+						Direction: 1,
+						Profiles:  []string{"a2dp-sink", "headset-head-unit"},
+					},
+				},
+			},
+		},
+	}
+
+	ln, err := net.Listen("unix", s.path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ln.Close()
+		s.mu.Lock()
+		for _, conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+// dropConnections simulates server restart
+func (s *fakeServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *fakeServer) send(conn net.Conn, w *tagWriter) {
+	conn.Write(packetBytes(w.buf))
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	for {
+		_, payload, err := readPacket(conn)
+		if err != nil {
+			return
+		}
+
+		r := &tagReader{buf: payload}
+		command := r.u32()
+		tag := r.u32()
+
+		s.mu.Lock()
+		body, code := s.handle(conn, command, r)
+		if r.err != nil {
+			code = CodeProtocol
+		}
+
+		w := &tagWriter{}
+		if code != CodeOK {
+			w.u32(commandError)
+			w.u32(tag)
+			w.u32(uint32(code))
+		} else {
+			w.u32(commandReply)
+			w.u32(tag)
+			w.buf = append(w.buf, body.buf...)
+		}
+		s.send(conn, w)
+		s.mu.Unlock()
+	}
+}
+
+func (s *fakeServer) findSink(index uint32, name string) *SinkInfo {
+	for _, sink := range s.sinks {
+		if sink.Name == name || (name == "" && sink.Index == index) {
+			return sink
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) findSinkInput(index uint32) *SinkInputInfo {
+	for _, si := range s.sinkInputs {
+		if si.Index == index {
+			return si
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) findSourceOutput(index uint32) *SourceOutputInfo {
+	for _, so := range s.sourceOuts {
+		if so.Index == index {
+			return so
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) findCard(index uint32, name string) *CardInfo {
+	for _, card := range s.cards {
+		if card.Name == name || (name == "" && card.Index == index) {
+			return card
+		}
+	}
+	return nil
+}
+
+// notify sends subscription event, facility and type as in pa_subscription_event_type_t
+func (s *fakeServer) notify(facility uint32, eventType uint32, index uint32) {
+	for conn, mask := range s.subscribed {
+		if mask&(1<<facility) == 0 {
+			continue
+		}
+		w := &tagWriter{}
+		w.u32(commandSubscribeEvent)
+		w.u32(InvalidIndex)
+		w.u32(facility | eventType)
+		w.u32(index)
+		s.send(conn, w)
+	}
+}
+
+func (s *fakeServer) writeSink(w *tagWriter, sink *SinkInfo) {
+	w.u32(sink.Index)
+	w.str(sink.Name)
+	w.str(sink.Description)
+	w.sampleSpec(sink.SampleSpec)
+	w.channelMap(sink.ChannelMap)
+	w.u32(InvalidIndex)
+	w.cvolume(sink.Volume)
+	w.boolean(sink.Mute)
+	w.u32(sink.Index + 1000)
+	w.str(sink.Name + ".monitor")
+	w.usec(0)
+	w.str("module-alsa-card.c")
+	w.u32(0)
+	w.proplist(sink.Props)
+	w.usec(0)
+	w.volume(VolumeNorm)
+	w.u32(0)
+	w.u32(VolumeNorm + 1)
+	w.u32(InvalidIndex)
+	w.u32(uint32(len(sink.Ports)))
+	for _, p := range sink.Ports {
+		w.str(p.Name)
+		w.str(p.Description)
+		w.u32(p.Priority)
+		if s.version >= 24 {
+			w.u32(p.Available)
+		}
+		if s.version >= 34 {
+			w.str(p.AvailabilityGroup)
+			w.u32(p.Type)
+		}
+	}
+	w.nameOrNull(sink.ActivePort)
+	if s.version >= 21 {
+		w.u8(1)
+		w.formatInfo()
+	}
+}
+
+func (s *fakeServer) writeSinkInput(w *tagWriter, si *SinkInputInfo) {
+	w.u32(si.Index)
+	w.str(si.Name)
+	w.u32(InvalidIndex)
+	w.u32(1)
+	w.u32(si.Sink)
+	w.sampleSpec(SampleSpec{Format: 3, Channels: uint8(len(si.ChannelMap)), Rate: 48000})
+	w.channelMap(si.ChannelMap)
+	w.cvolume(si.Volume)
+	w.usec(0)
+	w.usec(0)
+	w.str("")
+	w.str("protocol-native.c")
+	w.boolean(si.Mute)
+	w.proplist(si.Props)
+	if s.version >= 19 {
+		w.boolean(si.Corked)
+	}
+	if s.version >= 20 {
+		w.boolean(true)
+		w.boolean(true)
+	}
+	if s.version >= 21 {
+		w.formatInfo()
+	}
+}
+
+func (s *fakeServer) writeSourceOutput(w *tagWriter, so *SourceOutputInfo) {
+	w.u32(so.Index)
+	w.str(so.Name)
+	w.u32(InvalidIndex)
+	w.u32(1)
+	w.u32(so.Source)
+	w.sampleSpec(SampleSpec{Format: 3, Channels: uint8(len(so.ChannelMap)), Rate: 48000})
+	w.channelMap(so.ChannelMap)
+	w.usec(0)
+	w.usec(0)
+	w.str("")
+	w.str("protocol-native.c")
+	w.proplist(so.Props)
+	if s.version >= 19 {
+		w.boolean(so.Corked)
+	}
+	if s.version >= 22 {
+		w.cvolume(so.Volume)
+		w.boolean(so.Mute)
+		w.boolean(true)
+		w.boolean(true)
+		w.formatInfo()
+	}
+}
+
+func (s *fakeServer) writeCard(w *tagWriter, card *CardInfo) {
+	w.u32(card.Index)
+	w.str(card.Name)
+	w.u32(InvalidIndex)
+	w.str(card.Driver)
+	w.u32(uint32(len(card.Profiles)))
+	for _, p := range card.Profiles {
+		w.str(p.Name)
+		w.str(p.Description)
+		w.u32(p.Sinks)
+		w.u32(p.Sources)
+		w.u32(p.Priority)
+		if s.version >= 29 {
+			available := uint32(0)
+			if p.Available {
+				available = 1
+			}
+			w.u32(available)
+		}
+	}
+	w.str(card.ActiveProfile)
+	w.proplist(card.Props)
+	if s.version >= 26 {
+		w.u32(uint32(len(card.Ports)))
+		for _, p := range card.Ports {
+			w.str(p.Name)
+			w.str(p.Description)
+			w.u32(p.Priority)
+			w.u32(p.Available)
+			w.u8(p.Direction)
+			w.proplist(nil)
+			w.u32(uint32(len(p.Profiles)))
+			for _, profile := range p.Profiles {
+				w.str(profile)
+			}
+			if s.version >= 27 {
+				w.s64(0)
+			}
+			if s.version >= 34 {
+				w.str(p.AvailabilityGroup)
+				w.u32(p.Type)
+			}
+		}
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn, command uint32, r *tagReader) (*tagWriter, ErrorCode) {
+	w := &tagWriter{}
+
+	switch command {
+	case commandAuth:
+		r.u32()
+		if len(r.arbitrary()) != cookieSize {
+			return nil, CodeAccess
+		}
+		w.u32(s.version)
+
+	case commandSetClientName:
+		if r.proplist()["application.name"] == "" {
+			return nil, CodeInvalid
+		}
+		w.u32(1)
+
+	case commandGetServerInfo:
+		w.str("pulseaudio")
+		w.str("16.1")
+		w.str("user")
+		w.str("host")
+		w.sampleSpec(SampleSpec{Format: 3, Channels: 2, Rate: 48000})
+		w.str(s.defaultSink)
+		w.str("alsa_input.mic")
+		w.u32(0)
+		w.channelMap(ChannelMap{1, 2})
+
+	case commandGetSinkInfoList:
+		for _, sink := range s.sinks {
+			s.writeSink(w, sink)
+		}
+
+	case commandGetSinkInfo:
+		sink := s.findSink(r.u32(), r.str())
+		if sink == nil {
+			return nil, CodeNoEntity
+		}
+		s.writeSink(w, sink)
+
+	case commandGetSinkInputList:
+		for _, si := range s.sinkInputs {
+			s.writeSinkInput(w, si)
+		}
+
+	case commandGetSourceOutputs:
+		for _, so := range s.sourceOuts {
+			s.writeSourceOutput(w, so)
+		}
+
+	case commandGetSourceOutput:
+		so := s.findSourceOutput(r.u32())
+		if so == nil {
+			return nil, CodeNoEntity
+		}
+		s.writeSourceOutput(w, so)
+
+	case commandSetSourceOutVolume:
+		so := s.findSourceOutput(r.u32())
+		volume := r.cvolume()
+		if so == nil {
+			return nil, CodeNoEntity
+		}
+		so.Volume = volume
+		s.notify(3, 0x10, so.Index)
+
+	case commandSetSourceOutMute:
+		so := s.findSourceOutput(r.u32())
+		mute := r.boolean()
+		if so == nil {
+			return nil, CodeNoEntity
+		}
+		so.Mute = mute
+		s.notify(3, 0x10, so.Index)
+
+	case commandGetCardInfoList:
+		for _, card := range s.cards {
+			s.writeCard(w, card)
+		}
+
+	case commandGetCardInfo:
+		card := s.findCard(r.u32(), r.str())
+		if card == nil {
+			return nil, CodeNoEntity
+		}
+		s.writeCard(w, card)
+
+	case commandSetCardProfile:
+		card := s.findCard(r.u32(), r.str())
+		profile := r.str()
+		if card == nil {
+			return nil, CodeNoEntity
+		}
+		if !slices.ContainsFunc(card.Profiles, func(p CardProfileInfo) bool { return p.Name == profile }) {
+			return nil, CodeNoEntity
+		}
+		card.ActiveProfile = profile
+		s.notify(9, 0x10, card.Index)
+
+	case commandSetSinkVolume:
+		sink := s.findSink(r.u32(), r.str())
+		volume := r.cvolume()
+		if sink == nil {
+			return nil, CodeNoEntity
+		}
+		if len(volume) != 1 && len(volume) != len(sink.ChannelMap) {
+			return nil, CodeInvalid
+		}
+		sink.Volume = volume
+		s.notify(0, 0x10, sink.Index)
+
+	case commandSetSinkMute:
+		sink := s.findSink(r.u32(), r.str())
+		mute := r.boolean()
+		if sink == nil {
+			return nil, CodeNoEntity
+		}
+		sink.Mute = mute
+		s.notify(0, 0x10, sink.Index)
+
+	case commandSetSinkPort:
+		sink := s.findSink(r.u32(), r.str())
+		port := r.str()
+		if sink == nil {
+			return nil, CodeNoEntity
+		}
+		if !slices.ContainsFunc(sink.Ports, func(p PortInfo) bool { return p.Name == port }) {
+			return nil, CodeNoEntity
+		}
+		sink.ActivePort = port
+		s.notify(0, 0x10, sink.Index)
+
+	case commandSetDefaultSink:
+		sink := s.findSink(InvalidIndex, r.str())
+		if sink == nil {
+			return nil, CodeNoEntity
+		}
+		s.defaultSink = sink.Name
+		s.notify(7, 0x10, InvalidIndex)
+
+	case commandMoveSinkInput:
+		si := s.findSinkInput(r.u32())
+		sink := s.findSink(r.u32(), r.str())
+		if si == nil || sink == nil {
+			return nil, CodeNoEntity
+		}
+		si.Sink = sink.Index
+		s.notify(2, 0x10, si.Index)
+
+	case commandSubscribe:
+		s.subscribed[conn] = r.u32()
+
+	default:
+		return nil, CodeCommand
+	}
+
+	return w, CodeOK
+}
+
+func dialFake(t *testing.T, s *fakeServer) *Client {
+	t.Helper()
+
+	c, err := Dial(s.path)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func asError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package native
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// pstream frame descriptor: length, channel, offset hi, offset lo, flags
+const descriptorSize = 20
+
+// Channel of packets with tagstruct payload, other channels carry audio memblocks
+const channelControl = 0xFFFFFFFF
+
+// FRAME_SIZE_MAX_ALLOW from pulsecore/pstream.c
+const maxFrameSize = 16 * 1024 * 1024
+
+func packetBytes(payload []byte) []byte {
+	frame := make([]byte, descriptorSize, descriptorSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:], channelControl)
+	return append(frame, payload...)
+}
+
+func readPacket(r io.Reader) (channel uint32, payload []byte, err error) {
+	var descriptor [descriptorSize]byte
+	if _, err := io.ReadFull(r, descriptor[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(descriptor[0:])
+	channel = binary.BigEndian.Uint32(descriptor[4:])
+
+	if length > maxFrameSize {
+		return 0, nil, fmt.Errorf("native: frame of %d bytes is too large", length)
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return channel, payload, nil
+}
@@ -0,0 +1,283 @@
+package native
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Type tags from pulsecore/tagstruct.h. Every value on the wire is prefixed with one of them.
+const (
+	tagString     byte = 't'
+	tagStringNull byte = 'N'
+	tagU32        byte = 'L'
+	tagU8         byte = 'B'
+	tagU64        byte = 'R'
+	tagS64        byte = 'r'
+	tagSampleSpec byte = 'a'
+	tagArbitrary  byte = 'x'
+	tagBoolTrue   byte = '1'
+	tagBoolFalse  byte = '0'
+	tagTimeval    byte = 'T'
+	tagUsec       byte = 'U'
+	tagChannelMap byte = 'm'
+	tagCVolume    byte = 'v'
+	tagProplist   byte = 'P'
+	tagVolume     byte = 'V'
+	tagFormatInfo byte = 'f'
+)
+
+var errShortTagstruct = errors.New("tagstruct: unexpected end of data")
+
+// tagWriter builds tagstruct payload of a single packet.
+type tagWriter struct {
+	buf []byte
+}
+
+func (w *tagWriter) u32(v uint32) {
+	w.buf = append(w.buf, tagU32)
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *tagWriter) str(s string) {
+	w.buf = append(w.buf, tagString)
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, 0)
+}
+
+// nameOrNull writes NULL string for empty name. Commands addressed by index expect NULL name.
+func (w *tagWriter) nameOrNull(s string) {
+	if s == "" {
+		w.buf = append(w.buf, tagStringNull)
+		return
+	}
+	w.str(s)
+}
+
+func (w *tagWriter) boolean(b bool) {
+	if b {
+		w.buf = append(w.buf, tagBoolTrue)
+	} else {
+		w.buf = append(w.buf, tagBoolFalse)
+	}
+}
+
+func (w *tagWriter) arbitrary(b []byte) {
+	w.buf = append(w.buf, tagArbitrary)
+	w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *tagWriter) cvolume(v CVolume) {
+	w.buf = append(w.buf, tagCVolume, uint8(len(v)))
+	for _, ch := range v {
+		w.buf = binary.BigEndian.AppendUint32(w.buf, ch)
+	}
+}
+
+func (w *tagWriter) proplist(p map[string]string) {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w.buf = append(w.buf, tagProplist)
+	for _, k := range keys {
+		// Values are arbitrary data, strings are stored with terminating NUL
+		value := append([]byte(p[k]), 0)
+		w.str(k)
+		w.u32(uint32(len(value)))
+		w.arbitrary(value)
+	}
+	w.buf = append(w.buf, tagStringNull)
+}
+
+// tagReader reads tagstruct payload. First error sticks, following reads return zero values.
+type tagReader struct {
+	buf []byte
+	err error
+}
+
+func (r *tagReader) done() bool {
+	return r.err != nil || len(r.buf) == 0
+}
+
+func (r *tagReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *tagReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.fail(errShortTagstruct)
+		return nil
+	}
+
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *tagReader) tag(want ...byte) byte {
+	b := r.take(1)
+	if b == nil {
+		return 0
+	}
+
+	for _, w := range want {
+		if b[0] == w {
+			return b[0]
+		}
+	}
+
+	r.fail(fmt.Errorf("tagstruct: unexpected tag %q, want %q", b[0], want))
+	return 0
+}
+
+func (r *tagReader) rawU32() uint32 {
+	b := r.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *tagReader) rawU64() uint64 {
+	b := r.take(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *tagReader) rawU8() uint8 {
+	b := r.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *tagReader) u32() uint32 {
+	r.tag(tagU32)
+	return r.rawU32()
+}
+
+func (r *tagReader) u8() uint8 {
+	r.tag(tagU8)
+	return r.rawU8()
+}
+
+func (r *tagReader) u64() uint64 {
+	r.tag(tagU64)
+	return r.rawU64()
+}
+
+func (r *tagReader) s64() int64 {
+	r.tag(tagS64)
+	return int64(r.rawU64())
+}
+
+func (r *tagReader) usec() uint64 {
+	r.tag(tagUsec)
+	return r.rawU64()
+}
+
+func (r *tagReader) volume() uint32 {
+	r.tag(tagVolume)
+	return r.rawU32()
+}
+
+func (r *tagReader) boolean() bool {
+	return r.tag(tagBoolTrue, tagBoolFalse) == tagBoolTrue
+}
+
+// str reads string, NULL string is returned as empty one.
+func (r *tagReader) str() string {
+	if r.tag(tagString, tagStringNull) != tagString {
+		return ""
+	}
+
+	end := strings.IndexByte(string(r.buf), 0)
+	if end < 0 {
+		r.fail(errShortTagstruct)
+		return ""
+	}
+
+	s := string(r.take(end))
+	r.take(1)
+	return s
+}
+
+func (r *tagReader) arbitrary() []byte {
+	r.tag(tagArbitrary)
+	return r.take(int(r.rawU32()))
+}
+
+// count reads u32 length of following list, guarding against garbage that would allocate gigabytes.
+func (r *tagReader) count() int {
+	n := r.u32()
+	if int(n) > len(r.buf) {
+		r.fail(fmt.Errorf("tagstruct: list of %d items in %d bytes", n, len(r.buf)))
+		return 0
+	}
+	return int(n)
+}
+
+func (r *tagReader) sampleSpec() SampleSpec {
+	r.tag(tagSampleSpec)
+	return SampleSpec{
+		Format:   r.rawU8(),
+		Channels: r.rawU8(),
+		Rate:     r.rawU32(),
+	}
+}
+
+func (r *tagReader) channelMap() ChannelMap {
+	r.tag(tagChannelMap)
+	n := int(r.rawU8())
+	return ChannelMap(r.take(n))
+}
+
+func (r *tagReader) cvolume() CVolume {
+	r.tag(tagCVolume)
+	n := int(r.rawU8())
+
+	v := make(CVolume, 0, n)
+	for range n {
+		v = append(v, r.rawU32())
+	}
+	return v
+}
+
+func (r *tagReader) proplist() map[string]string {
+	r.tag(tagProplist)
+
+	p := map[string]string{}
+	for r.err == nil {
+		key := r.str()
+		if key == "" {
+			break
+		}
+
+		r.u32() // length, repeated by arbitrary
+		value := r.arbitrary()
+		p[key] = strings.TrimSuffix(string(value), "\x00")
+	}
+
+	return p
+}
+
+// formatInfo is only skipped, we don't negotiate stream formats.
+func (r *tagReader) formatInfo() {
+	r.tag(tagFormatInfo)
+	r.u8()
+	r.proplist()
+}
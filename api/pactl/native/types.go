@@ -0,0 +1,245 @@
+package native
+
+import "math"
+
+// PA_VOLUME_NORM, 100% volume
+const VolumeNorm = 0x10000
+
+// PA_INVALID_INDEX
+const InvalidIndex = 0xFFFFFFFF
+
+type SampleSpec struct {
+	Format   uint8
+	Channels uint8
+	Rate     uint32
+}
+
+// ChannelMap holds pa_channel_position_t of every channel
+type ChannelMap []uint8
+
+// Same names as in pactl output
+var channelPositionNames = []string{
+	"mono",
+	"front-left", "front-right", "front-center",
+	"rear-center", "rear-left", "rear-right",
+	"lfe",
+	"front-left-of-center", "front-right-of-center",
+	"side-left", "side-right",
+	"aux0", "aux1", "aux2", "aux3", "aux4", "aux5", "aux6", "aux7",
+	"aux8", "aux9", "aux10", "aux11", "aux12", "aux13", "aux14", "aux15",
+	"aux16", "aux17", "aux18", "aux19", "aux20", "aux21", "aux22", "aux23",
+	"aux24", "aux25", "aux26", "aux27", "aux28", "aux29", "aux30", "aux31",
+	"top-center",
+	"top-front-left", "top-front-right", "top-front-center",
+	"top-rear-left", "top-rear-right", "top-rear-center",
+}
+
+func (m ChannelMap) Names() []string {
+	names := make([]string, len(m))
+	for i, pos := range m {
+		if int(pos) < len(channelPositionNames) {
+			names[i] = channelPositionNames[pos]
+		} else {
+			names[i] = "invalid"
+		}
+	}
+	return names
+}
+
+// CVolume is pa_cvolume, raw volume of every channel
+type CVolume []uint32
+
+// Flat returns volume with the same value on all channels.
+func Flat(channels int, volume uint32) CVolume {
+	v := make(CVolume, max(channels, 1))
+	for i := range v {
+		v[i] = volume
+	}
+	return v
+}
+
+// Percent converts raw volume into percent, rounded the same way as pactl.
+func Percent(volume uint32) int {
+	return int((uint64(volume)*100 + VolumeNorm/2) / VolumeNorm)
+}
+
+// FromPercent converts percent into raw volume.
+func FromPercent(percent float64) uint32 {
+	return uint32(math.Round(max(percent, 0) * VolumeNorm / 100))
+}
+
+type PortInfo struct {
+	Name              string
+	Description       string
+	Priority          uint32
+	Available         uint32
+	AvailabilityGroup string
+	Type              uint32
+}
+
+// pa_port_available_t
+const (
+	PortAvailableUnknown uint32 = 0
+	PortAvailableNo      uint32 = 1
+	PortAvailableYes     uint32 = 2
+)
+
+type ServerInfo struct {
+	PackageName       string
+	PackageVersion    string
+	UserName          string
+	HostName          string
+	DefaultSinkName   string
+	DefaultSourceName string
+}
+
+type SinkInfo struct {
+	Index              uint32
+	Name               string
+	Description        string
+	SampleSpec         SampleSpec
+	ChannelMap         ChannelMap
+	OwnerModule        uint32
+	Volume             CVolume
+	Mute               bool
+	MonitorSourceIndex uint32
+	MonitorSourceName  string
+	Driver             string
+	Flags              uint32
+	Props              map[string]string
+	BaseVolume         uint32
+	State              uint32
+	Card               uint32
+	Ports              []PortInfo
+	ActivePort         string
+}
+
+type SourceInfo struct {
+	Index              uint32
+	Name               string
+	Description        string
+	SampleSpec         SampleSpec
+	ChannelMap         ChannelMap
+	OwnerModule        uint32
+	Volume             CVolume
+	Mute               bool
+	MonitorOfSinkIndex uint32
+	MonitorOfSinkName  string
+	Driver             string
+	Flags              uint32
+	Props              map[string]string
+	BaseVolume         uint32
+	State              uint32
+	Card               uint32
+	Ports              []PortInfo
+	ActivePort         string
+}
+
+type SinkInputInfo struct {
+	Index          uint32
+	Name           string
+	OwnerModule    uint32
+	Client         uint32
+	Sink           uint32
+	SampleSpec     SampleSpec
+	ChannelMap     ChannelMap
+	Volume         CVolume
+	Driver         string
+	Mute           bool
+	Props          map[string]string
+	Corked         bool
+	HasVolume      bool
+	VolumeWritable bool
+}
+
+// SourceOutputInfo is app recording from source, fe. video call using microphone
+type SourceOutputInfo struct {
+	Index          uint32
+	Name           string
+	OwnerModule    uint32
+	Client         uint32
+	Source         uint32
+	SampleSpec     SampleSpec
+	ChannelMap     ChannelMap
+	Volume         CVolume
+	Driver         string
+	Mute           bool
+	Props          map[string]string
+	Corked         bool
+	HasVolume      bool
+	VolumeWritable bool
+}
+
+type CardProfileInfo struct {
+	Name        string
+	Description string
+	Sinks       uint32
+	Sources     uint32
+	Priority    uint32
+	// Always true before protocol 29
+	Available bool
+}
+
+type CardPortInfo struct {
+	PortInfo
+	// pa_direction_t, 1 output, 2 input
+	Direction uint8
+	Profiles  []string
+}
+
+// CardInfo is physical device with profiles, fe. bluetooth headset with A2DP and HSP/HFP
+type CardInfo struct {
+	Index         uint32
+	Name          string
+	OwnerModule   uint32
+	Driver        string
+	Profiles      []CardProfileInfo
+	ActiveProfile string
+	Props         map[string]string
+	Ports         []CardPortInfo
+}
+
+// Event is single subscription event, fe. change on sink #55
+type Event struct {
+	Facility string
+	Type     string
+	Index    uint32
+}
+
+// Subscription masks, pa_subscription_mask_t
+const (
+	SubscribeSink         uint32 = 0x0001
+	SubscribeSource       uint32 = 0x0002
+	SubscribeSinkInput    uint32 = 0x0004
+	SubscribeSourceOutput uint32 = 0x0008
+	SubscribeModule       uint32 = 0x0010
+	SubscribeClient       uint32 = 0x0020
+	SubscribeSampleCache  uint32 = 0x0040
+	SubscribeServer       uint32 = 0x0080
+	SubscribeCard         uint32 = 0x0200
+)
+
+// Indexed by pa_subscription_event_type_t facility, names same as in `pactl subscribe`
+var facilityNames = []string{
+	"sink", "source", "sink-input", "source-output", "module",
+	"client", "sample-cache", "server", "autoload", "card",
+}
+
+func eventFromWire(t uint32, index uint32) Event {
+	ev := Event{Facility: "unknown", Index: index}
+
+	if f := int(t & 0x0F); f < len(facilityNames) {
+		ev.Facility = facilityNames[f]
+	}
+
+	switch t & 0x30 {
+	case 0x00:
+		ev.Type = "new"
+	case 0x10:
+		ev.Type = "change"
+	case 0x20:
+		ev.Type = "remove"
+	}
+
+	return ev
+}
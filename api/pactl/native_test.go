@@ -0,0 +1,131 @@
+package pactl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/undg/pulse-remote/api/pactl/native"
+)
+
+func TestSinkFromNative(t *testing.T) {
+	raw := native.SinkInfo{
+		Index:       55,
+		Name:        "alsa_output.speakers",
+		Description: "Speakers",
+		ChannelMap:  native.ChannelMap{1, 2},
+		Volume:      native.CVolume{native.VolumeNorm * 6 / 10, native.VolumeNorm / 2},
+		Mute:        true,
+	}
+
+	sink := sinkFromNative(raw, "alsa_output.speakers")
+
+	if sink.ID != 55 || sink.Label != "Speakers" {
+		t.Errorf("Wrong sink %+v", sink)
+	}
+	if sink.Volume != 55 || sink.Balance != -0.17 {
+		t.Errorf("Expected average volume 55 and balance -0.17, got %d %v", sink.Volume, sink.Balance)
+	}
+	wantChannels := []ChannelVolume{{"front-left", 60}, {"front-right", 50}}
+	if !reflect.DeepEqual(sink.Channels, wantChannels) {
+		t.Errorf("Expected channels %+v, got %+v", wantChannels, sink.Channels)
+	}
+	if !sink.Muted || !sink.IsDefault {
+		t.Errorf("Expected muted default sink, got %+v", sink)
+	}
+}
+
+func TestSourceFromNative(t *testing.T) {
+	monitor := sourceFromNative(native.SourceInfo{Name: "speakers.monitor", MonitorOfSinkName: "speakers"}, "mic")
+	if !monitor.Monitored || monitor.Monitor != "speakers" {
+		t.Errorf("Expected monitor of speakers, got %+v", monitor)
+	}
+
+	mic := sourceFromNative(native.SourceInfo{Name: "mic"}, "mic")
+	if mic.Monitored || mic.Monitor != "n/a" || !mic.IsDefault {
+		t.Errorf("Expected default not monitored source, got %+v", mic)
+	}
+}
+
+func TestSinkInputFromNative(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Props map[string]string
+		Want  string
+	}{
+		{"ApplicationName", map[string]string{"application.name": "Firefox", "media.name": "YouTube"}, "Firefox"},
+		{"MediaName", map[string]string{"media.name": "Playback Stream"}, "Playback Stream"},
+		{"Binary", map[string]string{"application.process.binary": "mpv"}, "mpv"},
+		{"NoProps", nil, "Sink Input #7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			app := sinkInputFromNative(native.SinkInputInfo{Index: 7, Sink: 55, Props: tt.Props})
+			if app.Label != tt.Want {
+				t.Errorf("Expected label %q, got %q", tt.Want, app.Label)
+			}
+			if app.SinkID != 55 {
+				t.Errorf("Expected sink 55, got %d", app.SinkID)
+			}
+		})
+	}
+}
+
+func TestSourceOutputFromNative(t *testing.T) {
+	app := sourceOutputFromNative(native.SourceOutputInfo{
+		Index:  93,
+		Source: 58,
+		Volume: native.CVolume{native.VolumeNorm / 2},
+		Props:  map[string]string{"application.name": "Zoom"},
+	})
+
+	if app.ID != 93 || app.SourceID != 58 || app.Label != "Zoom" || app.Volume != 50 {
+		t.Errorf("Wrong source output %+v", app)
+	}
+
+	// Protocol < 22 doesn't send volume of recording streams
+	if app := sourceOutputFromNative(native.SourceOutputInfo{Index: 93}); app.Volume != 0 || app.Label != "Source Output #93" {
+		t.Errorf("Wrong source output without volume %+v", app)
+	}
+}
+
+func TestCardFromNative(t *testing.T) {
+	card := cardFromNative(native.CardInfo{
+		Index: 61,
+		Name:  "alsa_card.pci-0000_01_00.1",
+		Profiles: []native.CardProfileInfo{
+			{Name: "off", Description: "Off", Available: true},
+			{Name: "output:hdmi-stereo", Description: "Digital Stereo (HDMI) Output", Sinks: 1, Priority: 5900, Available: true},
+			{Name: "output:hdmi-surround", Description: "Digital Surround 5.1 (HDMI) Output", Sinks: 1, Priority: 800},
+		},
+		ActiveProfile: "output:hdmi-stereo",
+	})
+
+	if card.Label != "alsa_card.pci-0000_01_00.1" {
+		t.Errorf("Expected name as label without device.description, got %q", card.Label)
+	}
+	if card.Profiles[0].Name != "output:hdmi-stereo" || card.Profiles[2].Name != "off" {
+		t.Errorf("Expected profiles sorted by priority, got %+v", card.Profiles)
+	}
+	if card.Profiles[1].Available {
+		t.Errorf("Expected surround profile to be unavailable")
+	}
+}
+
+func TestPortsFromNative(t *testing.T) {
+	ports := portsFromNative([]native.PortInfo{
+		{Name: "analog-output-lineout", Description: "Line Out", Priority: 9000, Available: native.PortAvailableNo},
+		{Name: "analog-output-headphones", Description: "Headphones", Priority: 9900, Available: native.PortAvailableYes},
+		{Name: "hdmi-output-0", Description: "HDMI", Priority: 5900, Available: native.PortAvailableUnknown},
+	})
+
+	want := []Port{
+		{Name: "analog-output-lineout", Label: "Line Out", Priority: 9000, Available: PortAvailableNo},
+		{Name: "analog-output-headphones", Label: "Headphones", Priority: 9900, Available: PortAvailableYes},
+		{Name: "hdmi-output-0", Label: "HDMI", Priority: 5900, Available: PortAvailableUnknown},
+	}
+
+	if !reflect.DeepEqual(ports, want) {
+		t.Errorf("Expected %+v, got %+v", want, ports)
+	}
+}
@@ -1,240 +1,173 @@
 package pactl
 
 import (
-	"bufio"
-	"os/exec"
-	"regexp"
-	"strconv"
-	"strings"
+	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/undg/pulse-remote/api/buildinfo"
 	"github.com/undg/pulse-remote/api/logger"
 )
 
-func SetSinkVolume(sinkName string, volume string) {
-	setVolume("sink", sinkName, volume)
+func SetSinkVolume(sinkName string, volume string) error {
+	return setVolume("sink", sinkName, volume)
 }
 
-func SetSinkMuted(sinkName string, muted bool) {
-	setMuted("sink", sinkName, muted)
+// ChangeSinkVolume changes volume by delta in percent, fe. 5 or -5
+func ChangeSinkVolume(sinkName string, delta float64) error {
+	return setVolume("sink", sinkName, fmt.Sprintf("%+.2f", delta))
 }
 
-func SetDefaultSink(sinkName string) {
-	setDefault("sink", sinkName)
+func SetSinkBalance(sinkName string, balance float64) error {
+	return setBalance("sink", sinkName, balance)
 }
 
-func SetSinkInputVolume(sinkInputID string, volume string) {
-	setVolume("sink-input", sinkInputID, volume)
+func SetSinkChannelVolumes(sinkName string, volumes []float64) error {
+	return setChannelVolumes("sink", sinkName, volumes)
 }
 
-func SetSinkInputMuted(sinkInputID string, muted bool) {
-	setMuted("sink-input", sinkInputID, muted)
+func SetSinkMuted(sinkName string, muted bool) error {
+	return setMuted("sink", sinkName, muted)
 }
 
-func MoveSinkInput(sinkInputID string, sinkName string) {
-	moveApp("sink-input", sinkInputID, sinkName)
+func SetDefaultSink(sinkName string) error {
+	return setDefault("sink", sinkName)
+}
 
+func SetSinkPort(sinkName string, port string) error {
+	return setPort("sink", sinkName, port)
 }
 
-func SetSourceVolume(sourceName string, volume string) {
-	setVolume("source", sourceName, volume)
+func SetSinkInputVolume(sinkInputID string, volume string) error {
+	return setVolume("sink-input", sinkInputID, volume)
 }
 
-func SetSourceMuted(sourceName string, muted bool) {
-	setMuted("source", sourceName, muted)
+func ChangeSinkInputVolume(sinkInputID string, delta float64) error {
+	return setVolume("sink-input", sinkInputID, fmt.Sprintf("%+.2f", delta))
+}
 
+func SetSinkInputMuted(sinkInputID string, muted bool) error {
+	return setMuted("sink-input", sinkInputID, muted)
 }
 
-func SetDefaultSource(sourceName string) {
-	setDefault("source", sourceName)
+func MoveSinkInput(sinkInputID string, sinkName string) error {
+	return moveApp("sink-input", sinkInputID, sinkName)
 }
 
-func SetSourceInputVolume(sourceInputID string, volume string) {
-	setVolume("source-input", sourceInputID, volume)
+func SetSourceVolume(sourceName string, volume string) error {
+	return setVolume("source", sourceName, volume)
 }
 
-func SetSourceInputMuted(sourceInputID string, muted bool) {
-	setMuted("source-input", sourceInputID, muted)
+func ChangeSourceVolume(sourceName string, delta float64) error {
+	return setVolume("source", sourceName, fmt.Sprintf("%+.2f", delta))
 }
 
-func MoveSourceOutput(sourceOutputID string, sourceName string) {
-	moveApp("source-output", sourceOutputID, sourceName)
+func SetSourceMuted(sourceName string, muted bool) error {
+	return setMuted("source", sourceName, muted)
 }
 
-func parseSink(sinkName string, defaultName string) Sink {
-	idRe, _ := regexp.Compile(`Sink #(\d+)`)
-	nameRe, _ := regexp.Compile(`Name: (.+)`)
-	descRe, _ := regexp.Compile(`Description: (.+)`)
-	volumeRe, _ := regexp.Compile(`Volume: .+?(\d+)%`)
-	muteRe, _ := regexp.Compile(`Mute: (yes|no)`)
-
-	id, _ := strconv.Atoi(idRe.FindStringSubmatch(sinkName)[1])
-	name := nameRe.FindStringSubmatch(sinkName)[1]
-	desc := descRe.FindStringSubmatch(sinkName)[1]
-	volume, _ := strconv.Atoi(volumeRe.FindStringSubmatch(sinkName)[1])
-	mute := muteRe.FindStringSubmatch(sinkName)[1] == "yes"
-
-	return Sink{
-		ID:        id,
-		Name:      name,
-		Label:     desc,
-		Volume:    volume,
-		Muted:     mute,
-		IsDefault: name == defaultName,
-	}
+func SetDefaultSource(sourceName string) error {
+	return setDefault("source", sourceName)
 }
 
-func getDefaultSinkName() (string, error) {
-	cmd := exec.Command("pactl", "info")
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
+func SetSourcePort(sourceName string, port string) error {
+	return setPort("source", sourceName, port)
+}
 
-	re, _ := regexp.Compile(`Default Sink: (.+)`)
-	matches := re.FindStringSubmatch(string(out))
-	if len(matches) < 2 {
-		return "", nil
-	}
+func SetSourceOutputVolume(sourceOutputID string, volume string) error {
+	return setVolume("source-output", sourceOutputID, volume)
+}
 
-	return strings.TrimSpace(matches[1]), nil
+func SetSourceOutputMuted(sourceOutputID string, muted bool) error {
+	return setMuted("source-output", sourceOutputID, muted)
 }
 
-func GetSinks() ([]Sink, error) {
-	defaultName, _ := getDefaultSinkName()
+func MoveSourceOutput(sourceOutputID string, sourceName string) error {
+	return moveApp("source-output", sourceOutputID, sourceName)
+}
 
-	cmd := exec.Command("pactl", "list", "sinks")
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
+func SetCardProfile(cardName string, profile string) error {
+	return setCardProfile(cardName, profile)
+}
 
-	sinksNames := strings.Split(string(out), "Sink #")
-	sinks := make([]Sink, 0, len(sinksNames)-1)
+func GetSinks() ([]Sink, error) {
+	if c, err := pulse(); err == nil {
+		return getSinksNative(c)
+	}
 
-	for _, sink := range sinksNames[1:] {
-		sinks = append(sinks, parseSink("Sink #"+sink, defaultName))
+	if hasJSONFormat() {
+		sinks, err := getSinksJSON()
+		if !errors.Is(err, errNoJSONFormat) {
+			return sinks, err
+		}
 	}
 
-	return sinks, nil
+	return getSinksText()
 }
 
 func GetSinkInputs() ([]SinkInput, error) {
-	cmd := exec.Command("pactl", "list", "sink-inputs")
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, err
+	if c, err := pulse(); err == nil {
+		return getSinkInputsNative(c)
 	}
 
-	re, err := regexp.Compile(`Sink Input #(\d+)[\s\S]*?Sink: (\d+)[\s\S]*?Mute: (yes|no)[\s\S]*?Volume:.*?(\d+)%[\s\S]*?application\.name = "(.*?)"`)
-	if err != nil {
-		return nil, err
-	}
-	matches := re.FindAllStringSubmatch(string(out), -1)
-
-	sinkInputs := make([]SinkInput, len(matches))
-	for i, m := range matches {
-		id, err := strconv.Atoi(m[1])
-		if err != nil {
-			return nil, err
-		}
-		sinkID, err := strconv.Atoi(m[2])
-		if err != nil {
-			return nil, err
-		}
-		volume, err := strconv.Atoi(m[4])
-		if err != nil {
-			return nil, err
-		}
-		sinkInputs[i] = SinkInput{
-			ID:     id,
-			SinkID: sinkID,
-			Label:  m[5],
-			Volume: volume,
-			Muted:  m[3] == "yes",
+	if hasJSONFormat() {
+		sinkInputs, err := getSinkInputsJSON()
+		if !errors.Is(err, errNoJSONFormat) {
+			return sinkInputs, err
 		}
 	}
 
-	return sinkInputs, nil
-}
-
-func parseSources(sourceName string, defaultName string) Source {
-	idRe, _ := regexp.Compile(`Source #(\d+)`)
-	nameRe, _ := regexp.Compile(`Name: (.+)`)
-	descRe, _ := regexp.Compile(`Description: (.+)`)
-	volumeRe, _ := regexp.Compile(`Volume: .+?(\d+)%`)
-	muteRe, _ := regexp.Compile(`Mute: (yes|no)`)
-	monitorRe, _ := regexp.Compile(`Monitor of Sink: (.+)`) // n/a or name of the Sink
-
-	id, _ := strconv.Atoi(idRe.FindStringSubmatch(sourceName)[1])
-	name := nameRe.FindStringSubmatch(sourceName)[1]
-	desc := descRe.FindStringSubmatch(sourceName)[1]
-	volume, _ := strconv.Atoi(volumeRe.FindStringSubmatch(sourceName)[1])
-	muted := muteRe.FindStringSubmatch(sourceName)[1] == "yes"
-	monitored := monitorRe.FindStringSubmatch(sourceName)[1] != "n/a"
-	monitor := monitorRe.FindStringSubmatch(sourceName)[1]
-
-	return Source{
-		ID:        id,
-		Name:      name,
-		Label:     desc,
-		Volume:    volume,
-		Muted:     muted,
-		Monitor:   monitor,
-		Monitored: monitored,
-		IsDefault: name == defaultName,
-	}
+	return getSinkInputsText()
 }
 
-func getDefaultSourceName() (string, error) {
-	cmd := exec.Command("pactl", "info")
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
+func GetSources() ([]Source, error) {
+	if c, err := pulse(); err == nil {
+		return getSourcesNative(c)
 	}
 
-	re, _ := regexp.Compile(`Default Source: (.+)`)
-	matches := re.FindStringSubmatch(string(out))
-	if len(matches) < 2 {
-		return "", nil
+	if hasJSONFormat() {
+		sources, err := getSourcesJSON()
+		if !errors.Is(err, errNoJSONFormat) {
+			return sources, err
+		}
 	}
 
-	return strings.TrimSpace(matches[1]), nil
+	return getSourcesText()
 }
 
-func GetSources() ([]Source, error) {
-	defaultName, _ := getDefaultSourceName()
-
-	cmd := exec.Command("pactl", "list", "sources")
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, err
+func GetSourceOutputs() ([]SourceOutput, error) {
+	if c, err := pulse(); err == nil {
+		return getSourceOutputsNative(c)
 	}
 
-	source := strings.Split(string(out), "Source #")
-	sources := make([]Source, 0, len(source)-1)
-
-	for _, sink := range source[1:] {
-		sources = append(sources, parseSources("Source #"+sink, defaultName))
+	if hasJSONFormat() {
+		sourceOutputs, err := getSourceOutputsJSON()
+		if !errors.Is(err, errNoJSONFormat) {
+			return sourceOutputs, err
+		}
 	}
 
-	return sources, nil
+	return getSourceOutputsText()
 }
 
-func ListenForChanges(callback func()) {
-	cmd := exec.Command("pactl", "subscribe")
-	stdout, _ := cmd.StdoutPipe()
-	cmd.Start()
+func GetCards() ([]Card, error) {
+	if c, err := pulse(); err == nil {
+		return getCardsNative(c)
+	}
 
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "sink") || strings.Contains(line, "server") {
-			callback()
+	if hasJSONFormat() {
+		cards, err := getCardsJSON()
+		if !errors.Is(err, errNoJSONFormat) {
+			return cards, err
 		}
 	}
+
+	return nil, ErrCardsUnsupported
 }
 
+// Missing cards are reported once, not with every poll
+var cardsUnsupportedOnce sync.Once
+
 func GetStatus() Status {
 	errPrefix := "ERROR [GetStatus()] -> "
 
@@ -253,12 +186,29 @@ func GetStatus() Status {
 		logger.Error().Err(err).Msgf("%s GetSinkInputs()", errPrefix)
 	}
 
+	sourceOutputs, err := GetSourceOutputs()
+	if err != nil {
+		logger.Error().Err(err).Msgf("%s GetSourceOutputs()", errPrefix)
+	}
+
+	cards, err := GetCards()
+	switch {
+	case errors.Is(err, ErrCardsUnsupported):
+		cardsUnsupportedOnce.Do(func() {
+			logger.Warn().Err(err).Msg("No cards in status, profiles can't be switched")
+		})
+	case err != nil:
+		logger.Error().Err(err).Msgf("%s GetCards()", errPrefix)
+	}
+
 	bi := buildinfo.Get()
 
 	return Status{
-		Sinks:      sinks,
-		SinkInputs: sinkInputs,
-		Sources:    sources,
-		BuildInfo:  *bi,
+		Sinks:         sinks,
+		SinkInputs:    sinkInputs,
+		Sources:       sources,
+		SourceOutputs: sourceOutputs,
+		Cards:         cards,
+		BuildInfo:     *bi,
 	}
 }
@@ -1,51 +1,314 @@
 package pactl
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/undg/pulse-remote/api/logger"
 )
 
-// setVolume adjusts volume state for PulseAudio devices.
+// channelUpdate computes new volume of every channel from current ones, all in percent. Nil leaves volume as it is.
+type channelUpdate func(names []string, percents []float64) ([]float64, error)
+
+// currentChannels reads channels of device from Status, when native protocol is not available
+func currentChannels(kind string, nameOrID string) ([]string, []float64, error) {
+	var channels []ChannelVolume
+	found := false
+
+	switch kind {
+	case "sink":
+		sinks, err := GetSinks()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range sinks {
+			if s.Name == nameOrID {
+				channels, found = s.Channels, true
+			}
+		}
+
+	case "source":
+		sources, err := GetSources()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range sources {
+			if s.Name == nameOrID {
+				channels, found = s.Channels, true
+			}
+		}
+
+	case "sink-input":
+		sinkInputs, err := GetSinkInputs()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range sinkInputs {
+			if strconv.Itoa(s.ID) == nameOrID {
+				channels, found = s.Channels, true
+			}
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("channels of %s are unknown", kind)
+	}
+
+	if !found || len(channels) == 0 {
+		return nil, nil, fmt.Errorf("%s %s: %w", kind, nameOrID, ErrNoEntity)
+	}
+
+	names, percents := splitChannels(channels)
+	return names, percents, nil
+}
+
+// runPactl executes pactl command. On failure pactl's stderr ends up in CommandError.
 //
 // Parameters:
-//   - kind: device type ("sink", "sink-input", "source", "source-input")
-//   - nameOrID: name for sinks/sources, numeric ID for inputs
-//   - volume: volume level
-func setVolume(kind string, nameOrID string, volume string) {
-	volumeInPercent := fmt.Sprint(volume) + "%"
-
-	cmd := exec.Command("pactl", "set-"+kind+"-volume", nameOrID, volumeInPercent)
+//   - caller: name of calling function for logs
+//   - target: name or ID of device, app or card
+//   - args: pactl arguments, the first one is command, fe. "set-default-sink"
+func runPactl(caller string, target string, args ...string) error {
+	cmd := exec.Command("pactl", args...)
 
-	logger.Debug().Msgf("$> pactl set-"+kind+"-volume %s %s", nameOrID, volumeInPercent)
-	logger.Info().Str("kind", kind).Str("nameOrID", nameOrID).Str("volumeInPercent", volumeInPercent).Msg("exec.Command(pactl ***) in setVolume()")
+	logger.Debug().Msgf("$> pactl %s", strings.Join(args, " "))
 
 	_, err := cmd.Output()
 	if err != nil {
-		logger.Error().Err(err).Msg("exec.Command(pactl ***) FAIL in setVolume()")
+		err = commandError(args[0], target, err)
+		logger.Error().Err(err).Msgf("exec.Command(pactl ***) FAIL in %s()", caller)
+	}
+	return err
+}
+
+// nativeDone tells if command was handled by native protocol. Otherwise pactl has to do it.
+func nativeDone(caller string, kind string, err error) bool {
+	if errors.Is(err, errNativeUnsupported) {
+		return false
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("kind", kind).Msgf("native protocol FAIL in %s()", caller)
+	}
+	return true
+}
+
+// updateChannels sets volume of every channel to values computed by update.
+//
+// Parameters:
+//   - caller: name of calling function for logs
+//   - kind: device type ("sink", "sink-input", "source", "source-output")
+//   - nameOrID: name for sinks/sources, numeric ID for apps
+//   - update: new channel volumes from current ones
+func updateChannels(caller string, kind string, nameOrID string, update channelUpdate) error {
+	command := "set-" + kind + "-volume"
+
+	if c, err := pulse(); err == nil {
+		err := updateChannelsNative(c, kind, nameOrID, update)
+		if nativeDone(caller, kind, err) {
+			return commandError(command, nameOrID, err)
+		}
+	}
+
+	names, percents, err := currentChannels(kind, nameOrID)
+	if err != nil {
+		logger.Error().Err(err).Str("kind", kind).Msgf("currentChannels FAIL in %s()", caller)
+		return commandError(command, nameOrID, err)
+	}
+
+	percents, err = update(names, percents)
+	if err != nil {
+		logger.Error().Err(err).Str("kind", kind).Msgf("FAIL in %s()", caller)
+		return err
 	}
+	if percents == nil {
+		return nil
+	}
+
+	return execSetVolume(caller, kind, nameOrID, percentArgs(capped(percents)))
+}
+
+func percentArgs(percents []float64) []string {
+	volumes := make([]string, len(percents))
+	for i, p := range percents {
+		volumes[i] = fmt.Sprintf("%.2f%%", p)
+	}
+	return volumes
+}
+
+func execSetVolume(caller string, kind string, nameOrID string, volumes []string) error {
+	logger.Info().Str("kind", kind).Str("nameOrID", nameOrID).Strs("volumes", volumes).Msgf("exec.Command(pactl ***) in %s()", caller)
+
+	return runPactl(caller, nameOrID, append([]string{"set-" + kind + "-volume", nameOrID}, volumes...)...)
+}
+
+// volumeUpdate parses volume level. With + or - sign it's relative, like pactl's +N%/-N%.
+func volumeUpdate(volume string) (update channelUpdate, relative bool, percent float64, err error) {
+	percent, err = strconv.ParseFloat(volume, 64)
+	if err != nil {
+		return nil, false, 0, fmt.Errorf("%w: volume %q is not a number", ErrInvalidArgument, volume)
+	}
+
+	relative = strings.HasPrefix(volume, "+") || strings.HasPrefix(volume, "-")
+	update = func(names []string, percents []float64) ([]float64, error) {
+		if relative {
+			return withDelta(percents, percent), nil
+		}
+		return withAverage(percents, percent), nil
+	}
+
+	return update, relative, percent, nil
+}
+
+// setVolume adjusts volume state for PulseAudio devices. Balance between channels is kept,
+// no channel stays above MaxVolume.
+//
+// Parameters:
+//   - kind: device type ("sink", "sink-input", "source", "source-output")
+//   - nameOrID: name for sinks/sources, numeric ID for apps
+//   - volume: volume level, average of all channels. With + or - sign change of current level, fe. "+5" or "-5"
+func setVolume(kind string, nameOrID string, volume string) error {
+	update, relative, percent, err := volumeUpdate(volume)
+	if err != nil {
+		logger.Error().Err(err).Str("volume", volume).Msg("invalid volume in setVolume()")
+		return err
+	}
+
+	if relative {
+		return changeVolume(kind, nameOrID, percent, update)
+	}
+
+	if c, err := pulse(); err == nil {
+		err := updateChannelsNative(c, kind, nameOrID, update)
+		if nativeDone("setVolume", kind, err) {
+			return commandError("set-"+kind+"-volume", nameOrID, err)
+		}
+	}
+
+	names, percents, err := currentChannels(kind, nameOrID)
+	if err == nil {
+		percents, _ = update(names, percents)
+		return execSetVolume("setVolume", kind, nameOrID, percentArgs(capped(percents)))
+	}
+
+	// Source outputs are not in Status with channels, they get the same volume on every channel
+	return execSetVolume("setVolume", kind, nameOrID, percentArgs([]float64{CapVolume(percent)}))
+}
+
+// changeVolume changes volume of every channel by delta. Native protocol has no relative volume,
+// channels are read and written back at once. Pactl gets +N%/-N%, so changes of other clients in between add up
+// instead of being lost. Going up is limited to what the loudest channel has left to MaxVolume.
+//
+// Parameters:
+//   - kind: device type ("sink", "sink-input", "source", "source-output")
+//   - nameOrID: name for sinks/sources, numeric ID for apps
+//   - delta: signed change, fe. 5 or -5
+//   - update: the same change for native protocol
+func changeVolume(kind string, nameOrID string, delta float64, update channelUpdate) error {
+	if c, err := pulse(); err == nil {
+		err := updateChannelsNative(c, kind, nameOrID, update)
+		if nativeDone("changeVolume", kind, err) {
+			return commandError("set-"+kind+"-volume", nameOrID, err)
+		}
+	}
+
+	if delta > 0 {
+		loudest, err := loudestChannel(kind, nameOrID)
+		if err != nil {
+			logger.Error().Err(err).Str("kind", kind).Msg("loudestChannel FAIL in changeVolume()")
+			return commandError("set-"+kind+"-volume", nameOrID, err)
+		}
+		delta = min(delta, MaxVolume-loudest)
+		if delta <= 0 {
+			return nil
+		}
+	}
+
+	return execSetVolume("changeVolume", kind, nameOrID, []string{fmt.Sprintf("%+.2f%%", delta)})
+}
+
+// loudestChannel reads volume of the loudest channel, when native protocol is not available.
+// Source outputs are not in Status with channels, their volume is used instead.
+//
+// Parameters:
+//   - kind: device type ("sink", "sink-input", "source", "source-output")
+//   - nameOrID: name for sinks/sources, numeric ID for apps
+func loudestChannel(kind string, nameOrID string) (float64, error) {
+	if kind != "source-output" {
+		_, percents, err := currentChannels(kind, nameOrID)
+		if err != nil {
+			return 0, err
+		}
+		return slices.Max(percents), nil
+	}
+
+	sourceOutputs, err := GetSourceOutputs()
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range sourceOutputs {
+		if strconv.Itoa(s.ID) == nameOrID {
+			return float64(s.Volume), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s %s: %w", kind, nameOrID, ErrNoEntity)
+}
+
+// setBalance moves volume between left and right channels, average volume stays the same.
+//
+// Parameters:
+//   - kind: device type ("sink", "sink-input", "source")
+//   - nameOrID: name for sinks/sources, numeric ID for apps
+//   - balance: from -1 (left only) through 0 (center) to 1 (right only)
+func setBalance(kind string, nameOrID string, balance float64) error {
+	if balance < -1 || balance > 1 {
+		return fmt.Errorf("%w: balance %v is not between -1 and 1", ErrInvalidArgument, balance)
+	}
+
+	return updateChannels("setBalance", kind, nameOrID, func(names []string, percents []float64) ([]float64, error) {
+		return withBalance(names, percents, balance), nil
+	})
+}
+
+// setChannelVolumes sets volume of every channel separately.
+//
+// Parameters:
+//   - kind: device type ("sink", "sink-input", "source")
+//   - nameOrID: name for sinks/sources, numeric ID for apps
+//   - volumes: volume level of every channel, in channel map order
+func setChannelVolumes(kind string, nameOrID string, volumes []float64) error {
+	return updateChannels("setChannelVolumes", kind, nameOrID, func(names []string, percents []float64) ([]float64, error) {
+		if len(volumes) != len(percents) {
+			return nil, fmt.Errorf("%w: expected %d channel volumes, got %d", ErrInvalidArgument, len(percents), len(volumes))
+		}
+		return volumes, nil
+	})
 }
 
 // setMuted adjusts mute state for PulseAudio devices.
 //
 // Parameters:
-//   - kind: device type ("sink", "sink-input", "source", "source-input")
-//   - nameOrID: name for sinks/sources, numeric ID for inputs
+//   - kind: device type ("sink", "sink-input", "source", "source-output")
+//   - nameOrID: name for sinks/sources, numeric ID for apps
 //   - muted: muted state
-func setMuted(kind string, nameOrID string, muted bool) {
-	mutedStr := strconv.FormatBool(muted)
+func setMuted(kind string, nameOrID string, muted bool) error {
+	command := "set-" + kind + "-mute"
 
-	cmd := exec.Command("pactl", "set-"+kind+"-mute", nameOrID, mutedStr)
+	if c, err := pulse(); err == nil {
+		err := setMutedNative(c, kind, nameOrID, muted)
+		if nativeDone("setMuted", kind, err) {
+			return commandError(command, nameOrID, err)
+		}
+	}
+
+	mutedStr := strconv.FormatBool(muted)
 
-	logger.Debug().Msgf("$> pactl set-"+kind+"-mute %s %s", nameOrID, mutedStr)
 	logger.Info().Str("kind", kind).Str("nameOrID", nameOrID).Str("mutedStr", mutedStr).Msg("exec.Command(pactl ***) in setMuted()")
 
-	_, err := cmd.Output()
-	if err != nil {
-		logger.Error().Err(err).Msg("exec.Command(pactl ***) FAIL in setMuted()")
-	}
+	return runPactl("setMuted", nameOrID, command, nameOrID, mutedStr)
 }
 
 // moveApp moves input or output app between sink/source devices
@@ -54,16 +317,40 @@ func setMuted(kind string, nameOrID string, muted bool) {
 //   - kind: device type ("sink-input", "source-output")
 //   - appID: sink-input ID or source-output ID
 //   - deviceName: sink name or source name
-func moveApp(kind string, appID string, deviceName string) {
-	cmd := exec.Command("pactl", "move-"+kind, appID, deviceName)
+func moveApp(kind string, appID string, deviceName string) error {
+	command := "move-" + kind
+
+	if c, err := pulse(); err == nil {
+		err := moveAppNative(c, kind, appID, deviceName)
+		if nativeDone("moveApp", kind, err) {
+			return commandError(command, appID, err)
+		}
+	}
 
-	logger.Debug().Msgf("$> pactl move-"+kind+"-mute %s %s", appID, deviceName)
 	logger.Info().Str("kind", kind).Str("appID", appID).Str("deviceName", deviceName).Msg("exec.Command(pactl ***) in moveApp()")
 
-	_, err := cmd.Output()
-	if err != nil {
-		logger.Error().Err(err).Msg("exec.Command(pactl ***) FAIL in moveApp()")
+	return runPactl("moveApp", appID, command, appID, deviceName)
+}
+
+// setPort switches active port of sink or source, fe. from line-out to headphones.
+//
+// Parameters:
+//   - kind: device type ("sink", "source")
+//   - name: device name
+//   - port: port name
+func setPort(kind string, name string, port string) error {
+	command := "set-" + kind + "-port"
+
+	if c, err := pulse(); err == nil {
+		err := setPortNative(c, kind, name, port)
+		if nativeDone("setPort", kind, err) {
+			return commandError(command, name, err)
+		}
 	}
+
+	logger.Info().Str("kind", kind).Str("name", name).Str("port", port).Msg("exec.Command(pactl ***) in setPort()")
+
+	return runPactl("setPort", name, command, name, port)
 }
 
 // setDefault sets default sink or source device.
@@ -71,14 +358,34 @@ func moveApp(kind string, appID string, deviceName string) {
 // Parameters:
 //   - kind: device type ("sink", "source")
 //   - name: device name
-func setDefault(kind string, name string) {
-	cmd := exec.Command("pactl", "set-default-"+kind, name)
+func setDefault(kind string, name string) error {
+	command := "set-default-" + kind
+
+	if c, err := pulse(); err == nil {
+		err := setDefaultNative(c, kind, name)
+		if nativeDone("setDefault", kind, err) {
+			return commandError(command, name, err)
+		}
+	}
 
-	logger.Debug().Msgf("$> pactl set-default-%s %s", kind, name)
 	logger.Info().Str("kind", kind).Str("name", name).Msg("exec.Command(pactl ***) in setDefault()")
 
-	_, err := cmd.Output()
-	if err != nil {
-		logger.Error().Err(err).Msg("exec.Command(pactl ***) FAIL in setDefault()")
+	return runPactl("setDefault", name, command, name)
+}
+
+// setCardProfile switches card to another profile, fe. bluetooth headset from A2DP to HSP/HFP.
+//
+// Parameters:
+//   - cardName: card name
+//   - profile: profile name
+func setCardProfile(cardName string, profile string) error {
+	if c, err := pulse(); err == nil {
+		err := c.SetCardProfile(cardName, profile)
+		nativeDone("setCardProfile", "card", err)
+		return commandError("set-card-profile", cardName, err)
 	}
+
+	logger.Info().Str("cardName", cardName).Str("profile", profile).Msg("exec.Command(pactl ***) in setCardProfile()")
+
+	return runPactl("setCardProfile", cardName, "set-card-profile", cardName, profile)
 }
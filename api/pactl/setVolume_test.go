@@ -0,0 +1,77 @@
+package pactl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePactl puts pactl script with one sink at 145% first in PATH and returns file with its calls
+func fakePactl(t *testing.T) string {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+
+	script := `#!/bin/sh
+echo "$*" >> ` + calls + `
+case "$*" in
+*"list sinks"*) echo '[{"index": 55, "name": "speakers", "channel_map": "front-left,front-right", "volume": {"front-left": {"value": 95027}, "front-right": {"value": 65536}}}]' ;;
+*info*) echo '{"default_sink_name": "speakers"}' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "pactl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	// No native protocol, every command goes through pactl
+	t.Setenv("PULSE_SERVER", filepath.Join(dir, "no-socket"))
+
+	return calls
+}
+
+func TestChangeVolume(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Volume string
+		Want   string
+	}{
+		{"RaiseUpToMaxVolume", "+10", "set-sink-volume speakers +5.00%"},
+		{"RaiseBelowMaxVolume", "+3", "set-sink-volume speakers +3.00%"},
+		{"Lower", "-10", "set-sink-volume speakers -10.00%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			calls := fakePactl(t)
+
+			if err := SetSinkVolume("speakers", tt.Volume); err != nil {
+				t.Fatalf("[Err] SetSinkVolume: %v", err)
+			}
+
+			out, err := os.ReadFile(calls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			if got := lines[len(lines)-1]; got != tt.Want {
+				t.Errorf("[Err] Expected %q, got %q", tt.Want, got)
+			}
+		})
+	}
+
+	t.Run("AtMaxVolume", func(t *testing.T) {
+		defer func(prev float64) { MaxVolume = prev }(MaxVolume)
+		MaxVolume = 100
+		calls := fakePactl(t)
+
+		if err := SetSinkVolume("speakers", "+5"); err != nil {
+			t.Fatalf("[Err] SetSinkVolume: %v", err)
+		}
+
+		out, _ := os.ReadFile(calls)
+		if strings.Contains(string(out), "set-sink-volume") {
+			t.Errorf("[Err] Expected no volume change above MaxVolume, got calls:\n%s", out)
+		}
+	})
+}
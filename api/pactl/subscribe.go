@@ -0,0 +1,168 @@
+package pactl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/pactl/native"
+)
+
+// Event is a single line from `pactl subscribe`, fe. "Event 'change' on sink #55"
+type Event struct {
+	Type     string `json:"type" doc:"new, change or remove"`
+	Facility string `json:"facility" doc:"sink, source, sink-input, source-output, card, server..."`
+	Index    int    `json:"index" doc:"Index of changed object, -1 for server"`
+}
+
+var eventRe = regexp.MustCompile(`^Event '(\w+)' on ([\w-]+)(?: #(\d+))?`)
+
+// Facilities that can change anything in Status
+var statusFacilities = map[string]bool{
+	"sink":          true,
+	"source":        true,
+	"sink-input":    true,
+	"source-output": true,
+	"card":          true,
+	"server":        true,
+}
+
+const (
+	subscribeRetryMin = time.Second
+	subscribeRetryMax = 30 * time.Second
+)
+
+func parseEvent(line string) (Event, bool) {
+	m := eventRe.FindStringSubmatch(line)
+	if m == nil {
+		return Event{}, false
+	}
+
+	index := -1
+	if m[3] != "" {
+		index, _ = strconv.Atoi(m[3])
+	}
+
+	return Event{Type: m[1], Facility: m[2], Index: index}, true
+}
+
+// subscribe runs single `pactl subscribe` process until it exits. Process is killed when ctx is done.
+func subscribe(ctx context.Context, callback func(Event)) error {
+	cmd := exec.CommandContext(ctx, "pactl", "subscribe")
+	// Event lines are translated, parser expects english
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	logger.Info().Int("pid", cmd.Process.Pid).Msg("pactl subscribe started")
+
+	// Anything could change while we were not listening
+	callback(Event{Type: "change", Facility: "server", Index: -1})
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		event, ok := parseEvent(scanner.Text())
+		if !ok || !statusFacilities[event.Facility] {
+			continue
+		}
+
+		logger.Trace().Interface("event", event).Msg("pactl subscribe")
+		callback(event)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("pactl subscribe exited")
+}
+
+// subscribeNative listens on dedicated native connection until it dies or ctx is done.
+func subscribeNative(ctx context.Context, callback func(Event)) error {
+	c, err := native.Dial("")
+	if err != nil {
+		return fmt.Errorf("%w: %w", errNativeUnavailable, err)
+	}
+	defer c.Close()
+
+	// Closed connection ends Events()
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+
+	mask := native.SubscribeSink | native.SubscribeSource | native.SubscribeSinkInput |
+		native.SubscribeSourceOutput | native.SubscribeCard | native.SubscribeServer
+	if err := c.Subscribe(mask); err != nil {
+		return err
+	}
+
+	logger.Info().Uint32("protocol_version", c.Version()).Msg("native subscribe started")
+
+	// Anything could change while we were not listening
+	callback(Event{Type: "change", Facility: "server", Index: -1})
+
+	for ev := range c.Events() {
+		event := Event{Type: ev.Type, Facility: ev.Facility, Index: int(ev.Index)}
+		if ev.Index == native.InvalidIndex {
+			event.Index = -1
+		}
+
+		if !statusFacilities[event.Facility] {
+			continue
+		}
+
+		logger.Trace().Interface("event", event).Msg("native subscribe")
+		callback(event)
+	}
+
+	return c.Err()
+}
+
+// ListenForChanges calls callback for every event that may change Status.
+// Native protocol is used when available, `pactl subscribe` otherwise.
+// Subscription is restarted with backoff whenever it dies, fe. when pipewire-pulse restarts.
+// Blocks until ctx is done, subscription doesn't outlive it.
+func ListenForChanges(ctx context.Context, callback func(Event)) {
+	retry := subscribeRetryMin
+
+	for {
+		started := time.Now()
+		err := subscribeNative(ctx, callback)
+		if errors.Is(err, errNativeUnavailable) {
+			err = subscribe(ctx, callback)
+		}
+
+		if ctx.Err() != nil {
+			logger.Info().Msg("subscribe stopped")
+			return
+		}
+
+		// Was running fine for a while, this is fresh failure
+		if time.Since(started) > subscribeRetryMax {
+			retry = subscribeRetryMin
+		}
+
+		logger.Warn().Err(err).Dur("retry_in", retry).Msg("subscribe died, restarting")
+		select {
+		case <-time.After(retry):
+		case <-ctx.Done():
+			logger.Info().Msg("subscribe stopped")
+			return
+		}
+
+		retry = min(retry*2, subscribeRetryMax)
+	}
+}
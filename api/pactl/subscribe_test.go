@@ -0,0 +1,32 @@
+package pactl
+
+import "testing"
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Input string
+		Want  Event
+		OK    bool
+	}{
+		{"Sink", "Event 'change' on sink #55", Event{"change", "sink", 55}, true},
+		{"SinkInput", "Event 'new' on sink-input #120", Event{"new", "sink-input", 120}, true},
+		{"SourceOutput", "Event 'remove' on source-output #7", Event{"remove", "source-output", 7}, true},
+		{"Server", "Event 'change' on server", Event{"change", "server", -1}, true},
+		{"Card", "Event 'change' on card #42", Event{"change", "card", 42}, true},
+		{"Garbage", "Connection failure: Connection refused", Event{}, false},
+		{"Empty", "", Event{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, ok := parseEvent(tt.Input)
+			if ok != tt.OK {
+				t.Fatalf("Expected ok=%v, got %v", tt.OK, ok)
+			}
+			if got != tt.Want {
+				t.Errorf("Expected %+v, got %+v", tt.Want, got)
+			}
+		})
+	}
+}
@@ -3,36 +3,92 @@ package pactl
 import "github.com/undg/pulse-remote/api/buildinfo"
 
 type Status = struct {
-	Sinks      []Sink              `json:"sinks" doc:"List of audio devices"`
-	SinkInputs []SinkInput         `json:"sinkInputs" doc:"List of applications that are playing audio"`
-	Sources    []Source            `json:"sources" doc:"List of microphones and other sources"`
-	BuildInfo  buildinfo.BuildInfo `json:"buildInfo" doc:"Build information"`
+	Sinks         []Sink              `json:"sinks" doc:"List of audio devices"`
+	SinkInputs    []SinkInput         `json:"sinkInputs" doc:"List of applications that are playing audio"`
+	Sources       []Source            `json:"sources" doc:"List of microphones and other sources"`
+	SourceOutputs []SourceOutput      `json:"sourceOutputs" doc:"List of applications that are recording audio"`
+	Cards         []Card              `json:"cards" doc:"List of sound cards with their profiles"`
+	BuildInfo     buildinfo.BuildInfo `json:"buildInfo" doc:"Build information"`
 }
 
 type Sink struct {
-	ID        int    `json:"id" doc:"The id of the sink. Same  as name"`
-	Name      string `json:"name" doc:"The name of the sink. Same as id"`
-	Label     string `json:"label" doc:"Human-readable label for the sink"`
-	Volume    int    `json:"volume" doc:"Current volume level of the sink"`
-	Muted     bool   `json:"muted" doc:"Whether the sink is muted"`
-	IsDefault bool   `json:"isDefault" doc:"Whether this sink is the current default"`
+	ID         int             `json:"id" doc:"The id of the sink. Same  as name"`
+	Name       string          `json:"name" doc:"The name of the sink. Same as id"`
+	Label      string          `json:"label" doc:"Human-readable label for the sink"`
+	Volume     int             `json:"volume" doc:"Current volume level of the sink, average of all channels"`
+	Channels   []ChannelVolume `json:"channels" doc:"Volume of every channel in channel map order"`
+	Balance    float64         `json:"balance" doc:"Left/right balance from -1 (left only) through 0 (center) to 1 (right only)"`
+	Muted      bool            `json:"muted" doc:"Whether the sink is muted"`
+	IsDefault  bool            `json:"isDefault" doc:"Whether this sink is the current default"`
+	ActivePort string          `json:"activePort" doc:"Name of the active port, empty if device has no ports"`
+	Ports      []Port          `json:"ports" doc:"Ports of the sink, fe. headphones and line-out"`
 }
 
 type Source struct {
-	ID        int    `json:"id" doc:"Unique numeric identifier of the source"`
-	Name      string `json:"name" doc:"Unique string identifier of the source"`
-	Label     string `json:"label" doc:"Human-readable label for the source"`
-	Volume    int    `json:"volume" doc:"Current volume level of the source"`
-	Muted     bool   `json:"muted" doc:"Whether the source is muted"`
-	Monitor   string `json:"monitor" doc:"Name of monitor source"`
-	Monitored bool   `json:"monitored" doc:"Whether source is being monitored"`
-	IsDefault bool   `json:"isDefault" doc:"Whether this source is the current default"`
+	ID         int             `json:"id" doc:"Unique numeric identifier of the source"`
+	Name       string          `json:"name" doc:"Unique string identifier of the source"`
+	Label      string          `json:"label" doc:"Human-readable label for the source"`
+	Volume     int             `json:"volume" doc:"Current volume level of the source, average of all channels"`
+	Channels   []ChannelVolume `json:"channels" doc:"Volume of every channel in channel map order"`
+	Balance    float64         `json:"balance" doc:"Left/right balance from -1 (left only) through 0 (center) to 1 (right only)"`
+	Muted      bool            `json:"muted" doc:"Whether the source is muted"`
+	Monitor    string          `json:"monitor" doc:"Name of monitor source"`
+	Monitored  bool            `json:"monitored" doc:"Whether source is being monitored"`
+	IsDefault  bool            `json:"isDefault" doc:"Whether this source is the current default"`
+	ActivePort string          `json:"activePort" doc:"Name of the active port, empty if device has no ports"`
+	Ports      []Port          `json:"ports" doc:"Ports of the source, fe. internal and headset microphone"`
+}
+
+type ChannelVolume struct {
+	Name   string `json:"name" doc:"Channel position, fe. front-left, mono, aux0"`
+	Volume int    `json:"volume" doc:"Volume level of the channel"`
+}
+
+// Port availability, fe. jack plugged in or not. Unknown when hardware can't detect it.
+const (
+	PortAvailableUnknown = "unknown"
+	PortAvailableNo      = "no"
+	PortAvailableYes     = "yes"
+)
+
+type Port struct {
+	Name      string `json:"name" doc:"Port name, fe. analog-output-headphones"`
+	Label     string `json:"label" doc:"Human-readable label for the port"`
+	Priority  int    `json:"priority" doc:"Higher priority is preferred by the sound server"`
+	Available string `json:"available" enum:"unknown,no,yes" doc:"Whether something is plugged into the port"`
 }
 
 type SinkInput struct {
-	ID     int    `json:"id" doc:"The id of the sink. Same  as name"`
-	SinkID int    `json:"sinkId" doc:"Id of parrent device, same as sink.id"`
-	Label  string `json:"label" doc:"Human-readable label for the sink"`
-	Volume int    `json:"volume" doc:"Current volume level of the sink"`
-	Muted  bool   `json:"muted" doc:"Whether the sink is muted"`
+	ID       int             `json:"id" doc:"The id of the sink. Same  as name"`
+	SinkID   int             `json:"sinkId" doc:"Id of parrent device, same as sink.id"`
+	Label    string          `json:"label" doc:"Human-readable label for the sink"`
+	Volume   int             `json:"volume" doc:"Current volume level of the sink, average of all channels"`
+	Channels []ChannelVolume `json:"channels" doc:"Volume of every channel in channel map order"`
+	Balance  float64         `json:"balance" doc:"Left/right balance from -1 (left only) through 0 (center) to 1 (right only)"`
+	Muted    bool            `json:"muted" doc:"Whether the sink is muted"`
+}
+
+type SourceOutput struct {
+	ID       int    `json:"id" doc:"Unique numeric identifier of the source output"`
+	SourceID int    `json:"sourceId" doc:"Id of parrent device, same as source.id"`
+	Label    string `json:"label" doc:"Human-readable label for the application"`
+	Volume   int    `json:"volume" doc:"Current volume level of the source output"`
+	Muted    bool   `json:"muted" doc:"Whether the source output is muted"`
+}
+
+type Card struct {
+	ID            int           `json:"id" doc:"Unique numeric identifier of the card"`
+	Name          string        `json:"name" doc:"Unique string identifier of the card"`
+	Label         string        `json:"label" doc:"Human-readable label for the card"`
+	ActiveProfile string        `json:"activeProfile" doc:"Name of the active profile"`
+	Profiles      []CardProfile `json:"profiles" doc:"Profiles sorted by priority, highest first"`
+}
+
+type CardProfile struct {
+	Name      string `json:"name" doc:"Profile name, fe. a2dp-sink or output:hdmi-surround"`
+	Label     string `json:"label" doc:"Human-readable label for the profile"`
+	Sinks     int    `json:"sinks" doc:"Number of sinks created by the profile"`
+	Sources   int    `json:"sources" doc:"Number of sources created by the profile"`
+	Priority  int    `json:"priority" doc:"Higher priority is preferred by the sound server"`
+	Available bool   `json:"available" doc:"Whether the profile can be activated, fe. HDMI cable is connected"`
 }
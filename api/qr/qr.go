@@ -0,0 +1,64 @@
+// Package qr renders QR codes for the startup banner and pairing endpoint.
+package qr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func encode(content string) (*qrcode.QRCode, error) {
+	q, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("qr %q: %w", content, err)
+	}
+	return q, nil
+}
+
+// PNG is size x size pixels, black on white
+func PNG(content string, size int) ([]byte, error) {
+	q, err := encode(content)
+	if err != nil {
+		return nil, err
+	}
+	return q.PNG(size)
+}
+
+// SVG scales to any size, one unit is one module
+func SVG(content string) ([]byte, error) {
+	q, err := encode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	bits := q.Bitmap()
+	var path strings.Builder
+	for y, row := range bits {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	n := len(bits)
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/></svg>`, n, n, n, n, path.String())
+	return []byte(svg), nil
+}
+
+// Terminal draws two modules per character with half blocks. Colors are forced with ANSI codes,
+// so it scans on light and dark terminals alike.
+func Terminal(content string) (string, error) {
+	q, err := encode(content)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for line := range strings.Lines(q.ToSmallString(false)) {
+		out.WriteString("\x1b[97;40m" + strings.TrimSuffix(line, "\n") + "\x1b[0m\n")
+	}
+	return out.String(), nil
+}
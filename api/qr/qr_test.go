@@ -0,0 +1,49 @@
+package qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const content = "http://192.168.1.10:8448/login?pair=token"
+
+func TestPNG(t *testing.T) {
+	png, err := PNG(content, 256)
+	if err != nil {
+		t.Fatalf("[Err] PNG: %v", err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("[Err] Expected PNG signature, got %q", png[:8])
+	}
+}
+
+func TestSVG(t *testing.T) {
+	svg, err := SVG(content)
+	if err != nil {
+		t.Fatalf("[Err] SVG: %v", err)
+	}
+
+	s := string(svg)
+	if !strings.HasPrefix(s, "<svg") || !strings.HasSuffix(s, "</svg>") || !strings.Contains(s, `fill="#000"`) {
+		t.Errorf("[Err] Unexpected SVG %s", s)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	code, err := Terminal(content)
+	if err != nil {
+		t.Fatalf("[Err] Terminal: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "\x1b[97;40m") || !strings.HasSuffix(line, "\x1b[0m") {
+			t.Fatalf("[Err] Expected every line in forced colors, got %q", line)
+		}
+	}
+	// Two modules per line, with 4 modules of quiet zone on both sides
+	if width := len([]rune(lines[0])) - len("\x1b[97;40m") - len("\x1b[0m"); width < 2*len(lines)-2 {
+		t.Errorf("[Err] Expected square code, got %d lines of %d", len(lines), width)
+	}
+}
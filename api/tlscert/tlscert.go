@@ -0,0 +1,341 @@
+// Package tlscert generates local CA and server certificate, so HTTPS works without any setup.
+// Phones trust the server once CA certificate is installed on them. Server certificate is renewed
+// when it expires or IP address changes, CA stays the same.
+package tlscert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/undg/pulse-remote/api/logger"
+)
+
+const (
+	caValidity = 10 * 365 * 24 * time.Hour
+	// Apple doesn't accept longer
+	certValidity = 397 * 24 * time.Hour
+	renewBefore  = 30 * 24 * time.Hour
+)
+
+// CA can sign only for local addresses and names: private, link-local, CGNAT (fe. Tailscale), loopback and ULA.
+// Phone that trusts it can't be intercepted on the internet by someone who stole CA key from the desktop.
+var (
+	permittedIPRanges = []string{
+		"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16", "100.64.0.0/10", "127.0.0.0/8",
+		"fc00::/7", "fe80::/10", "::1/128",
+	}
+	// Hostname is permitted as well, when it's a single label
+	permittedDNSDomains = []string{"local", "localhost"}
+)
+
+// Files are PEM encoded. CA is the one to install on phones.
+type Files struct {
+	CA   string
+	Cert string
+	Key  string
+}
+
+func filesIn(dir string) Files {
+	return Files{
+		CA:   filepath.Join(dir, "ca.pem"),
+		Cert: filepath.Join(dir, "server.pem"),
+		Key:  filepath.Join(dir, "server-key.pem"),
+	}
+}
+
+func caKeyFile(dir string) string {
+	return filepath.Join(dir, "ca-key.pem")
+}
+
+// Hosts are names and addresses server certificate covers, ips are the local ones, fe. from utils.GetLocalIPs
+func Hosts(ips []net.IP) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname, hostname+".local")
+	}
+	for _, ip := range ips {
+		hosts = append(hosts, ip.String())
+	}
+	return hosts
+}
+
+// Ensure returns certificate from dir covering every host, IP or DNS name. Missing CA and server certificate
+// are generated, server certificate is generated again when it doesn't cover hosts or expires soon.
+func Ensure(dir string, hosts []string) (Files, error) {
+	files := filesIn(dir)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return files, fmt.Errorf("tls: %w", err)
+	}
+
+	ca, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return files, err
+	}
+	hosts = permittedHosts(ca, hosts)
+
+	err = checkCert(files, ca, hosts)
+	if err == nil {
+		return files, nil
+	}
+
+	logger.Info().Str("reason", err.Error()).Msg("Generating server certificate")
+	return files, createCert(files, ca, caKey, hosts)
+}
+
+// Fingerprint is SHA-256 of the first certificate in file, fe. AB:CD:...
+func Fingerprint(certFile string) (string, error) {
+	cert, err := readCert(certFile)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":"), nil
+}
+
+func loadOrCreateCA(dir string) (*x509.Certificate, crypto.Signer, error) {
+	files := filesIn(dir)
+
+	ca, errCert := readCert(files.CA)
+	key, errKey := readKey(caKeyFile(dir))
+	switch {
+	case errCert == nil && errKey == nil:
+		if !constrained(ca) {
+			logger.Warn().Str("dir", dir).
+				Msg("Local CA can sign for any domain, remove it to generate one limited to local network and install it on phones again")
+		}
+		return ca, key, nil
+	case !errors.Is(errCert, os.ErrNotExist) || !errors.Is(errKey, os.ErrNotExist):
+		// Half of CA, or broken one. Generating new would make every phone distrust the server.
+		return nil, nil, fmt.Errorf("tls: CA in %s: %w", dir, errors.Join(errCert, errKey))
+	}
+
+	logger.Info().Str("dir", dir).Msg("Generating local CA")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tls: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{Organization: []string{"pulse-remote"}, CommonName: "pulse-remote local CA " + hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         permittedDomains(hostname),
+	}
+	for _, cidr := range permittedIPRanges {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		template.PermittedIPRanges = append(template.PermittedIPRanges, ipNet)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tls: %w", err)
+	}
+	if err := writeKey(caKeyFile(dir), key); err != nil {
+		return nil, nil, err
+	}
+	if err := writeCert(files.CA, der); err != nil {
+		return nil, nil, err
+	}
+
+	ca, err = x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tls: %w", err)
+	}
+	return ca, key, nil
+}
+
+// permittedDomains of CA on machine with hostname. Fully qualified hostname, fe. box.example.com,
+// would permit every name under public domain, it's left out.
+func permittedDomains(hostname string) []string {
+	domains := slices.Clone(permittedDNSDomains)
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	if hostname != "" && !strings.Contains(hostname, ".") {
+		domains = append(domains, hostname)
+	}
+	return domains
+}
+
+func constrained(ca *x509.Certificate) bool {
+	return len(ca.PermittedDNSDomains) > 0 || len(ca.PermittedIPRanges) > 0
+}
+
+// permittedHosts leaves out hosts CA can't sign for, fe. public IP. Certificate with them would be rejected whole.
+func permittedHosts(ca *x509.Certificate, hosts []string) []string {
+	if !constrained(ca) {
+		return hosts
+	}
+
+	permitted := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if permits(ca, host) {
+			permitted = append(permitted, host)
+		} else {
+			logger.Warn().Str("host", host).Msg("Host is outside of local network, server certificate doesn't cover it")
+		}
+	}
+	return permitted
+}
+
+// permits tells if host is in CA's name constraints, the same way clients check them
+func permits(ca *x509.Certificate, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return slices.ContainsFunc(ca.PermittedIPRanges, func(n *net.IPNet) bool { return n.Contains(ip) })
+	}
+
+	host = strings.ToLower(host)
+	return slices.ContainsFunc(ca.PermittedDNSDomains, func(domain string) bool {
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	})
+}
+
+// checkCert returns why server certificate can't be used
+func checkCert(files Files, ca *x509.Certificate, hosts []string) error {
+	cert, err := readCert(files.Cert)
+	if err != nil {
+		return err
+	}
+	if _, err := readKey(files.Key); err != nil {
+		return err
+	}
+
+	if err := cert.CheckSignatureFrom(ca); err != nil {
+		return fmt.Errorf("not signed by CA: %w", err)
+	}
+	if time.Until(cert.NotAfter) < renewBefore {
+		return fmt.Errorf("expires %s", cert.NotAfter.Format(time.DateOnly))
+	}
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			return fmt.Errorf("doesn't cover %s", host)
+		}
+	}
+	return nil
+}
+
+func createCert(files Files, ca *x509.Certificate, caKey crypto.Signer, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{Organization: []string{"pulse-remote"}, CommonName: hostname},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	if err := writeKey(files.Key, key); err != nil {
+		return err
+	}
+	return writeCert(files.Cert, der)
+}
+
+func serialNumber() *big.Int {
+	n, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return n
+}
+
+func readPEM(path string, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: no %s PEM block", path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+func readCert(path string) (*x509.Certificate, error) {
+	der, err := readPEM(path, "CERTIFICATE")
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func readKey(path string) (crypto.Signer, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: key can't sign", path)
+	}
+	return signer, nil
+}
+
+func writeCert(path string, der []byte) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	return nil
+}
+
+func writeKey(path string, key crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	return nil
+}
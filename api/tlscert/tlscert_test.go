@@ -0,0 +1,135 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestEnsure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+	hosts := []string{"localhost", "127.0.0.1", "::1", "192.168.1.10"}
+
+	files, err := Ensure(dir, hosts)
+	if err != nil {
+		t.Fatalf("[Err] Ensure: %v", err)
+	}
+
+	if info, err := os.Stat(files.Key); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("[Err] Expected key readable only by owner: %v %v", info, err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		t.Fatalf("[Err] LoadX509KeyPair: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(pair.Certificate[0])
+
+	ca, _ := readCert(files.CA)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, host := range hosts {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("[Err] Expected certificate valid for %s: %v", host, err)
+		}
+	}
+
+	t.Run("Reuse", func(t *testing.T) {
+		before, _ := Fingerprint(files.Cert)
+		if _, err := Ensure(dir, hosts[:2]); err != nil {
+			t.Fatalf("[Err] Ensure: %v", err)
+		}
+		if after, _ := Fingerprint(files.Cert); after != before {
+			t.Errorf("[Err] Expected certificate to be reused when it covers hosts")
+		}
+	})
+
+	t.Run("NewIP", func(t *testing.T) {
+		caBefore, _ := Fingerprint(files.CA)
+		certBefore, _ := Fingerprint(files.Cert)
+
+		if _, err := Ensure(dir, append(hosts, "10.0.0.5")); err != nil {
+			t.Fatalf("[Err] Ensure: %v", err)
+		}
+		if after, _ := Fingerprint(files.Cert); after == certBefore {
+			t.Errorf("[Err] Expected new certificate for new IP")
+		}
+		if after, _ := Fingerprint(files.CA); after != caBefore {
+			t.Errorf("[Err] Expected the same CA, phones trust it already")
+		}
+	})
+
+	t.Run("NameConstraints", func(t *testing.T) {
+		if !ca.PermittedDNSDomainsCritical || len(ca.PermittedIPRanges) == 0 || !slices.Contains(ca.PermittedDNSDomains, "local") {
+			t.Fatalf("[Err] Expected critical constraints to local network, got %v %v", ca.PermittedDNSDomains, ca.PermittedIPRanges)
+		}
+
+		// Public addresses are left out, the rest of certificate stays valid
+		if _, err := Ensure(dir, append(hosts, "203.0.113.7", "example.com")); err != nil {
+			t.Fatalf("[Err] Ensure: %v", err)
+		}
+		cert, _ := readCert(files.Cert)
+		public := slices.ContainsFunc(cert.IPAddresses, func(ip net.IP) bool { return ip.String() == "203.0.113.7" })
+		if public || slices.Contains(cert.DNSNames, "example.com") {
+			t.Errorf("[Err] Expected public hosts left out, got %v %v", cert.DNSNames, cert.IPAddresses)
+		}
+
+		// Stolen CA key can't sign for public names
+		caKey, _ := readKey(caKeyFile(dir))
+		for _, host := range []string{"example.com", "8.8.8.8"} {
+			evil := filesIn(t.TempDir())
+			if err := createCert(evil, ca, caKey, []string{host}); err != nil {
+				t.Fatalf("[Err] createCert: %v", err)
+			}
+			evilCert, _ := readCert(evil.Cert)
+			if _, err := evilCert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err == nil {
+				t.Errorf("[Err] Expected certificate for %s rejected by name constraints", host)
+			}
+		}
+	})
+
+	t.Run("BrokenCA", func(t *testing.T) {
+		os.WriteFile(caKeyFile(dir), []byte("garbage"), 0o600)
+		if _, err := Ensure(dir, hosts); err == nil {
+			t.Errorf("[Err] Expected error instead of silently replacing CA")
+		}
+	})
+}
+
+func TestPermittedDomains(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     []string
+	}{
+		{"Desktop", []string{"local", "localhost", "desktop"}},
+		{"box.example.com", []string{"local", "localhost"}},
+		{"box.example.com.", []string{"local", "localhost"}},
+		{"", []string{"local", "localhost"}},
+	}
+
+	for _, tt := range tests {
+		if got := permittedDomains(tt.hostname); !slices.Equal(got, tt.want) {
+			t.Errorf("[Err] Expected %v for hostname %q, got %v", tt.want, tt.hostname, got)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	files, err := Ensure(t.TempDir(), []string{"localhost"})
+	if err != nil {
+		t.Fatalf("[Err] Ensure: %v", err)
+	}
+
+	fingerprint, err := Fingerprint(files.Cert)
+	if err != nil {
+		t.Fatalf("[Err] Fingerprint: %v", err)
+	}
+	if !regexp.MustCompile(`^([0-9A-F]{2}:){31}[0-9A-F]{2}$`).MatchString(fingerprint) {
+		t.Errorf("[Err] Unexpected fingerprint %s", fingerprint)
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"github.com/undg/pulse-remote/api/json"
 )
 
-const PORT = ":8448"
-
 func ActionsToStrings(actions []json.Action) []string {
 	strs := make([]string, len(actions))
 	for i, action := range actions {
@@ -16,23 +14,13 @@ func ActionsToStrings(actions []json.Action) []string {
 	return strs
 }
 
-func IsLocalIP(ip net.IP) bool {
-	if ip.IsLoopback() {
-		return true
-	}
+// cgnat is shared address space of carrier-grade NAT, also used by Tailscale and other VPNs
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
 
-	if ip4 := ip.To4(); ip4 != nil {
-		switch {
-		case ip4[0] == 10:
-			return true
-		case ip4[0] == 172 && ip4[1] >= 16 && ip4[1] <= 31:
-			return true
-		case ip4[0] == 192 && ip4[1] == 168:
-			return true
-		}
-	}
-
-	return false
+// IsLocalIP is true for addresses not routed on the internet: loopback, private (10/8, 172.16/12, 192.168/16,
+// IPv6 ULA fc00::/7), link-local (169.254/16, fe80::/10) and CGNAT 100.64/10.
+func IsLocalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || cgnat.Contains(ip)
 }
 
 func GetLocalIP() (string, error) {
@@ -50,3 +38,26 @@ func GetLocalIP() (string, error) {
 	}
 	return "", nil
 }
+
+// GetLocalIPs returns every address other devices could reach this machine on, IPv4 first.
+// Loopback and link-local addresses are skipped.
+func GetLocalIPs() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ip4s, ip6s []net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ip4 := ipnet.IP.To4(); ip4 != nil {
+			ip4s = append(ip4s, ip4)
+		} else {
+			ip6s = append(ip6s, ipnet.IP)
+		}
+	}
+	return append(ip4s, ip6s...), nil
+}
@@ -23,10 +23,24 @@ func TestIsLocalIP(t *testing.T) {
 		{"Private IPv4 (192.168.x.x)", "192.168.0.1", true, false},
 		{"Public IPv4", "8.8.8.8", false, false},
 		{"Public IPv6", "2001:4860:4860::8888", false, false},
+		{"IPv6 ULA (fc00::/7)", "fd12:3456:789a::1", true, false},
+		{"IPv6 ULA lower half", "fc00::1", true, false},
+		{"IPv6 link-local (fe80::/10)", "fe80::1", true, false},
+		{"IPv4 link-local", "169.254.10.1", true, false},
+		{"CGNAT", "100.64.0.1", true, false},
+		{"Tailscale IPv4", "100.101.102.103", true, false},
+		{"Tailscale IPv6", "fd7a:115c:a1e0::1", true, false},
+		{"IPv4-mapped private", "::ffff:192.168.1.10", true, false},
+		{"IPv4-mapped public", "::ffff:8.8.8.8", false, false},
 
 		// Edge cases
 		{"Border of private range (172.15.255.255)", "172.15.255.255", false, false},
 		{"Border of private range (172.32.0.0)", "172.32.0.0", false, false},
+		{"Border of CGNAT (100.63.255.255)", "100.63.255.255", false, false},
+		{"Border of CGNAT (100.128.0.0)", "100.128.0.0", false, false},
+		{"Border of ULA (fe00::1)", "fe00::1", false, false},
+		{"Border of link-local (fec0::1)", "fec0::1", false, false},
+		{"Multicast IPv6", "ff02::1", false, false},
 		{"Broadcast IPv4", "255.255.255.255", false, false},
 		{"Unspecified IPv4", "0.0.0.0", false, false},
 		{"Unspecified IPv6", "::", false, false},
@@ -69,3 +83,22 @@ func TestGetLocalIP(t *testing.T) {
 		t.Errorf("Invalid IP format: %s", ip)
 	}
 }
+
+func TestGetLocalIPs(t *testing.T) {
+	ips, err := GetLocalIPs()
+	if err != nil {
+		t.Fatalf("GetLocalIPs() error: %v", err)
+	}
+
+	seenIPv6 := false
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			t.Errorf("Unexpected loopback or link-local IP: %s", ip)
+		}
+		if ip.To4() == nil {
+			seenIPv6 = true
+		} else if seenIPv6 {
+			t.Errorf("Expected IPv4 before IPv6, got %v", ips)
+		}
+	}
+}
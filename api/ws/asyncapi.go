@@ -0,0 +1,175 @@
+package ws
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/danielgtaylor/huma/schema"
+
+	"github.com/undg/pulse-remote/api/buildinfo"
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// AsyncAPI describes WebSocket messages of every action in json.AvailableCommands with its payload from actions.
+// host is the server's address, fe. 192.168.1.10:8448.
+func AsyncAPI(host string, secure bool) (map[string]any, error) {
+	status, err := schema.Generate(reflect.TypeOf(pactl.Status{}))
+	if err != nil {
+		return nil, err
+	}
+	info, err := schema.Generate(reflect.TypeOf(buildinfo.BuildInfo{}))
+	if err != nil {
+		return nil, err
+	}
+	response, err := json.ResponseSchema()
+	if err != nil {
+		return nil, err
+	}
+	response.Properties["payload"] = &schema.Schema{
+		Description: "Fresh status, build info for GetBuildInfo",
+		OneOf:       []*schema.Schema{{Ref: "#/components/schemas/Status"}, {Ref: "#/components/schemas/BuildInfo"}},
+	}
+	delta, err := schema.Generate(reflect.TypeOf(pactl.StatusDelta{}))
+	if err != nil {
+		return nil, err
+	}
+	deltaEvent, err := json.ResponseSchema()
+	if err != nil {
+		return nil, err
+	}
+	deltaEvent.Properties["action"].Enum = []interface{}{json.EventStatusDelta}
+	deltaEvent.Properties["type"].Enum = []interface{}{string(json.TypeEvent)}
+	deltaEvent.Properties["payload"] = &schema.Schema{Ref: "#/components/schemas/StatusDelta"}
+
+	messages := map[string]any{
+		"Response": map[string]any{
+			"name":    "Response",
+			"summary": "Reply to action with its id and fresh status, or event. Status is sent as GetStatus event on connect and after every change.",
+			"payload": response,
+		},
+		json.EventStatusDelta: map[string]any{
+			"name":    json.EventStatusDelta,
+			"summary": "Change of status since previous seq, sent instead of full status to clients connected with ?updates=delta",
+			"payload": deltaEvent,
+		},
+	}
+	actions := make([]map[string]string, 0, len(json.AvailableCommands))
+
+	for _, action := range json.AvailableCommands {
+		payload, err := payloadSchema(action)
+		if err != nil {
+			return nil, err
+		}
+
+		message := &schema.Schema{
+			Type: "object",
+			Properties: map[string]*schema.Schema{
+				"action": {Type: "string", Enum: []interface{}{string(action)}},
+				"id":     json.IDSchema("Optional request ID, string or number, echoed in reply to this message"),
+			},
+			Required:             []string{"action"},
+			AdditionalProperties: false,
+		}
+		if payload != nil {
+			message.Properties["payload"] = payload
+			message.Required = append(message.Required, "payload")
+		}
+
+		summary, ok := wsOnlyActions[action]
+		if route, isREST := restRouteOf(action); isREST {
+			summary, ok = route.summary, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("action %s has no REST route", action)
+		}
+		messages[string(action)] = map[string]any{
+			"name":    string(action),
+			"summary": summary,
+			"payload": message,
+		}
+		actions = append(actions, map[string]string{"$ref": "#/components/messages/" + string(action)})
+	}
+
+	protocol := "ws"
+	if secure {
+		protocol = "wss"
+	}
+
+	return map[string]any{
+		"asyncapi": "2.6.0",
+		"info": map[string]any{
+			"title":       "pulse-remote",
+			"description": "Clients send actions, server responds to each one and broadcasts status after every change.",
+			"version":     buildinfo.Get().GitVersion,
+		},
+		"servers": map[string]any{
+			"pulse-remote": map[string]any{"url": host, "protocol": protocol},
+		},
+		"defaultContentType": "application/json",
+		"channels": map[string]any{
+			"/api/v1/ws": map[string]any{
+				"bindings": map[string]any{
+					"ws": map[string]any{
+						"query": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"updates": map[string]any{
+									"type":        "string",
+									"enum":        []string{"full", "delta"},
+									"default":     "full",
+									"description": "full sends whole status after every change, delta sends StatusDelta events",
+								},
+							},
+						},
+					},
+				},
+				"publish": map[string]any{
+					"operationId": "sendAction",
+					"message":     map[string]any{"oneOf": actions},
+				},
+				"subscribe": map[string]any{
+					"operationId": "receiveResponse",
+					"message": map[string]any{"oneOf": []map[string]string{
+						{"$ref": "#/components/messages/Response"},
+						{"$ref": "#/components/messages/" + json.EventStatusDelta},
+					}},
+				},
+			},
+		},
+		"components": map[string]any{
+			"messages": messages,
+			"schemas": map[string]any{
+				"Status":      status,
+				"StatusDelta": delta,
+				"BuildInfo":   info,
+			},
+		},
+	}, nil
+}
+
+func restRouteOf(action json.Action) (restRoute, bool) {
+	for _, route := range restRoutes {
+		if route.action == action {
+			return route, true
+		}
+	}
+	return restRoute{}, false
+}
+
+func serveAsyncAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := AsyncAPI(r.Host, r.TLS != nil)
+	if err != nil {
+		logger.Error().Err(err).Msg("AsyncAPI()")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := stdjson.NewEncoder(w).Encode(doc); err != nil {
+		logger.Error().Err(err).Msg("json.NewEncoder(w).Encode(doc)")
+	}
+}
@@ -1,71 +1,112 @@
 package ws
 
 import (
+	"context"
 	"reflect"
 	"time"
 
-	"github.com/undg/pulse-remote/api/json"
 	"github.com/undg/pulse-remote/api/logger"
 	"github.com/undg/pulse-remote/api/pactl"
 )
 
-var prevRes json.Response
+const (
+	// Wait for burst of pactl events to settle down before reading status
+	debounceDelay = 50 * time.Millisecond
+	// Continuous stream of events (fe. slider drag) still gets update at least this often
+	debounceMaxWait = 250 * time.Millisecond
+	// Safety net for anything that subscribe could miss, see Server.PollInterval
+	defaultPollInterval = 5 * time.Second
+)
 
-const writeWait = 10 * time.Second
+// BroadcastUpdates sends Status to every client after pactl reports a change.
+// Bursts of events are coalesced into a single update.
+// Returns when ctx is done and subscription has stopped, so no subscribe process outlives it.
+func (s *Server) BroadcastUpdates(ctx context.Context) {
+	changed := make(chan struct{}, 1)
+	listening := make(chan struct{})
+
+	go func() {
+		defer close(listening)
+		s.backend.ListenForChanges(ctx, func(pactl.Event) {
+			select {
+			case changed <- struct{}{}:
+			default:
+				// Update already pending
+			}
+		})
+	}()
 
-func BroadcastUpdates() {
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
+	interval := s.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
 
-	for range ticker.C {
-		clientsMutex.Lock()
-		clientsCount := len(clients)
-		clientsMutex.Unlock()
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
 
-		if clientsCount == 0 {
-			logger.Debug().Msg("No clients connected. Skip VOLUME update.")
-			continue
-		}
+	debounce := time.NewTimer(debounceDelay)
+	debounce.Stop()
 
-		// Same Action and StatusSuccess if everything is OK
-		res := json.Response{
-			Action: string(json.ActionGetStatus),
-			Status: json.StatusSuccess,
-		}
+	var firstEvent time.Time
+	pending := false
 
-		res.Payload = pactl.GetStatus()
+	for {
+		select {
+		case <-changed:
+			now := time.Now()
+			if !pending {
+				pending = true
+				firstEvent = now
+			}
+
+			wait := min(debounceDelay, debounceMaxWait-now.Sub(firstEvent))
+			debounce.Reset(max(wait, 0))
+
+		case <-debounce.C:
+			pending = false
+			s.broadcastStatus()
+
+		case <-poll.C:
+			if !pending {
+				s.broadcastStatus()
+			}
 
-		equal := reflect.DeepEqual(res, prevRes)
-		if equal {
-			continue
+		case <-ctx.Done():
+			<-listening
+			return
 		}
+	}
+}
 
-		prevRes = res
+func (s *Server) broadcastStatus() {
+	s.clientsMutex.Lock()
+	clientsCount := len(s.clients)
+	s.clientsMutex.Unlock()
 
-		clientsMutex.Lock()
-		updatedClients := 0
+	if clientsCount == 0 {
+		logger.Debug().Msg("No clients connected. Skip VOLUME update.")
+		return
+	}
 
-		loggerMsg := "broadcasting volume status"
+	status := s.backend.GetStatus()
 
-		for conn := range clients {
-			conn.SetWriteDeadline(time.Now().Add(writeWait))
-			err := safeWriteJSON(conn, res)
-			if err != nil {
-				logger.Error().Err(err).Msg(loggerMsg)
-				conn.Close()
-				delete(clients, conn)
-			} else {
-				updatedClients++
-			}
-		}
-		clientsMutex.Unlock()
+	// Subscribe events don't say what exactly changed, some of them don't touch Status
+	equal := reflect.DeepEqual(status, s.prevStatus)
+	if equal {
+		return
+	}
 
-		if res.Error != "" {
-			logger.Error().Str("Action", res.Action).Int("Status", int(res.Status)).Str("Error", string(res.Error)).Int("updated_clients", updatedClients).Msg(loggerMsg)
-		}
+	s.prevStatus = status
 
-		logger.Info().Str("Action", res.Action).Int("Status", int(res.Status)).Int("updated_clients", updatedClients).Msg(loggerMsg)
-		logger.Debug().Str("res.Payload", "DEBUG=TRACE to see Payload").Msg(loggerMsg)
-		logger.Trace().Interface("full_res", res).Msg(loggerMsg)
+	// Writers of clients send it, broadcast doesn't wait for anyone
+	s.clientsMutex.Lock()
+	updatedClients := 0
+	for _, c := range s.clients {
+		if c.setStatus(status) {
+			updatedClients++
+		}
 	}
+	s.clientsMutex.Unlock()
+
+	logger.Info().Int("updated_clients", updatedClients).Msg("broadcasting volume status")
 }
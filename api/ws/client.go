@@ -0,0 +1,223 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+const (
+	// Defaults of Server.PingInterval, PongTimeout and WriteTimeout
+	defaultPingInterval = 20 * time.Second
+	defaultPongTimeout  = 45 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+	// Replies waiting for slow client, it's dropped when they don't fit
+	sendQueueSize = 16
+	// Text of CloseGoingAway frame clients get from Server.Shutdown
+	shutdownReason = "server shutting down"
+)
+
+// client is connected WebSocket client and what it asked for. Only its writer goroutine writes to conn,
+// so slow client doesn't hold anyone else.
+type client struct {
+	conn      *websocket.Conn
+	connected time.Time
+
+	// Why connection was closed, set once by close
+	reason    string
+	closeOnce sync.Once
+
+	// Replies and initial status, in order
+	queue chan json.Response
+	// Latest status is pending in status, woken writer sends it
+	wake chan struct{}
+	// Closed on disconnect, stops writer
+	done chan struct{}
+	// Closed by Server.Shutdown, writer flushes queue and says goodbye
+	bye chan struct{}
+
+	// Fields below are guarded by Server.clientsMutex
+
+	// StatusDelta events instead of full status, ?updates=delta
+	deltas bool
+	// Subscribed topics, nil for everything. See json.ValidTopic.
+	topics map[string]bool
+	// Version of status client has, grows with every event sent to it. Starts at 1, so it's never omitted.
+	seq uint64
+	// Status client has from last event, base of next delta
+	sent pactl.Status
+	// Newer status not sent yet, nil when client is up to date. Newer one replaces it, client gets only the latest.
+	status *pactl.Status
+}
+
+func newClient(conn *websocket.Conn, deltas bool, status pactl.Status) *client {
+	return &client{
+		conn:      conn,
+		connected: time.Now(),
+		queue:     make(chan json.Response, sendQueueSize),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		bye:       make(chan struct{}),
+		deltas:    deltas,
+		seq:       1,
+		sent:      status,
+	}
+}
+
+// send queues reply. Client with full queue doesn't read fast enough and is dropped.
+func (s *Server) send(c *client, res json.Response) {
+	select {
+	case c.queue <- res:
+	default:
+		c.close(fmt.Sprintf("slow client, %d replies waiting", sendQueueSize))
+	}
+}
+
+// close drops connection, first reason wins. Reader fails on closed connection and cleans up.
+func (c *client) close(reason string) {
+	c.closeOnce.Do(func() {
+		c.reason = reason
+		c.conn.Close()
+	})
+}
+
+// closeReason describes why reading from client failed
+func closeReason(err error, pongTimeout time.Duration) string {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return fmt.Sprintf("client closed with %d %s", closeErr.Code, closeErr.Text)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Sprintf("no pong or message in %s", pongTimeout)
+	}
+	return "read failed: " + err.Error()
+}
+
+// setStatus makes status pending for client, replacing older one not sent yet.
+// False when nothing client is subscribed to has changed. Called with clientsMutex held.
+func (c *client) setStatus(status pactl.Status) bool {
+	view := filterStatus(status, c.topics)
+
+	latest := c.sent
+	if c.status != nil {
+		latest = *c.status
+	}
+	if reflect.DeepEqual(latest, view) {
+		return false
+	}
+
+	c.status = &view
+	select {
+	case c.wake <- struct{}{}:
+	default:
+		// Writer already woken
+	}
+	return true
+}
+
+// statusEvent takes pending status as full status or StatusDelta, false when there is none
+func (s *Server) statusEvent(c *client) (json.Response, bool) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if c.status == nil {
+		return json.Response{}, false
+	}
+	next := *c.status
+	c.status = nil
+	c.seq++
+
+	// Same Action and StatusSuccess if everything is OK
+	res := json.Response{
+		Type:    json.TypeEvent,
+		Action:  string(json.ActionGetStatus),
+		Status:  json.StatusSuccess,
+		Payload: next,
+		Seq:     c.seq,
+	}
+	if c.deltas {
+		res.Action = json.EventStatusDelta
+		res.Payload = pactl.Diff(c.sent, next)
+	}
+	c.sent = next
+	return res, true
+}
+
+// writer sends queued replies first, then pending status, and pings, until client disconnects, write fails
+// or server shuts down
+func (s *Server) writer(c *client) {
+	writeTimeout := orDefault(s.WriteTimeout, defaultWriteTimeout)
+	ping := time.NewTicker(orDefault(s.PingInterval, defaultPingInterval))
+	defer ping.Stop()
+
+	write := func(res json.Response) bool {
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := c.conn.WriteJSON(res); err != nil {
+			c.close("write failed: " + err.Error())
+			return false
+		}
+		logger.Trace().Interface("full_res", res).Msg("Sent to client")
+		return true
+	}
+
+	for {
+		var res json.Response
+		select {
+		case res = <-c.queue:
+		default:
+			select {
+			case res = <-c.queue:
+			case <-c.wake:
+				var ok bool
+				if res, ok = s.statusEvent(c); !ok {
+					continue
+				}
+			case <-ping.C:
+				if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+					c.close("ping failed: " + err.Error())
+					return
+				}
+				continue
+			case <-c.bye:
+				// Replies already queued go out before close frame, pending status is dropped
+				for len(c.queue) > 0 {
+					if !write(<-c.queue) {
+						return
+					}
+				}
+				closeGoingAway(c.conn, writeTimeout)
+				c.close(shutdownReason)
+				return
+			case <-c.done:
+				return
+			}
+		}
+
+		if !write(res) {
+			return
+		}
+	}
+}
+
+// closeGoingAway tells client that server is shutting down, so it reconnects instead of reporting an error
+func closeGoingAway(conn *websocket.Conn, timeout time.Duration) {
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, shutdownReason)
+	conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(timeout))
+}
+
+func orDefault(d time.Duration, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/undg/pulse-remote/api/backend/fake"
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+func TestClientDropsToLatest(t *testing.T) {
+	audio := fake.New()
+	defer audio.Close()
+	s := NewServer(audio)
+
+	sinks := func(volumes ...int) pactl.Status {
+		var status pactl.Status
+		for i, v := range volumes {
+			status.Sinks = append(status.Sinks, pactl.Sink{ID: 55 + i, Volume: v})
+		}
+		return status
+	}
+
+	for _, deltas := range []bool{false, true} {
+		c := newClient(nil, deltas, sinks(30, 50))
+
+		if c.setStatus(sinks(30, 50)) {
+			t.Errorf("[Err] Expected no pending status without change")
+		}
+		c.setStatus(sinks(40, 50))
+		c.setStatus(sinks(45, 50))
+
+		res, ok := s.statusEvent(c)
+		if !ok || res.Seq != 2 {
+			t.Fatalf("[Err] Expected one event with seq 2, got %+v %v", res, ok)
+		}
+		if deltas {
+			delta := res.Payload.(pactl.StatusDelta)
+			if res.Action != json.EventStatusDelta || len(delta.Sinks) != 1 || delta.Sinks[0].Volume != 45 {
+				t.Errorf("[Err] Expected delta with latest volume of changed sink only, got %+v", res)
+			}
+		} else if status := res.Payload.(pactl.Status); status.Sinks[0].Volume != 45 {
+			t.Errorf("[Err] Expected latest status, got %+v", status)
+		}
+
+		if _, ok := s.statusEvent(c); ok {
+			t.Errorf("[Err] Expected nothing pending after event")
+		}
+	}
+}
+
+func TestClientSlowEvicted(t *testing.T) {
+	_, s, conn := startTestServer(t)
+
+	// No writer takes from queue, as if client's network stalled
+	c := newClient(conn, false, pactl.Status{})
+	for i := 0; i <= sendQueueSize; i++ {
+		s.send(c, json.Response{Action: string(json.ActionGetStatus)})
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("{}")); err == nil {
+		t.Errorf("[Err] Expected connection of slow client closed")
+	}
+}
+
+func TestClientKeepalive(t *testing.T) {
+	audio := fake.New()
+	s := NewServer(audio)
+	s.PingInterval = 20 * time.Millisecond
+	s.PongTimeout = 100 * time.Millisecond
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/ws", s.HandleWebSocket)
+	mux.Handle("/api/", s.RESTHandler())
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		audio.Close()
+		srv.Close()
+	})
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/ws", nil)
+		if err != nil {
+			t.Fatalf("[Err] Dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	connected := func() int {
+		s.clientsMutex.Lock()
+		defer s.clientsMutex.Unlock()
+		return len(s.clients)
+	}
+
+	// Reading client answers pings, the other one is asleep
+	alive := dial()
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	dial()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for connected() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("[Err] Expected sleeping client dropped, %d connected", connected())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(3 * s.PongTimeout)
+	if connected() != 1 {
+		t.Errorf("[Err] Expected client answering pings to stay, %d connected", connected())
+	}
+
+	res, err := http.Get(srv.URL + "/api/v1/metrics")
+	if err != nil {
+		t.Fatalf("[Err] GET metrics: %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	for _, want := range []string{"pulse_remote_ws_connections 1", "pulse_remote_ws_connection_duration_seconds_count 1", `_bucket{le="1"} 1`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("[Err] Expected %q in metrics, got\n%s", want, body)
+		}
+	}
+}
@@ -3,9 +3,13 @@ package ws
 import (
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"slices"
 	"strings"
 
 	"github.com/gorilla/websocket"
+	"github.com/undg/pulse-remote/api/config"
 	"github.com/undg/pulse-remote/api/logger"
 	"github.com/undg/pulse-remote/api/utils"
 )
@@ -15,28 +19,89 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 }
 
-func upgraderCheckOrigin() {
-	msgPrefix := "ERROR [upgraderCheckOrigin()]: "
-	upgrader.CheckOrigin = func(r *http.Request) bool {
-		host, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			logger.Error().Err(err).Msg("SplitHostPort")
-			return false
-		}
+// upgrader with origin policy of the server
+func (s *Server) upgrader() *websocket.Upgrader {
+	u := upgrader
+	u.CheckOrigin = s.checkOrigin
+	return &u
+}
 
-		ip := net.ParseIP(host)
-		if ip == nil {
-			logger.Error().Err(err).Msg("ParseIP")
-			return false
-		}
+// checkOrigin lets in clients from local networks, opening WebSocket from the server's own page or AllowedOrigins.
+// Browsers send Origin with every handshake, without this check any website open in a browser on LAN
+// could drive the mixer. Clients without Origin are not browsers, fe. scripts, they are let in.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !utils.IsLocalIP(ip) {
+		logger.Warn().Str("client_ip", r.RemoteAddr).Msg("Client outside of local networks")
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || originAllowed(origin, r.Host, s.AllowedOrigins, isOwnHost) {
+		return true
+	}
+
+	logger.Warn().Str("client_ip", r.RemoteAddr).Str("origin", origin).Str("host", r.Host).Msg("Origin not allowed")
+	return false
+}
+
+// originAllowed is true for allowed origins, and for the same origin as requested host when that host is the server itself.
+// Checking own host stops DNS rebinding, fe. evil.example resolving to the server's IP has the same origin as its host.
+// allowed are normalized by config.ParseOrigin.
+func originAllowed(origin string, host string, allowed []string, isOwnHost func(hostname string) bool) bool {
+	normalized, err := config.ParseOrigin(origin)
+	if err != nil {
+		return false
+	}
+	if slices.Contains(allowed, normalized) {
+		return true
+	}
 
-		if utils.IsLocalIP(ip) || strings.HasPrefix(r.Host, "localhost") {
-			logger.Info().Str("IP", string(ip)).Str("Host", r.Host).Msgf("ParseIP %s", msgPrefix)
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return false
+	}
+	// Requested host normalized the same way, browsers leave out default port in both
+	requested, err := config.ParseOrigin(u.Scheme + "://" + host)
+	return err == nil && requested == normalized && isOwnHost(u.Hostname())
+}
+
+// isOwnHost is true for names and addresses of this machine: localhost, hostname, hostname.local,
+// loopback and interface addresses.
+func isOwnHost(hostname string) bool {
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	if hostname == "localhost" {
+		return true
+	}
+
+	if name, err := os.Hostname(); err == nil && name != "" {
+		name = strings.ToLower(name)
+		if hostname == name || hostname == name+".local" {
 			return true
-		} else {
-			logger.Error().Str("IP", string(ip)).Str("Host", r.Host).Msgf("ParseIP %s", msgPrefix)
-			return false
 		}
+	}
 
+	ip := net.ParseIP(hostname)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		logger.Error().Err(err).Msg("net.InterfaceAddrs()")
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,121 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/undg/pulse-remote/api/backend/fake"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	ownHost := func(hostname string) bool {
+		return hostname == "localhost" || hostname == "192.168.1.10" || hostname == "fd00::10"
+	}
+	allowed := []string{"https://audio.example.com", "http://192.168.1.10:5173"}
+
+	tests := []struct {
+		name   string
+		origin string
+		host   string
+		want   bool
+	}{
+		{"SameOrigin", "http://192.168.1.10:8448", "192.168.1.10:8448", true},
+		{"SameOriginLocalhost", "http://localhost:8448", "localhost:8448", true},
+		{"SameOriginIPv6", "http://[fd00::10]:8448", "[fd00::10]:8448", true},
+		{"SameOriginHTTPSDefaultPort", "https://localhost", "localhost:443", true},
+		{"SameOriginUpperCase", "http://LOCALHOST:8448", "localhost:8448", true},
+		{"Allowed", "https://audio.example.com", "192.168.1.10:8448", true},
+		{"AllowedDevServer", "http://192.168.1.10:5173", "192.168.1.10:8448", true},
+
+		{"CrossSite", "https://evil.example", "192.168.1.10:8448", false},
+		{"OtherPort", "http://192.168.1.10:3000", "192.168.1.10:8448", false},
+		{"AllowedWrongScheme", "http://audio.example.com", "192.168.1.10:8448", false},
+		{"DNSRebinding", "http://evil.example:8448", "evil.example:8448", false},
+		{"NullOrigin", "null", "192.168.1.10:8448", false},
+		{"FileOrigin", "file://", "192.168.1.10:8448", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := originAllowed(tt.origin, tt.host, allowed, ownHost); got != tt.want {
+				t.Errorf("[Err] originAllowed(%q, %q) = %v, want %v", tt.origin, tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOwnHost(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     bool
+	}{
+		{"localhost", true},
+		{"LocalHost", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"evil.example", false},
+		{"8.8.8.8", false},
+	}
+
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		tests = append(tests, struct {
+			hostname string
+			want     bool
+		}{hostname + ".local", true})
+	}
+
+	for _, tt := range tests {
+		if got := isOwnHost(tt.hostname); got != tt.want {
+			t.Errorf("[Err] isOwnHost(%q) = %v, want %v", tt.hostname, got, tt.want)
+		}
+	}
+}
+
+func TestHandleWebSocketOrigin(t *testing.T) {
+	audio := fake.New()
+	s := NewServer(audio)
+	s.AllowedOrigins = []string{"https://audio.example.com"}
+
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	t.Cleanup(func() {
+		audio.Close()
+		srv.Close()
+	})
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"NoOrigin", "", http.StatusSwitchingProtocols},
+		{"SameOrigin", srv.URL, http.StatusSwitchingProtocols},
+		{"Allowed", "https://audio.example.com", http.StatusSwitchingProtocols},
+		{"CrossSite", "https://evil.example", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+
+			conn, res, err := websocket.DefaultDialer.Dial(url, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if res == nil {
+				t.Fatalf("[Err] Dial without response: %v", err)
+			}
+			if res.StatusCode != tt.want {
+				t.Errorf("[Err] Expected HTTP %d, got %d", tt.want, res.StatusCode)
+			}
+		})
+	}
+}
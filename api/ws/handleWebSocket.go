@@ -1,128 +1,295 @@
 package ws
 
 import (
+	"context"
+	stdjson "encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/undg/pulse-remote/api/auth"
+	"github.com/undg/pulse-remote/api/backend"
 	"github.com/undg/pulse-remote/api/json"
 	"github.com/undg/pulse-remote/api/logger"
 	"github.com/undg/pulse-remote/api/pactl"
 	"github.com/undg/pulse-remote/api/utils"
 )
 
-var clients = make(map[*websocket.Conn]bool)
-var clientsMutex = &sync.Mutex{}
+// CloseUnauthorized is close code for clients without valid token, connection is closed right after upgrade.
+// Browsers hide HTTP status of failed upgrade, close code they can read.
+const CloseUnauthorized = 4401
 
-func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	logger.Info().Str("server_ip", r.Host).Str("client_ip", r.RemoteAddr).Msg("New client attempting to connect")
+// Server keeps connected WebSocket clients and serves them with audio backend of choice.
+type Server struct {
+	backend backend.AudioBackend
+
+	// Status is broadcasted at least this often, even without change events. Zero for default.
+	PollInterval time.Duration
+	// Clients are pinged this often, and dropped without pong or message for PongTimeout. Zero for default.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	// Client that can't take a frame this long is dropped. Zero for default.
+	WriteTimeout time.Duration
+	// Authorize checks client before it gets any data, and again before every action,
+	// so revoked token or changed role works right away. Nil lets everyone do everything.
+	Authorize func(r *http.Request) (auth.Role, bool)
+	// Websites allowed to connect besides the server's own page, normalized by config.ParseOrigin
+	AllowedOrigins []string
+
+	// Clients and their fields are guarded by clientsMutex
+	clients      map[*websocket.Conn]*client
+	clientsMutex sync.Mutex
+	// Set by Shutdown, new clients are turned away. Guarded by clientsMutex.
+	closing bool
+	// Running HandleWebSocket of registered clients, Shutdown waits for their in-flight actions
+	handlers sync.WaitGroup
+
+	// Last broadcasted status, only touched by BroadcastUpdates()
+	prevStatus pactl.Status
+
+	lifetimes lifetimes
+}
+
+func NewServer(b backend.AudioBackend) *Server {
+	return &Server{
+		backend: b,
+		clients: make(map[*websocket.Conn]*client),
+	}
+}
+
+// Shutdown sends CloseGoingAway to every client, after replies already queued for it, and turns new clients away.
+// Waits until actions in flight are done, or ctx is done. Connections are hijacked, http.Server.Shutdown doesn't see them.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.clientsMutex.Lock()
+	if !s.closing {
+		s.closing = true
+		for _, c := range s.clients {
+			close(c.bye)
+		}
+	}
+	clientCount := len(s.clients)
+	s.clientsMutex.Unlock()
+
+	logger.Info().Int("clients_count", clientCount).Msg("Closing WebSocket connections")
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	upgraderCheckOrigin()
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	logger.Info().Str("server_ip", r.Host).Str("client_ip", r.RemoteAddr).Msg("New client attempting to connect")
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := s.upgrader().Upgrade(w, r, nil)
 	if err != nil {
 		logger.Error().Err(err).Msg("Upgrading Websocket unsuccessful")
 		return
 	}
 
-	clientsMutex.Lock()
-	clients[conn] = true
-	clientCount := len(clients)
-	clientsMutex.Unlock()
-
-	logger.Info().Int("clients_connected", clientCount).Msg("Client connection established")
+	role, ok := s.role(r)
+	if !ok {
+		closeUnauthorized(conn, r)
+		conn.Close()
+		return
+	}
 
 	// Execute ActionGetStatus when a new client connects
-	status := pactl.GetStatus()
+	status := s.backend.GetStatus()
+	c := newClient(conn, r.URL.Query().Get("updates") == "delta", status)
 
 	initialResponse := json.Response{
+		Type:    json.TypeEvent,
 		Action:  string(json.ActionGetStatus),
 		Status:  json.StatusSuccess,
 		Payload: status,
+		Seq:     c.seq,
+	}
+	// Goes out before any broadcast, writer sends queue first
+	s.send(c, initialResponse)
+
+	s.clientsMutex.Lock()
+	closing := s.closing
+	if !closing {
+		s.clients[conn] = c
+		s.handlers.Add(1)
 	}
+	clientCount := len(s.clients)
+	s.clientsMutex.Unlock()
 
-	if err := safeWriteJSON(conn, initialResponse); err != nil {
-		logger.Error().Err(err).Msg("Initial sinks data FAIL")
+	if closing {
+		closeGoingAway(conn, orDefault(s.WriteTimeout, defaultWriteTimeout))
+		conn.Close()
+		return
 	}
 
+	go s.writer(c)
+
+	logger.Info().Int("clients_connected", clientCount).Msg("Client connection established")
+
 	// Cleanup after client is disconnected
 	defer func() {
-		clientsMutex.Lock()
-		delete(clients, conn)
-		clientCounts := len(clients)
-		clientsMutex.Unlock()
-		conn.Close()
-		logger.Info().Int("clients_count", clientCounts).Msg("Client disconnected")
+		// Logged before Shutdown stops waiting
+		defer s.handlers.Done()
+
+		s.clientsMutex.Lock()
+		delete(s.clients, conn)
+		clientCounts := len(s.clients)
+		s.clientsMutex.Unlock()
+		close(c.done)
+
+		lifetime := time.Since(c.connected)
+		s.lifetimes.observe(lifetime)
+		logger.Info().Str("client_ip", r.RemoteAddr).Str("user_agent", r.UserAgent()).Stringer("role", role).
+			Str("reason", c.reason).Dur("lifetime", lifetime).Int("clients_count", clientCounts).Msg("Client disconnected")
 	}()
 
+	// Pong or any message proves client is alive, half-open connections of sleeping phones time out
+	pongTimeout := orDefault(s.PongTimeout, defaultPongTimeout)
+	conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
 	// Messaging system with client
 	for {
-		var msg json.Message
-		err := conn.ReadJSON(&msg)
+		_, data, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				logger.Error().Err(err).Msg("Can't read JSON")
-			}
+			c.close(closeReason(err, pongTimeout))
 			break
 		}
+		conn.SetReadDeadline(time.Now().Add(pongTimeout))
 
-		// Same Action and StatusSuccess if everyting is OK
-		res := json.Response{
-			Action: string(msg.Action),
-			Status: json.StatusSuccess,
+		// Malformed message gets error reply, connection stays
+		var msg json.Message
+		if err := stdjson.Unmarshal(data, &msg); err != nil {
+			s.send(c, invalidMessage(data, err))
+			continue
+		}
+		if err := json.ValidID(msg.ID); err != nil {
+			logger.Warn().Err(err).Str("action", string(msg.Action)).Msg("Invalid message ID")
+			s.send(c, json.Response{Type: json.TypeReply, Action: string(msg.Action), Status: json.StatusPayloadError, Error: err.Error()})
+			continue
 		}
 
-		switch msg.Action {
-
-		case json.ActionGetStatus:
-			status := pactl.GetStatus()
-			res.Payload = status
-
-		// SINKS, Speakers
-		case json.ActionSetSinkVolume:
-			handleSetSinkVolume(&msg, &res)
-		case json.ActionSetSinkMuted:
-			handleSetSinkMuted(&msg, &res)
-		case json.ActionSetDefaultSink:
-			handleSetDefaultSink(&msg, &res)
-
-		// App's under SiNKS
-		case json.ActionSetSinkInputVolume:
-			handleSetSinkInputVolume(&msg, &res)
-		case json.ActionSetSinkInputMuted:
-			handleSetSinkInputMuted(&msg, &res)
-		case json.ActionMoveSinkInput:
-			handleMoveSinkInput(&msg, &res)
-
-		// SOURCES, Microphones
-		case json.ActionSetSourceVolume:
-			handleSetSourceVolume(&msg, &res)
-		case json.ActionSetSourceMuted:
-			handleSetSourceMuted(&msg, &res)
-		case json.ActionSetDefaultSource:
-			handleSetDefaultSource(&msg, &res)
-
-		// App's under SOURCES
-		case json.ActionSetSourceInputVolume:
-			handleSetSourceInputVolume(&msg, &res)
-		case json.ActionSetSourceInputMuted:
-			handleSetSourceInputMuted(&msg, &res)
-
-		case json.ActionMoveSourceOutput:
-			handleMoveSourceOutput(&msg, &res)
-
-		default:
-			res.Error = "Command not found. Available actions: " + strings.Join(utils.ActionsToStrings(json.AvailableCommands), " ")
-			res.Status = json.StatusActionError
+		role, ok = s.role(r)
+		if !ok {
+			closeUnauthorized(conn, r)
+			c.close("unauthorized")
+			break
 		}
 
-		handleServerLog(&msg, &res)
+		seq := s.clientSeq(c)
+		res, _ := s.run(&msg, role, r, c)
+		if status, ok := res.Payload.(pactl.Status); ok {
+			res.Payload = s.clientStatus(c, status)
+			res.Seq = seq
+		}
 
-		if err := safeWriteJSON(conn, res); err != nil {
-			logger.Error().Err(err).Msg("Can't write JSON")
-			break
+		s.send(c, res)
+	}
+}
+
+// invalidMessage is reply to message that isn't valid JSON or has field of wrong type, with its id when it can be read
+func invalidMessage(data []byte, err error) json.Response {
+	var envelope struct {
+		ID stdjson.RawMessage `json:"id"`
+	}
+	stdjson.Unmarshal(data, &envelope)
+	if json.ValidID(envelope.ID) != nil {
+		envelope.ID = nil
+	}
+
+	logger.Warn().Err(err).Msg("Invalid JSON message")
+	return json.Response{
+		Type:   json.TypeReply,
+		ID:     envelope.ID,
+		Status: json.StatusErrorInvalidJSON,
+		Error:  "Invalid JSON message: " + err.Error(),
+	}
+}
+
+func (s *Server) role(r *http.Request) (auth.Role, bool) {
+	if s.Authorize == nil {
+		return auth.RoleAdmin, true
+	}
+	return s.Authorize(r)
+}
+
+func closeUnauthorized(conn *websocket.Conn, r *http.Request) {
+	logger.Warn().Str("client_ip", r.RemoteAddr).Msg("Unauthorized client, closing connection")
+	closeMsg := websocket.FormatCloseMessage(CloseUnauthorized, "Unauthorized")
+	conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+}
+
+// run checks client's role and runs action, the same way for WebSocket and REST. c is nil for REST.
+// Returned error is from the backend, its description is already in response.
+func (s *Server) run(msg *json.Message, role auth.Role, r *http.Request, c *client) (json.Response, error) {
+	// Same Action and StatusSuccess if everyting is OK
+	res := json.Response{
+		Type:   json.TypeReply,
+		ID:     msg.ID,
+		Action: string(msg.Action),
+		Status: json.StatusSuccess,
+	}
+
+	var err error
+	if slices.Contains(json.AvailableCommands, msg.Action) && !role.Allows(msg.Action) {
+		res.Status = json.StatusUnauthorized
+		res.Error = fmt.Sprintf("Action %s needs %s role, client is %s", msg.Action, auth.RequiredRole(msg.Action), role)
+		res.Payload = s.backend.GetStatus()
+		logger.Warn().Str("client_ip", r.RemoteAddr).Str("action", res.Action).Stringer("role", role).Msg("Action not allowed")
+	} else {
+		err = s.handleMessage(msg, &res, c)
+	}
+
+	handleServerLog(msg, &res)
+	return res, err
+}
+
+// handleMessage runs action from client with its handler from actions, result goes to res
+func (s *Server) handleMessage(msg *json.Message, res *json.Response, c *client) error {
+	h, ok := actions[msg.Action]
+	if !ok {
+		res.Error = "Command not found. Available actions: " + strings.Join(utils.ActionsToStrings(json.AvailableCommands), " ")
+		res.Status = json.StatusActionError
+		return nil
+	}
+
+	if h.query != nil {
+		res.Payload = h.query(s.backend)
+		return nil
+	}
+
+	p, err := h.decode(msg.Payload)
+	if err != nil {
+		res.Error = err.Error()
+		res.Status = json.StatusPayloadError
+		logger.Error().Str("action", res.Action).Msg(res.Error)
+		return nil
+	}
+
+	if h.client != nil {
+		if c == nil {
+			res.Error = fmt.Sprintf("Action %s is only available over WebSocket", msg.Action)
+			res.Status = json.StatusActionError
+			return nil
 		}
+		return s.respond(res, h.client(s, c, p))
 	}
+
+	return s.respond(res, h.run(s.backend, p))
 }
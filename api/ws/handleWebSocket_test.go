@@ -0,0 +1,561 @@
+package ws
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/undg/pulse-remote/api/auth"
+	"github.com/undg/pulse-remote/api/backend/fake"
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+type statusResponse struct {
+	Type    string             `json:"type"`
+	ID      stdjson.RawMessage `json:"id"`
+	Action  string             `json:"action"`
+	Status  int16              `json:"status"`
+	Payload pactl.Status       `json:"payload"`
+	Error   string             `json:"error"`
+	Seq     uint64             `json:"seq"`
+}
+
+func startTestServer(t *testing.T) (*fake.Backend, *Server, *websocket.Conn) {
+	t.Helper()
+
+	audio := fake.New()
+	s := NewServer(audio)
+
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	t.Cleanup(func() {
+		audio.Close()
+		srv.Close()
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("[Err] Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return audio, s, conn
+}
+
+func readStatus(t *testing.T, conn *websocket.Conn) statusResponse {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var res statusResponse
+	if err := conn.ReadJSON(&res); err != nil {
+		t.Fatalf("[Err] ReadJSON: %v", err)
+	}
+	return res
+}
+
+func send(t *testing.T, conn *websocket.Conn, action json.Action, payload any) statusResponse {
+	t.Helper()
+
+	if err := conn.WriteJSON(json.Message{Action: action, Payload: payload}); err != nil {
+		t.Fatalf("[Err] WriteJSON: %v", err)
+	}
+	return readStatus(t, conn)
+}
+
+func TestHandleWebSocket(t *testing.T) {
+	audio, _, conn := startTestServer(t)
+
+	t.Run("InitialStatus", func(t *testing.T) {
+		res := readStatus(t, conn)
+		if res.Action != string(json.ActionGetStatus) || res.Status != json.StatusSuccess || res.Type != string(json.TypeEvent) {
+			t.Fatalf("[Err] Unexpected initial response %+v", res)
+		}
+		if len(res.Payload.Sinks) != 2 || len(res.Payload.Sources) != 2 {
+			t.Errorf("[Err] Expected fake status, got %+v", res.Payload)
+		}
+	})
+
+	t.Run("SetSinkVolume", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetSinkVolume, map[string]any{"name": "alsa_output.speakers", "volume": 30})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		if res.Payload.Sinks[0].Volume != 30 {
+			t.Errorf("[Err] Expected volume 30 in response, got %d", res.Payload.Sinks[0].Volume)
+		}
+
+		calls := audio.Calls()
+		if len(calls) != 1 || calls[0].String() != "SetSinkVolume[alsa_output.speakers 30.00]" {
+			t.Errorf("[Err] Unexpected backend calls %v", calls)
+		}
+	})
+
+	t.Run("ChangeSinkVolume", func(t *testing.T) {
+		res := send(t, conn, json.ActionChangeSinkVolume, map[string]any{"name": "alsa_output.speakers", "delta": -5})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		if res.Payload.Sinks[0].Volume != 25 {
+			t.Errorf("[Err] Expected volume 25 after -5, got %d", res.Payload.Sinks[0].Volume)
+		}
+
+		calls := audio.Calls()
+		if last := calls[len(calls)-1].String(); last != "ChangeSinkVolume[alsa_output.speakers -5.00]" {
+			t.Errorf("[Err] Unexpected backend call %s", last)
+		}
+
+		res = send(t, conn, json.ActionChangeSinkVolume, map[string]any{"name": "alsa_output.speakers", "delta": 500})
+		if res.Payload.Sinks[0].Volume != int(pactl.MaxVolume) {
+			t.Errorf("[Err] Expected volume capped at %v, got %d", pactl.MaxVolume, res.Payload.Sinks[0].Volume)
+		}
+
+		send(t, conn, json.ActionSetSinkVolume, map[string]any{"name": "alsa_output.speakers", "volume": 30})
+	})
+
+	t.Run("ChangeSinkInputVolume", func(t *testing.T) {
+		res := send(t, conn, json.ActionChangeSinkInputVolume, map[string]any{"id": 91, "delta": -10})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		if res.Payload.SinkInputs[0].Volume != 90 {
+			t.Errorf("[Err] Expected sink input volume 90, got %d", res.Payload.SinkInputs[0].Volume)
+		}
+	})
+
+	t.Run("ChangeSourceVolume", func(t *testing.T) {
+		res := send(t, conn, json.ActionChangeSourceVolume, map[string]any{"name": "alsa_input.mic", "delta": 5})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		if res.Payload.Sources[1].Volume != 75 {
+			t.Errorf("[Err] Expected source volume 75, got %d", res.Payload.Sources[1].Volume)
+		}
+	})
+
+	t.Run("SetSinkBalance", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetSinkBalance, map[string]any{"name": "alsa_output.speakers", "balance": 0.5})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		sink := res.Payload.Sinks[0]
+		if sink.Balance != 0.5 || sink.Volume != 30 {
+			t.Errorf("[Err] Expected balance 0.5 with volume 30, got %v %d", sink.Balance, sink.Volume)
+		}
+	})
+
+	t.Run("SetSinkVolumeKeepsBalance", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetSinkVolume, map[string]any{"name": "alsa_output.speakers", "volume": 60})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		sink := res.Payload.Sinks[0]
+		if sink.Balance != 0.5 || sink.Volume != 60 {
+			t.Errorf("[Err] Expected balance 0.5 with volume 60, got %v %d", sink.Balance, sink.Volume)
+		}
+	})
+
+	t.Run("SetSinkChannelVolumes", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetSinkChannelVolumes, map[string]any{"name": "alsa_output.speakers", "volumes": []int{20, 40}})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		sink := res.Payload.Sinks[0]
+		if sink.Channels[0].Volume != 20 || sink.Channels[1].Volume != 40 || sink.Volume != 30 {
+			t.Errorf("[Err] Expected channels 20/40 with volume 30, got %+v", sink)
+		}
+	})
+
+	t.Run("SetSinkPort", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetSinkPort, map[string]any{"name": "alsa_output.speakers", "port": "analog-output-headphones"})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		if res.Payload.Sinks[0].ActivePort != "analog-output-headphones" {
+			t.Errorf("[Err] Expected active port analog-output-headphones, got %q", res.Payload.Sinks[0].ActivePort)
+		}
+	})
+
+	t.Run("MoveSinkInput", func(t *testing.T) {
+		res := send(t, conn, json.ActionMoveSinkInput, map[string]any{"id": 91, "name": "bluez_output.headset"})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		if res.Payload.SinkInputs[0].SinkID != 56 {
+			t.Errorf("[Err] Expected sink input on sink 56, got %d", res.Payload.SinkInputs[0].SinkID)
+		}
+	})
+
+	t.Run("SetSourceInputVolume", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetSourceInputVolume, map[string]any{"id": 93, "volume": 40})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		if res.Payload.SourceOutputs[0].Volume != 40 {
+			t.Errorf("[Err] Expected source output volume 40, got %d", res.Payload.SourceOutputs[0].Volume)
+		}
+	})
+
+	t.Run("SetCardProfile", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetCardProfile, map[string]any{"name": "bluez_card.headset", "profile": "headset-head-unit"})
+		if res.Status != json.StatusSuccess {
+			t.Fatalf("[Err] Unexpected response %+v", res)
+		}
+		if res.Payload.Cards[0].ActiveProfile != "headset-head-unit" {
+			t.Errorf("[Err] Expected active profile headset-head-unit, got %q", res.Payload.Cards[0].ActiveProfile)
+		}
+	})
+
+	t.Run("RequestID", func(t *testing.T) {
+		// Echoed exactly as sent, string or number
+		for _, id := range []string{`"req-1"`, `1`, `2.50`} {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"GetStatus","id":`+id+`}`)); err != nil {
+				t.Fatalf("[Err] WriteMessage: %v", err)
+			}
+			res := readStatus(t, conn)
+			if res.Type != string(json.TypeReply) || string(res.ID) != id {
+				t.Errorf("[Err] Expected reply to %s, got %s %s", id, res.Type, res.ID)
+			}
+		}
+
+		// Other types are rejected, not echoed
+		for _, id := range []string{`{"n":1}`, `[1]`, `true`, `null`} {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"GetStatus","id":`+id+`}`)); err != nil {
+				t.Fatalf("[Err] WriteMessage: %v", err)
+			}
+			if res := readStatus(t, conn); res.Status != json.StatusPayloadError || res.ID != nil {
+				t.Errorf("[Err] Expected payload error without id for %s, got %+v", id, res)
+			}
+		}
+
+		res := send(t, conn, json.ActionSetSinkMuted, map[string]any{"name": "alsa_output.speakers"})
+		if res.Type != string(json.TypeReply) || res.ID != nil {
+			t.Errorf("[Err] Expected reply without ID, got %s %s", res.Type, res.ID)
+		}
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		// ID is echoed when it can be read
+		for msg, id := range map[string]string{`{"action":5,"id":7}`: `7`, `{"action":`: ``} {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				t.Fatalf("[Err] WriteMessage: %v", err)
+			}
+			if res := readStatus(t, conn); res.Status != json.StatusErrorInvalidJSON || res.Error == "" || string(res.ID) != id {
+				t.Errorf("[Err] Expected invalid JSON error with id %q for %s, got %+v", id, msg, res)
+			}
+		}
+
+		// Connection survives bad messages
+		if res := send(t, conn, json.ActionGetStatus, nil); res.Status != json.StatusSuccess {
+			t.Errorf("[Err] Expected status after invalid JSON, got %+v", res)
+		}
+	})
+
+	t.Run("UnknownAction", func(t *testing.T) {
+		res := send(t, conn, "DoSomething", nil)
+		if res.Status != json.StatusActionError || res.Error == "" {
+			t.Errorf("[Err] Expected action error, got %+v", res)
+		}
+	})
+
+	t.Run("InvalidPayload", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetSinkMuted, "speakers")
+		if res.Status != json.StatusPayloadError {
+			t.Errorf("[Err] Expected payload error, got %+v", res)
+		}
+	})
+
+	t.Run("InvalidPayloadField", func(t *testing.T) {
+		before := len(audio.Calls())
+
+		tests := []struct {
+			Name    string
+			Action  json.Action
+			Payload map[string]any
+		}{
+			{"MissingName", json.ActionSetDefaultSink, map[string]any{}},
+			{"VolumeNotNumber", json.ActionSetSinkVolume, map[string]any{"name": "alsa_output.speakers", "volume": "loud"}},
+			{"NegativeVolume", json.ActionSetSourceVolume, map[string]any{"name": "alsa_input.mic", "volume": -5}},
+			{"FractionalID", json.ActionSetSinkInputMuted, map[string]any{"id": 91.5, "muted": true}},
+			{"MutedNotBool", json.ActionSetSinkMuted, map[string]any{"name": "alsa_output.speakers", "muted": "yes"}},
+			{"VolumeAboveMax", json.ActionSetSinkVolume, map[string]any{"name": "alsa_output.speakers", "volume": pactl.MaxVolume + 1}},
+			{"ChannelVolumeAboveMax", json.ActionSetSinkChannelVolumes, map[string]any{"name": "alsa_output.speakers", "volumes": []float64{30, pactl.MaxVolume + 1}}},
+			{"NegativeID", json.ActionSetSinkInputVolume, map[string]any{"id": -1, "volume": 30}},
+			{"UnknownField", json.ActionSetSinkMuted, map[string]any{"name": "alsa_output.speakers", "muted": true, "loud": true}},
+			{"NullField", json.ActionSetSinkMuted, map[string]any{"name": "alsa_output.speakers", "muted": nil}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.Name, func(t *testing.T) {
+				res := send(t, conn, tt.Action, tt.Payload)
+				if res.Status != json.StatusPayloadError || res.Error == "" {
+					t.Errorf("[Err] Expected payload error, got %+v", res)
+				}
+			})
+		}
+
+		if calls := audio.Calls(); len(calls) != before {
+			t.Errorf("[Err] Invalid payloads reached backend %v", calls[before:])
+		}
+	})
+
+	t.Run("InvalidArgument", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetSinkChannelVolumes, map[string]any{"name": "alsa_output.speakers", "volumes": []int{20, 30, 40}})
+		if res.Status != json.StatusPayloadError || !strings.Contains(res.Error, "channel volumes") {
+			t.Errorf("[Err] Expected payload error about channel volumes, got %+v", res)
+		}
+	})
+
+	t.Run("NoSuchEntity", func(t *testing.T) {
+		res := send(t, conn, json.ActionSetDefaultSink, map[string]any{"name": "alsa_output.nope"})
+		if res.Status != json.StatusActionError || !strings.Contains(res.Error, "No such entity") {
+			t.Errorf("[Err] Expected action error with No such entity, got %+v", res)
+		}
+		if len(res.Payload.Sinks) != 2 {
+			t.Errorf("[Err] Expected status sent with error, got %+v", res.Payload)
+		}
+	})
+}
+
+func TestBroadcastUpdates(t *testing.T) {
+	audio, s, conn := startTestServer(t)
+	readStatus(t, conn)
+
+	go s.BroadcastUpdates(t.Context())
+
+	broadcast := make(chan statusResponse, 1)
+	go func() {
+		var res statusResponse
+		if err := conn.ReadJSON(&res); err == nil {
+			broadcast <- res
+		}
+	}()
+
+	mute := func(status *pactl.Status) { status.Sinks[1].Muted = true }
+	timeout := time.After(2 * time.Second)
+
+	// Events emitted before broadcaster starts listening are lost, keep changing until it picks up
+	for {
+		audio.Update(pactl.Event{Type: "change", Facility: "sink", Index: 56}, mute)
+
+		select {
+		case res := <-broadcast:
+			if res.Action != string(json.ActionGetStatus) || res.Type != string(json.TypeEvent) || !res.Payload.Sinks[1].Muted {
+				t.Errorf("[Err] Expected status with muted headset, got %+v", res)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("[Err] No broadcast after backend change")
+		}
+	}
+}
+
+func TestBroadcastDeltas(t *testing.T) {
+	audio := fake.New()
+	s := NewServer(audio)
+
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	t.Cleanup(func() {
+		audio.Close()
+		srv.Close()
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?updates=delta", nil)
+	if err != nil {
+		t.Fatalf("[Err] Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	snapshot := readStatus(t, conn)
+	if snapshot.Seq == 0 || len(snapshot.Payload.Sinks) != 2 {
+		t.Fatalf("[Err] Expected full status with seq on connect, got %+v", snapshot)
+	}
+
+	go s.BroadcastUpdates(t.Context())
+
+	type deltaResponse struct {
+		Action  string            `json:"action"`
+		Seq     uint64            `json:"seq"`
+		Payload pactl.StatusDelta `json:"payload"`
+	}
+	broadcast := make(chan deltaResponse, 1)
+	go func() {
+		var res deltaResponse
+		if err := conn.ReadJSON(&res); err == nil {
+			broadcast <- res
+		}
+	}()
+
+	mute := func(status *pactl.Status) { status.Sinks[1].Muted = true }
+	timeout := time.After(2 * time.Second)
+
+	for {
+		audio.Update(pactl.Event{Type: "change", Facility: "sink", Index: 56}, mute)
+
+		select {
+		case res := <-broadcast:
+			if res.Action != json.EventStatusDelta || res.Seq != snapshot.Seq+1 {
+				t.Errorf("[Err] Expected StatusDelta with seq %d, got %s %d", snapshot.Seq+1, res.Action, res.Seq)
+			}
+			// Delta is from status client got on connect
+			if len(res.Payload.Sinks) != 1 || res.Payload.Sinks[0].ID != 56 || !res.Payload.Sinks[0].Muted {
+				t.Errorf("[Err] Expected muted headset in delta, got %+v", res.Payload)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("[Err] No broadcast after backend change")
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	audio := fake.New()
+	s := NewServer(audio)
+
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	t.Cleanup(func() {
+		audio.Close()
+		srv.Close()
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	stopped := make(chan struct{})
+	go func() {
+		s.BroadcastUpdates(ctx)
+		close(stopped)
+	}()
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("[Err] Dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	expectGoingAway := func(conn *websocket.Conn) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err := conn.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.CloseGoingAway) || !strings.Contains(err.Error(), shutdownReason) {
+			t.Errorf("[Err] Expected close %d %q, got %v", websocket.CloseGoingAway, shutdownReason, err)
+		}
+	}
+
+	// Initial status is still queued, it goes out before close frame
+	conn := dial()
+	shutdownCtx, shutdownCancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer shutdownCancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("[Err] Shutdown: %v", err)
+	}
+
+	if res := readStatus(t, conn); res.Action != string(json.ActionGetStatus) {
+		t.Errorf("[Err] Expected initial status before close, got %+v", res)
+	}
+	expectGoingAway(conn)
+
+	s.clientsMutex.Lock()
+	connected := len(s.clients)
+	s.clientsMutex.Unlock()
+	if connected != 0 {
+		t.Errorf("[Err] Expected no clients after Shutdown, got %d", connected)
+	}
+
+	expectGoingAway(dial())
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Errorf("[Err] BroadcastUpdates still running after cancel")
+	}
+}
+
+func TestHandleWebSocketUnauthorized(t *testing.T) {
+	audio := fake.New()
+	s := NewServer(audio)
+	s.Authorize = func(r *http.Request) (auth.Role, bool) {
+		return auth.RoleAdmin, r.URL.Query().Get("token") == "secret"
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	t.Cleanup(func() {
+		audio.Close()
+		srv.Close()
+	})
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("[Err] Dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, CloseUnauthorized) {
+		t.Errorf("[Err] Expected close code %d before any status, got %v", CloseUnauthorized, err)
+	}
+
+	conn, _, err = websocket.DefaultDialer.Dial(url+"?token=secret", nil)
+	if err != nil {
+		t.Fatalf("[Err] Dial: %v", err)
+	}
+	defer conn.Close()
+
+	if res := readStatus(t, conn); res.Status != json.StatusSuccess {
+		t.Errorf("[Err] Expected initial status for authorized client, got %+v", res)
+	}
+}
+
+func TestHandleWebSocketRoles(t *testing.T) {
+	audio, s, conn := startTestServer(t)
+	role := auth.RoleViewer
+	s.Authorize = func(r *http.Request) (auth.Role, bool) { return role, true }
+	readStatus(t, conn)
+
+	tests := []struct {
+		Name    string
+		Role    auth.Role
+		Action  json.Action
+		Payload any
+		Status  int16
+	}{
+		{"ViewerGetStatus", auth.RoleViewer, json.ActionGetStatus, nil, json.StatusSuccess},
+		{"ViewerSetSinkVolume", auth.RoleViewer, json.ActionSetSinkVolume, map[string]any{"name": "alsa_output.speakers", "volume": 20}, json.StatusUnauthorized},
+		{"OperatorSetSinkVolume", auth.RoleOperator, json.ActionSetSinkVolume, map[string]any{"name": "alsa_output.speakers", "volume": 20}, json.StatusSuccess},
+		{"OperatorSetDefaultSink", auth.RoleOperator, json.ActionSetDefaultSink, map[string]any{"name": "bluez_output.headset"}, json.StatusUnauthorized},
+		{"OperatorMoveSinkInput", auth.RoleOperator, json.ActionMoveSinkInput, map[string]any{"id": 91, "name": "bluez_output.headset"}, json.StatusUnauthorized},
+		{"AdminSetDefaultSink", auth.RoleAdmin, json.ActionSetDefaultSink, map[string]any{"name": "bluez_output.headset"}, json.StatusSuccess},
+		{"ViewerUnknownAction", auth.RoleViewer, json.Action("Nope"), nil, json.StatusActionError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			role = tt.Role
+			calls := len(audio.Calls())
+
+			res := send(t, conn, tt.Action, tt.Payload)
+			if res.Status != tt.Status {
+				t.Fatalf("[Err] Expected status %d, got %+v", tt.Status, res)
+			}
+			if tt.Status == json.StatusUnauthorized {
+				if len(audio.Calls()) != calls {
+					t.Errorf("[Err] Backend called for not allowed action")
+				}
+				if !strings.Contains(res.Error, tt.Role.String()) || len(res.Payload.Sinks) == 0 {
+					t.Errorf("[Err] Expected error with role and status payload, got %+v", res)
+				}
+			}
+		})
+	}
+}
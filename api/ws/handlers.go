@@ -2,253 +2,153 @@ package ws
 
 import (
 	"fmt"
+	"strconv"
 
+	"github.com/undg/pulse-remote/api/backend"
 	"github.com/undg/pulse-remote/api/json"
 	"github.com/undg/pulse-remote/api/logger"
-	"github.com/undg/pulse-remote/api/pactl"
 )
 
-func handleSetSinkVolume(msg *json.Message, res *json.Response) {
-	if sinkInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		name, ok := sinkInfo["name"].(string)
-		if !ok {
-			logger.Error().Msg("sinkInfo['name'].(string) NOT OK\n")
-		}
-
-		volume, ok := sinkInfo["volume"].(float64)
-		if !ok {
-			logger.Error().Msg("sinkInfo['volume'].(float64) NOT OK")
-		}
-
-		pactl.SetSinkVolume(name, fmt.Sprintf("%.2f", volume))
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid sink information format"
-		res.Status = json.StatusActionError
-	}
-}
-
-func handleSetSinkMuted(msg *json.Message, res *json.Response) {
-	if sinkInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		name, ok := sinkInfo["name"].(string)
-		if !ok {
-			logger.Error().Msg("sinkInfo['name'].(string) NOT OK")
-		}
-
-		muted, ok := sinkInfo["muted"].(bool)
-		if !ok {
-			logger.Error().Msg("sinkInfo['muted'].(bool) NOT OK")
-		}
-
-		pactl.SetSinkMuted(name, muted)
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid sink information format"
-		res.Status = json.StatusActionError
-	}
-}
-
-func handleSetDefaultSink(msg *json.Message, res *json.Response) {
-	if sinkInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		name, ok := sinkInfo["name"].(string)
-		if !ok {
-			logger.Error().Msg("sinkInfo['name'].(string) NOT OK")
-		}
-
-		pactl.SetDefaultSink(name)
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid sink information format"
-		res.Status = json.StatusActionError
-	}
-}
-
-func handleSetSinkInputVolume(msg *json.Message, res *json.Response) {
-	if sinkInputInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		id, ok := sinkInputInfo["id"].(float64)
-		if !ok {
-			logger.Error().Msg("sinkInfo['id'].(float64) NOT OK")
-		}
-
-		volume, ok := sinkInputInfo["volume"].(float64)
-		if !ok {
-			logger.Error().Msg("sinkInfo['volume'].(float64) NOT OK")
-		}
-
-		pactl.SetSinkInputVolume(fmt.Sprintf("%.0f", id), fmt.Sprintf("%.2f", volume))
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid sink information format"
-		res.Status = json.StatusActionError
-	}
-}
-
-func handleSetSinkInputMuted(msg *json.Message, res *json.Response) {
-	if sinkInputInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		id, ok := sinkInputInfo["id"].(float64)
-		if !ok {
-			logger.Error().Msg("sinkInfo['id'].(float64) NOT OK")
-		}
-
-		muted, ok := sinkInputInfo["muted"].(bool)
-		if !ok {
-			logger.Error().Msg("sinkInfo['muted'].(bool) NOT OK")
-		}
-
-		pactl.SetSinkInputMuted(fmt.Sprintf("%.0f", id), muted)
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid sink information format"
-		res.Status = json.StatusActionError
-	}
+// actionHandler runs one action. Actions with payload are commands, they change something and respond with fresh status.
+// Actions without payload are queries.
+type actionHandler struct {
+	// Zero value of payload type, nil for queries. Schemas are generated from it.
+	payload any
+	// Response payload of query
+	query func(b backend.AudioBackend) any
+	// decode returns typed and validated payload for run
+	decode func(raw interface{}) (any, error)
+	run    func(b backend.AudioBackend, p any) error
+	// Set instead of run for actions that change WebSocket connection itself, they have no REST endpoint
+	client func(s *Server, c *client, p any) error
 }
 
-func handleMoveSinkInput(msg *json.Message, res *json.Response) {
-	if sinkInputInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		sinkInputID, ok := sinkInputInfo["id"].(float64)
-		if !ok {
-			logger.Error().Msg("sinkInfo['id'].(float64) NOT OK")
-		}
-
-		sinkName, ok := sinkInputInfo["name"].(string)
-		if !ok {
-			logger.Error().Msg("sinkInfo['name'].(string) NOT OK")
-		}
-
-		pactl.MoveSinkInput(fmt.Sprintf("%.0f", sinkInputID), sinkName)
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid sink information format"
-		res.Status = json.StatusActionError
+// command registers action with payload P
+func command[P any](run func(b backend.AudioBackend, p P) error) actionHandler {
+	var zero P
+	return actionHandler{
+		payload: zero,
+		decode: func(raw interface{}) (any, error) {
+			return decodePayload[P](raw)
+		},
+		run: func(b backend.AudioBackend, p any) error {
+			return run(b, p.(P))
+		},
 	}
 }
 
-// SOURCES, Microphones
-func handleSetSourceVolume(msg *json.Message, res *json.Response) {
-	if sourceInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		name, ok := sourceInfo["name"].(string)
-		if !ok {
-			logger.Error().Msg("sourceInfo['name'].(string) NOT OK")
-		}
-
-		volume, ok := sourceInfo["volume"].(float64)
-		if !ok {
-			logger.Error().Msg("sourceInfo['volume'].(float64) NOT OK")
-		}
-
-		pactl.SetSourceVolume(name, fmt.Sprintf("%.2f", volume))
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid source information format"
-		res.Status = json.StatusActionError
+// clientCommand registers action with payload P that changes client's connection, not the backend
+func clientCommand[P any](run func(s *Server, c *client, p P) error) actionHandler {
+	h := command(func(backend.AudioBackend, P) error { return nil })
+	h.run = nil
+	h.client = func(s *Server, c *client, p any) error {
+		return run(s, c, p.(P))
 	}
+	return h
 }
 
-func handleSetSourceMuted(msg *json.Message, res *json.Response) {
-	if sourceInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		name, ok := sourceInfo["name"].(string)
-		if !ok {
-			logger.Error().Msg("sourceInfo['name'].(string) NOT OK")
-		}
-
-		muted, ok := sourceInfo["muted"].(bool)
-		if !ok {
-			logger.Error().Msg("sourceInfo['muted'].(bool) NOT OK")
-		}
-
-		pactl.SetSourceMuted(name, muted)
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid source information format"
-		res.Status = json.StatusActionError
-	}
+func query(fn func(b backend.AudioBackend) any) actionHandler {
+	return actionHandler{query: fn}
 }
 
-func handleSetDefaultSource(msg *json.Message, res *json.Response) {
-	if sourceInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		name, ok := sourceInfo["name"].(string)
-		if !ok {
-			logger.Error().Msg("sourceInfo['name'].(string) NOT OK")
-		}
-
-		pactl.SetDefaultSource(name)
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid source information format"
-		res.Status = json.StatusActionError
-	}
+// actions has handler of every action in json.AvailableCommands
+var actions = map[json.Action]actionHandler{
+	json.ActionGetStatus: query(func(b backend.AudioBackend) any {
+		return b.GetStatus()
+	}),
+	json.ActionGetBuildInfo: query(func(b backend.AudioBackend) any {
+		return b.GetStatus().BuildInfo
+	}),
+
+	// SINKS, Speakers
+	json.ActionSetSinkVolume: command(func(b backend.AudioBackend, p json.VolumePayload) error {
+		return b.SetSinkVolume(p.Name, volumeArg(p.Volume))
+	}),
+	json.ActionChangeSinkVolume: command(func(b backend.AudioBackend, p json.VolumeChangePayload) error {
+		return b.ChangeSinkVolume(p.Name, p.Delta)
+	}),
+	json.ActionSetSinkBalance: command(func(b backend.AudioBackend, p json.BalancePayload) error {
+		return b.SetSinkBalance(p.Name, p.Balance)
+	}),
+	json.ActionSetSinkChannelVolumes: command(func(b backend.AudioBackend, p json.ChannelVolumesPayload) error {
+		return b.SetSinkChannelVolumes(p.Name, p.Volumes)
+	}),
+	json.ActionSetSinkMuted: command(func(b backend.AudioBackend, p json.MutedPayload) error {
+		return b.SetSinkMuted(p.Name, p.Muted)
+	}),
+	json.ActionSetDefaultSink: command(func(b backend.AudioBackend, p json.DefaultPayload) error {
+		return b.SetDefaultSink(p.Name)
+	}),
+	json.ActionSetSinkPort: command(func(b backend.AudioBackend, p json.PortPayload) error {
+		return b.SetSinkPort(p.Name, p.Port)
+	}),
+
+	// App's under SINKS
+	json.ActionSetSinkInputVolume: command(func(b backend.AudioBackend, p json.AppVolumePayload) error {
+		return b.SetSinkInputVolume(idArg(p.ID), volumeArg(p.Volume))
+	}),
+	json.ActionChangeSinkInputVolume: command(func(b backend.AudioBackend, p json.AppVolumeChangePayload) error {
+		return b.ChangeSinkInputVolume(idArg(p.ID), p.Delta)
+	}),
+	json.ActionSetSinkInputMuted: command(func(b backend.AudioBackend, p json.AppMutedPayload) error {
+		return b.SetSinkInputMuted(idArg(p.ID), p.Muted)
+	}),
+	json.ActionMoveSinkInput: command(func(b backend.AudioBackend, p json.MoveSinkInputPayload) error {
+		return b.MoveSinkInput(idArg(p.ID), p.Name)
+	}),
+
+	// SOURCES, Microphones
+	json.ActionSetSourceVolume: command(func(b backend.AudioBackend, p json.VolumePayload) error {
+		return b.SetSourceVolume(p.Name, volumeArg(p.Volume))
+	}),
+	json.ActionChangeSourceVolume: command(func(b backend.AudioBackend, p json.VolumeChangePayload) error {
+		return b.ChangeSourceVolume(p.Name, p.Delta)
+	}),
+	json.ActionSetSourceMuted: command(func(b backend.AudioBackend, p json.MutedPayload) error {
+		return b.SetSourceMuted(p.Name, p.Muted)
+	}),
+	json.ActionSetDefaultSource: command(func(b backend.AudioBackend, p json.DefaultPayload) error {
+		return b.SetDefaultSource(p.Name)
+	}),
+	json.ActionSetSourcePort: command(func(b backend.AudioBackend, p json.PortPayload) error {
+		return b.SetSourcePort(p.Name, p.Port)
+	}),
+
+	// App's under SOURCES
+	json.ActionSetSourceInputVolume: command(func(b backend.AudioBackend, p json.AppVolumePayload) error {
+		return b.SetSourceOutputVolume(idArg(p.ID), volumeArg(p.Volume))
+	}),
+	json.ActionSetSourceInputMuted: command(func(b backend.AudioBackend, p json.AppMutedPayload) error {
+		return b.SetSourceOutputMuted(idArg(p.ID), p.Muted)
+	}),
+	json.ActionMoveSourceOutput: command(func(b backend.AudioBackend, p json.MoveSourceOutputPayload) error {
+		return b.MoveSourceOutput(idArg(p.OutputID), p.SourceName)
+	}),
+
+	// CARDS
+	json.ActionSetCardProfile: command(func(b backend.AudioBackend, p json.CardProfilePayload) error {
+		return b.SetCardProfile(p.Name, p.Profile)
+	}),
+
+	// WebSocket connection
+	json.ActionSubscribe: clientCommand(func(s *Server, c *client, p json.TopicsPayload) error {
+		s.subscribe(c, p.Topics, true)
+		return nil
+	}),
+	json.ActionUnsubscribe: clientCommand(func(s *Server, c *client, p json.TopicsPayload) error {
+		s.subscribe(c, p.Topics, false)
+		return nil
+	}),
 }
 
-func handleSetSourceInputVolume(msg *json.Message, res *json.Response) {
-	if sourceInputInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		id, ok := sourceInputInfo["id"].(float64)
-		if !ok {
-			logger.Error().Msg("sourceInfo['id'].(float64) NOT OK")
-		}
-
-		volume, ok := sourceInputInfo["volume"].(float64)
-		if !ok {
-			logger.Error().Msg("sourceInfo['volume'].(float64) NOT OK")
-		}
-
-		pactl.SetSourceInputVolume(fmt.Sprintf("%.0f", id), fmt.Sprintf("%.2f", volume))
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid source information format"
-		res.Status = json.StatusActionError
-	}
+// volumeArg is absolute volume in the format backend takes
+func volumeArg(volume float64) string {
+	return fmt.Sprintf("%.2f", volume)
 }
 
-func handleSetSourceInputMuted(msg *json.Message, res *json.Response) {
-	if sourceInputInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		id, ok := sourceInputInfo["id"].(float64)
-		if !ok {
-			logger.Error().Msg("sourceInfo['id'].(float64) NOT OK")
-		}
-
-		muted, ok := sourceInputInfo["muted"].(bool)
-		if !ok {
-			logger.Error().Msg("sourceInfo['muted'].(bool) NOT OK")
-		}
-
-		pactl.SetSourceInputMuted(fmt.Sprintf("%.0f", id), muted)
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid source information format"
-		res.Status = json.StatusActionError
-	}
-}
-
-func handleMoveSourceOutput(msg *json.Message, res *json.Response) {
-	if sourceOutputInfo, ok := msg.Payload.(map[string]interface{}); ok {
-		sourceOutputID, ok := sourceOutputInfo["outputId"].(float64)
-		if !ok {
-			logger.Error().Msg("sourceOutputInfo['outputId'].(float64) NOT OK")
-		}
-
-		sourceName, ok := sourceOutputInfo["sourceName"].(string)
-		if !ok {
-			logger.Error().Msg("sourceOutputInfo['sourceName'].(string) NOT OK")
-		}
-
-		pactl.MoveSourceOutput(fmt.Sprintf("%.0f", sourceOutputID), sourceName)
-
-		res.Payload = pactl.GetStatus()
-	} else {
-		res.Error = "Invalid source information format"
-		res.Status = json.StatusActionError
-	}
+// idArg is ID of sink input or source output in the format backend takes
+func idArg(id uint32) string {
+	return strconv.FormatUint(uint64(id), 10)
 }
 
 func handleServerLog(msg *json.Message, res *json.Response) {
@@ -0,0 +1,62 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+func TestActions(t *testing.T) {
+	if len(actions) != len(json.AvailableCommands) {
+		t.Errorf("[Err] Expected handler of every action and nothing else, got %d for %d actions", len(actions), len(json.AvailableCommands))
+	}
+
+	for _, action := range json.AvailableCommands {
+		s, err := payloadSchema(action)
+		if err != nil {
+			t.Errorf("[Err] payloadSchema(%s): %v", action, err)
+			continue
+		}
+		if s != nil && (s.Type != "object" || len(s.Required) == 0) {
+			t.Errorf("[Err] Expected object with required fields for %s, got %+v", action, s)
+		}
+	}
+
+	s, _ := payloadSchema(json.ActionSetSinkInputVolume)
+	if s.Properties["id"].Type != "integer" || *s.Properties["id"].Minimum != 0 || *s.Properties["volume"].Minimum != 0 || *s.Properties["volume"].Maximum != pactl.MaxVolume {
+		t.Errorf("[Err] Expected not negative integer id and capped volume, got %+v", s.Properties)
+	}
+
+	if _, err := payloadSchema("Nope"); err == nil {
+		t.Errorf("[Err] Expected error for unknown action")
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	p, err := decodePayload[json.MoveSourceOutputPayload](map[string]interface{}{"outputId": float64(93), "sourceName": "alsa_input.mic"})
+	if err != nil || p.OutputID != 93 || p.SourceName != "alsa_input.mic" {
+		t.Errorf("[Err] Expected decoded payload, got %+v %v", p, err)
+	}
+
+	tests := []struct {
+		name    string
+		payload interface{}
+		want    string
+	}{
+		{"NotObject", "speakers", "Invalid payload format, expected object"},
+		{"Missing", map[string]interface{}{"volume": float64(30)}, "Missing or invalid 'name' in payload, expected not empty string"},
+		{"Empty", map[string]interface{}{"name": "", "volume": float64(30)}, "Missing or invalid 'name' in payload, expected not empty string"},
+		{"WrongType", map[string]interface{}{"name": "alsa_output.speakers", "volume": "loud"}, "Missing or invalid 'volume' in payload, expected number"},
+		{"Unknown", map[string]interface{}{"name": "alsa_output.speakers", "volume": float64(30), "loud": true}, `Unknown field "loud" in payload`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodePayload[json.VolumePayload](tt.payload)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("[Err] Expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// lifetimeBuckets are upper bounds of connection lifetime histogram, phones on Wi-Fi tend to live short
+var lifetimeBuckets = []time.Duration{
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	10 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+}
+
+// lifetimes is histogram of closed WebSocket connections
+type lifetimes struct {
+	mu sync.Mutex
+	// Connections not longer than bucket of the same index, last one is for longer than all of them
+	buckets []uint64
+	count   uint64
+	sum     time.Duration
+}
+
+func (l *lifetimes) observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = make([]uint64, len(lifetimeBuckets)+1)
+	}
+
+	i := 0
+	for i < len(lifetimeBuckets) && d > lifetimeBuckets[i] {
+		i++
+	}
+	l.buckets[i]++
+	l.count++
+	l.sum += d
+}
+
+// serveMetrics writes connected clients and lifetimes of closed connections in Prometheus text format
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	s.clientsMutex.Lock()
+	connected := len(s.clients)
+	s.clientsMutex.Unlock()
+
+	s.lifetimes.mu.Lock()
+	defer s.lifetimes.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP pulse_remote_ws_connections Connected WebSocket clients.")
+	fmt.Fprintln(w, "# TYPE pulse_remote_ws_connections gauge")
+	fmt.Fprintf(w, "pulse_remote_ws_connections %d\n", connected)
+
+	const name = "pulse_remote_ws_connection_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Lifetime of closed WebSocket connections.\n", name)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+
+	var cumulative uint64
+	for i, bound := range lifetimeBuckets {
+		if s.lifetimes.buckets != nil {
+			cumulative += s.lifetimes.buckets[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound.Seconds(), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, s.lifetimes.count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, s.lifetimes.sum.Seconds())
+	fmt.Fprintf(w, "%s_count %d\n", name, s.lifetimes.count)
+}
@@ -0,0 +1,132 @@
+package ws
+
+import (
+	stdjson "encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/danielgtaylor/huma/schema"
+
+	"github.com/undg/pulse-remote/api/buildinfo"
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// OpenAPI describes REST endpoints, generated from restRoutes, payloads of actions and Go types of responses
+func OpenAPI() (map[string]any, error) {
+	status, err := schema.Generate(reflect.TypeOf(pactl.Status{}))
+	if err != nil {
+		return nil, err
+	}
+	errorRes, err := json.ResponseSchema()
+	if err != nil {
+		return nil, err
+	}
+	errorRes.Description = "Failed action, status and error are the same as in WebSocket response"
+
+	paths := map[string]map[string]any{}
+	for _, route := range restRoutes {
+		operation, err := openAPIOperation(route)
+		if err != nil {
+			return nil, err
+		}
+		if paths[route.path] == nil {
+			paths[route.path] = map[string]any{}
+		}
+		paths[route.path][strings.ToLower(route.method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "pulse-remote",
+			"description": "Every endpoint runs the WebSocket action of the same name. Successful requests respond with fresh status.",
+			"version":     buildinfo.Get().GitVersion,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Status": status,
+				"Error":  errorRes,
+			},
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"cookie": map[string]any{"type": "apiKey", "in": "cookie", "name": "pulse_remote_token"},
+			},
+		},
+		// Auth is opt-in, without it no token is needed
+		"security": []map[string][]string{{}, {"bearer": {}}, {"cookie": {}}},
+	}, nil
+}
+
+func openAPIOperation(route restRoute) (map[string]any, error) {
+	result := jsonContent(ref("Status"))
+	if route.result != nil {
+		s, err := schema.Generate(reflect.TypeOf(route.result))
+		if err != nil {
+			return nil, err
+		}
+		result = jsonContent(s)
+	}
+
+	errorContent := jsonContent(ref("Error"))
+	responses := map[string]any{
+		"200": map[string]any{"description": "Success", "content": result},
+		"401": map[string]any{"description": "Client is not authenticated"},
+		"403": map[string]any{"description": "Client's role doesn't allow the action, or client is outside of local networks or from foreign origin", "content": errorContent},
+	}
+
+	operation := map[string]any{
+		"operationId": string(route.action),
+		"summary":     route.summary,
+		"tags":        []string{strings.Split(strings.TrimPrefix(route.path, "/api/v1/"), "/")[0]},
+		"responses":   responses,
+	}
+
+	body, err := payloadSchema(route.action)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return operation, nil
+	}
+
+	if route.param != "" {
+		param := body.Properties[route.key]
+		operation["parameters"] = []map[string]any{{"name": route.param, "in": "path", "required": true, "schema": param}}
+		// Path param is not repeated in body
+		body.RemoveProperty(route.key)
+	}
+	operation["requestBody"] = map[string]any{"required": true, "content": jsonContent(body)}
+
+	responses["400"] = map[string]any{"description": "Invalid JSON or payload", "content": errorContent}
+	responses["415"] = map[string]any{"description": "Content-Type is not application/json", "content": errorContent}
+	responses["404"] = map[string]any{"description": "No such sink, source, app or card", "content": errorContent}
+	responses["502"] = map[string]any{"description": "Sound server rejected the action", "content": errorContent}
+
+	return operation, nil
+}
+
+func ref(name string) map[string]string {
+	return map[string]string{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(s any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": s}}
+}
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := OpenAPI()
+	if err != nil {
+		logger.Error().Err(err).Msg("OpenAPI()")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := stdjson.NewEncoder(w).Encode(doc); err != nil {
+		logger.Error().Err(err).Msg("json.NewEncoder(w).Encode(doc)")
+	}
+}
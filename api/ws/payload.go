@@ -0,0 +1,190 @@
+package ws
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/schema"
+
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// decodePayload turns Message.Payload into P. Fields of P without omitempty are required, unknown fields are rejected,
+// minLength, minimum and maximum tags are checked and so is Validate of P if it has one.
+// Nothing reaches the backend before payload passes all of it.
+func decodePayload[P any](raw interface{}) (P, error) {
+	var p P
+
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return p, errors.New("Invalid payload format, expected object")
+	}
+
+	t := reflect.TypeOf(p)
+	for i := 0; i < t.NumField(); i++ {
+		key, required := fieldKey(t.Field(i))
+		if v, ok := fields[key]; required && (!ok || v == nil) {
+			return p, invalidField(key, expectedOf(t.Field(i).Type))
+		}
+	}
+
+	b, err := stdjson.Marshal(fields)
+	if err != nil {
+		return p, err
+	}
+	dec := stdjson.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		var typeErr *stdjson.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return p, invalidField(typeErr.Field, expectedOf(typeErr.Type))
+		}
+		if key, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return p, fmt.Errorf("Unknown field %s in payload", key)
+		}
+		return p, err
+	}
+
+	if err := checkTags(reflect.ValueOf(p)); err != nil {
+		return p, err
+	}
+
+	if v, ok := any(p).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return p, fmt.Errorf("Invalid payload: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+// fieldKey is JSON key of payload field, field is required without omitempty
+func fieldKey(f reflect.StructField) (string, bool) {
+	name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, !strings.Contains(opts, "omitempty")
+}
+
+// checkTags checks minLength, minimum and maximum tags, the same ones schemas are generated from
+func checkTags(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _ := fieldKey(f)
+		value := v.Field(i)
+
+		if tag := f.Tag.Get("minLength"); tag != "" {
+			min, err := strconv.Atoi(tag)
+			if err != nil {
+				return fmt.Errorf("minLength of %s: %w", key, err)
+			}
+			if value.Len() < min {
+				return invalidField(key, expectedOf(f.Type))
+			}
+		}
+
+		var number float64
+		switch value.Kind() {
+		case reflect.Float32, reflect.Float64:
+			number = value.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			number = float64(value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			number = float64(value.Uint())
+		default:
+			continue
+		}
+
+		for _, bound := range []string{"minimum", "maximum"} {
+			tag := f.Tag.Get(bound)
+			if tag == "" {
+				continue
+			}
+			limit, err := strconv.ParseFloat(tag, 64)
+			if err != nil {
+				return fmt.Errorf("%s of %s: %w", bound, key, err)
+			}
+			if (bound == "minimum" && number < limit) || (bound == "maximum" && number > limit) {
+				return invalidField(key, fmt.Sprintf("number with %s %s", bound, tag))
+			}
+		}
+	}
+	return nil
+}
+
+func invalidField(key string, expected string) error {
+	return fmt.Errorf("Missing or invalid '%s' in payload, expected %s", key, expected)
+}
+
+// expectedOf describes Go type of payload field the way clients know it from JSON
+func expectedOf(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "not empty string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "not negative integer"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array of " + expectedOf(t.Elem()) + "s"
+	default:
+		return t.String()
+	}
+}
+
+// respond puts fresh status into response. Backend error is described in Error,
+// status is sent anyway so clients can revert optimistic updates. err is returned as it is.
+func (s *Server) respond(res *json.Response, err error) error {
+	if err != nil {
+		res.Error = err.Error()
+		res.Status = json.StatusActionError
+		if errors.Is(err, pactl.ErrInvalidArgument) {
+			res.Status = json.StatusPayloadError
+		}
+		logger.Error().Err(err).Str("action", res.Action).Msg("backend FAIL")
+	}
+
+	res.Payload = s.backend.GetStatus()
+	return err
+}
+
+// payloadSchema is schema of action's payload, nil for actions without payload. Volumes are capped at pactl.MaxVolume,
+// the cap is configurable so it's not in tags.
+func payloadSchema(action json.Action) (*schema.Schema, error) {
+	h, ok := actions[action]
+	if !ok {
+		return nil, fmt.Errorf("action %s has no entry in actions", action)
+	}
+	if h.payload == nil {
+		return nil, nil
+	}
+
+	s, err := schema.Generate(reflect.TypeOf(h.payload))
+	if err != nil {
+		return nil, err
+	}
+
+	max := pactl.MaxVolume
+	if volume, ok := s.Properties["volume"]; ok {
+		volume.Maximum = &max
+	}
+	if volumes, ok := s.Properties["volumes"]; ok && volumes.Items != nil {
+		min := 0.0
+		volumes.Items.Minimum = &min
+		volumes.Items.Maximum = &max
+	}
+	return s, nil
+}
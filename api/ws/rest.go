@@ -0,0 +1,221 @@
+package ws
+
+import (
+	stdjson "encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/undg/pulse-remote/api/buildinfo"
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// restRoute is HTTP endpoint of WebSocket action. Path param and JSON body together are the action's payload
+// from actions, so REST and WebSocket share validation and handlers.
+type restRoute struct {
+	method  string
+	path    string
+	action  json.Action
+	summary string
+	// Path param and payload key it goes to, fe. {id} of source output is outputId. Empty without param.
+	param string
+	key   string
+	// Param is number in payload, like IDs. Names stay strings, even when they look like numbers.
+	numeric bool
+	// Documents successful response in OpenAPI, Status when nil
+	result any
+}
+
+var restRoutes = []restRoute{
+	{method: http.MethodGet, path: "/api/v1/status", action: json.ActionGetStatus,
+		summary: "Status of all sinks, sources, apps and cards"},
+	{method: http.MethodGet, path: "/api/v1/build-info", action: json.ActionGetBuildInfo,
+		summary: "Build information", result: buildinfo.BuildInfo{}},
+
+	// SINKS, Speakers
+	{method: http.MethodPut, path: "/api/v1/sinks/{name}/volume", action: json.ActionSetSinkVolume, param: "name", key: "name",
+		summary: "Set sink volume"},
+	{method: http.MethodPost, path: "/api/v1/sinks/{name}/volume/change", action: json.ActionChangeSinkVolume, param: "name", key: "name",
+		summary: "Change sink volume by signed delta"},
+	{method: http.MethodPut, path: "/api/v1/sinks/{name}/balance", action: json.ActionSetSinkBalance, param: "name", key: "name",
+		summary: "Set sink left/right balance"},
+	{method: http.MethodPut, path: "/api/v1/sinks/{name}/channel-volumes", action: json.ActionSetSinkChannelVolumes, param: "name", key: "name",
+		summary: "Set volume of every sink channel"},
+	{method: http.MethodPost, path: "/api/v1/sinks/{name}/mute", action: json.ActionSetSinkMuted, param: "name", key: "name",
+		summary: "Mute or unmute sink"},
+	{method: http.MethodPut, path: "/api/v1/sinks/{name}/port", action: json.ActionSetSinkPort, param: "name", key: "name",
+		summary: "Set active sink port"},
+	{method: http.MethodPut, path: "/api/v1/defaults/sink", action: json.ActionSetDefaultSink,
+		summary: "Set default sink"},
+
+	// App's under SINKS
+	{method: http.MethodPut, path: "/api/v1/sink-inputs/{id}/volume", action: json.ActionSetSinkInputVolume, param: "id", key: "id", numeric: true,
+		summary: "Set volume of app playing audio"},
+	{method: http.MethodPost, path: "/api/v1/sink-inputs/{id}/volume/change", action: json.ActionChangeSinkInputVolume, param: "id", key: "id", numeric: true,
+		summary: "Change volume of app playing audio by signed delta"},
+	{method: http.MethodPost, path: "/api/v1/sink-inputs/{id}/mute", action: json.ActionSetSinkInputMuted, param: "id", key: "id", numeric: true,
+		summary: "Mute or unmute app playing audio"},
+	{method: http.MethodPost, path: "/api/v1/sink-inputs/{id}/move", action: json.ActionMoveSinkInput, param: "id", key: "id", numeric: true,
+		summary: "Move app playing audio to another sink"},
+
+	// SOURCES, Microphones
+	{method: http.MethodPut, path: "/api/v1/sources/{name}/volume", action: json.ActionSetSourceVolume, param: "name", key: "name",
+		summary: "Set source volume"},
+	{method: http.MethodPost, path: "/api/v1/sources/{name}/volume/change", action: json.ActionChangeSourceVolume, param: "name", key: "name",
+		summary: "Change source volume by signed delta"},
+	{method: http.MethodPost, path: "/api/v1/sources/{name}/mute", action: json.ActionSetSourceMuted, param: "name", key: "name",
+		summary: "Mute or unmute source"},
+	{method: http.MethodPut, path: "/api/v1/sources/{name}/port", action: json.ActionSetSourcePort, param: "name", key: "name",
+		summary: "Set active source port"},
+	{method: http.MethodPut, path: "/api/v1/defaults/source", action: json.ActionSetDefaultSource,
+		summary: "Set default source"},
+
+	// App's under SOURCES
+	{method: http.MethodPut, path: "/api/v1/source-outputs/{id}/volume", action: json.ActionSetSourceInputVolume, param: "id", key: "id", numeric: true,
+		summary: "Set volume of app recording audio"},
+	{method: http.MethodPost, path: "/api/v1/source-outputs/{id}/mute", action: json.ActionSetSourceInputMuted, param: "id", key: "id", numeric: true,
+		summary: "Mute or unmute app recording audio"},
+	{method: http.MethodPost, path: "/api/v1/source-outputs/{id}/move", action: json.ActionMoveSourceOutput, param: "id", key: "outputId", numeric: true,
+		summary: "Move app recording audio to another source"},
+
+	// CARDS
+	{method: http.MethodPut, path: "/api/v1/cards/{name}/profile", action: json.ActionSetCardProfile, param: "name", key: "name",
+		summary: "Set card profile, fe. switch Bluetooth headset between A2DP and HSP/HFP"},
+}
+
+// wsOnlyActions change WebSocket connection itself, they have no REST endpoint. Values are summaries for AsyncAPI.
+var wsOnlyActions = map[json.Action]string{
+	json.ActionSubscribe:   "Receive status events of topics only, fe. sinks or sinks:alsa_output.speakers. Level meters (levels topic) are not available.",
+	json.ActionUnsubscribe: "Stop status events of topics. One device of a list client gets, fe. sinks:alsa_output.speakers, is left out of it.",
+}
+
+// RESTHandler serves every action as HTTP endpoint, OpenAPI document of them on /api/v1/openapi.json
+// and AsyncAPI document of WebSocket on /api/v1/asyncapi.json.
+// Clients are authorized with Authorize, the same as WebSocket ones.
+func (s *Server) RESTHandler() http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range restRoutes {
+		mux.HandleFunc(route.method+" "+route.path, s.handleREST(route))
+	}
+	mux.HandleFunc("GET /api/v1/openapi.json", serveOpenAPI)
+	mux.HandleFunc("GET /api/v1/asyncapi.json", serveAsyncAPI)
+	mux.HandleFunc("GET /api/v1/metrics", s.serveMetrics)
+	return mux
+}
+
+func (s *Server) handleREST(route restRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Same policy as WebSocket handshake. Without it any website open in a browser on LAN could send
+		// a request, and clients from outside of local networks would get in through REST.
+		if !s.checkOrigin(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		role, ok := s.role(r)
+		if !ok {
+			logger.Warn().Str("client_ip", r.RemoteAddr).Str("path", r.URL.Path).Msg("Unauthorized")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pulse-remote"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		msg := json.Message{Action: route.action}
+		if actions[route.action].payload != nil {
+			// Forms and text/plain are sent cross-site without CORS preflight, JSON is not
+			if !isJSON(r.Header.Get("Content-Type")) {
+				writeREST(w, http.StatusUnsupportedMediaType, json.Response{
+					Action: string(route.action),
+					Status: json.StatusErrorInvalidJSON,
+					Error:  "Content-Type must be application/json",
+				})
+				return
+			}
+
+			fields, err := readBody(w, r)
+			if err != nil {
+				writeREST(w, http.StatusBadRequest, json.Response{
+					Action: string(route.action),
+					Status: json.StatusErrorInvalidJSON,
+					Error:  "Invalid JSON body, expected object: " + err.Error(),
+				})
+				return
+			}
+			if route.param != "" {
+				fields[route.key] = pathValue(r.PathValue(route.param), route.numeric)
+			}
+			msg.Payload = fields
+		}
+
+		res, err := s.run(&msg, role, r, nil)
+		if res.Status == json.StatusSuccess {
+			writeREST(w, http.StatusOK, res.Payload)
+			return
+		}
+
+		// Status of failed request is left out, GET /api/v1/status has it
+		res.Payload = nil
+		writeREST(w, httpStatus(res, err), res)
+	}
+}
+
+// readBody reads JSON object the same way WebSocket messages are read, empty body is empty object
+func readBody(w http.ResponseWriter, r *http.Request) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	err := stdjson.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&fields)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, errors.New("null")
+	}
+	return fields, nil
+}
+
+// isJSON is true for application/json Content-Type, with or without parameters like charset
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// pathValue is number for numeric params, like IDs in WebSocket payload. Anything else stays string,
+// ID that isn't a number fails validation.
+func pathValue(v string, numeric bool) interface{} {
+	if !numeric {
+		return v
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		return n
+	}
+	return v
+}
+
+// httpStatus of failed action, err is from the backend
+func httpStatus(res json.Response, err error) int {
+	switch res.Status {
+	case json.StatusPayloadError, json.StatusErrorInvalidJSON:
+		return http.StatusBadRequest
+	case json.StatusUnauthorized:
+		return http.StatusForbidden
+	case json.StatusActionError:
+		if errors.Is(err, pactl.ErrNoEntity) {
+			return http.StatusNotFound
+		}
+		// Sound server rejected it or isn't running
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func writeREST(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := stdjson.NewEncoder(w).Encode(v); err != nil {
+		logger.Error().Err(err).Msg("json.NewEncoder(w).Encode(v)")
+	}
+}
@@ -0,0 +1,311 @@
+package ws
+
+import (
+	stdjson "encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/undg/pulse-remote/api/auth"
+	"github.com/undg/pulse-remote/api/backend/fake"
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+func startTestREST(t *testing.T, role auth.Role) (*fake.Backend, *httptest.Server) {
+	t.Helper()
+
+	audio := fake.New()
+	s := NewServer(audio)
+	s.Authorize = func(r *http.Request) (auth.Role, bool) {
+		return role, role != auth.RoleNone
+	}
+
+	srv := httptest.NewServer(s.RESTHandler())
+	t.Cleanup(func() {
+		audio.Close()
+		srv.Close()
+	})
+	return audio, srv
+}
+
+func doREST(t *testing.T, srv *httptest.Server, method string, path string, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("[Err] NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[Err] %s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func TestREST(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCall string
+	}{
+		{"SetSinkVolume", http.MethodPut, "/api/v1/sinks/alsa_output.speakers/volume", `{"volume": 30}`, "SetSinkVolume[alsa_output.speakers 30.00]"},
+		{"ChangeSinkVolume", http.MethodPost, "/api/v1/sinks/alsa_output.speakers/volume/change", `{"delta": -5}`, "ChangeSinkVolume[alsa_output.speakers -5.00]"},
+		{"SetSinkMuted", http.MethodPost, "/api/v1/sinks/alsa_output.speakers/mute", `{"muted": true}`, "SetSinkMuted[alsa_output.speakers true]"},
+		{"SetDefaultSink", http.MethodPut, "/api/v1/defaults/sink", `{"name": "bluez_output.headset"}`, "SetDefaultSink[bluez_output.headset]"},
+		{"MoveSinkInput", http.MethodPost, "/api/v1/sink-inputs/91/move", `{"name": "bluez_output.headset"}`, "MoveSinkInput[91 bluez_output.headset]"},
+		{"SetSourceMuted", http.MethodPost, "/api/v1/sources/alsa_input.mic/mute", `{"muted": true}`, "SetSourceMuted[alsa_input.mic true]"},
+		{"MoveSourceOutput", http.MethodPost, "/api/v1/source-outputs/93/move", `{"sourceName": "alsa_output.speakers.monitor"}`, "MoveSourceOutput[93 alsa_output.speakers.monitor]"},
+		{"SetCardProfile", http.MethodPut, "/api/v1/cards/bluez_card.headset/profile", `{"profile": "off"}`, "SetCardProfile[bluez_card.headset off]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audio, srv := startTestREST(t, auth.RoleAdmin)
+
+			res := doREST(t, srv, tt.method, tt.path, tt.body)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("[Err] Expected HTTP 200, got %d", res.StatusCode)
+			}
+
+			var status pactl.Status
+			if err := stdjson.NewDecoder(res.Body).Decode(&status); err != nil || len(status.Sinks) != 2 {
+				t.Errorf("[Err] Expected fresh status, got %+v %v", status, err)
+			}
+
+			calls := audio.Calls()
+			if len(calls) != 1 || calls[0].String() != tt.wantCall {
+				t.Errorf("[Err] Expected backend call %s, got %v", tt.wantCall, calls)
+			}
+		})
+	}
+}
+
+func TestRESTPathParams(t *testing.T) {
+	// Only params that are numbers in payload are converted, names that look like numbers stay names
+	for _, route := range restRoutes {
+		if route.param == "" {
+			continue
+		}
+		p, err := payloadSchema(route.action)
+		if err != nil {
+			t.Fatalf("[Err] payloadSchema(%s): %v", route.action, err)
+		}
+		typ := p.Properties[route.key].Type
+		if numeric := typ == "integer" || typ == "number"; numeric != route.numeric {
+			t.Errorf("[Err] Expected numeric %v for %s of %s, payload has %s", numeric, route.param, route.path, typ)
+		}
+	}
+
+	for _, name := range []string{"1", "inf", "nan", "1e3"} {
+		t.Run(name, func(t *testing.T) {
+			audio, srv := startTestREST(t, auth.RoleAdmin)
+			audio.Update(pactl.Event{Type: "change", Facility: "sink", Index: 55}, func(status *pactl.Status) { status.Sinks[0].Name = name })
+
+			res := doREST(t, srv, http.MethodPut, "/api/v1/sinks/"+name+"/volume", `{"volume": 30}`)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("[Err] Expected HTTP 200 for sink %q, got %d", name, res.StatusCode)
+			}
+			if calls := audio.Calls(); len(calls) != 1 || calls[0].String() != "SetSinkVolume["+name+" 30.00]" {
+				t.Errorf("[Err] Expected volume of sink %q set, got %v", name, calls)
+			}
+		})
+	}
+}
+
+func TestRESTGet(t *testing.T) {
+	_, srv := startTestREST(t, auth.RoleViewer)
+
+	res := doREST(t, srv, http.MethodGet, "/api/v1/build-info", "")
+	var info map[string]string
+	if err := stdjson.NewDecoder(res.Body).Decode(&info); err != nil || res.StatusCode != http.StatusOK || info["gitVersion"] == "" {
+		t.Errorf("[Err] Expected build info, got %d %v %v", res.StatusCode, info, err)
+	}
+
+	res = doREST(t, srv, http.MethodGet, "/api/v1/status", "")
+	var status pactl.Status
+	if err := stdjson.NewDecoder(res.Body).Decode(&status); err != nil || res.StatusCode != http.StatusOK || len(status.Sinks) != 2 {
+		t.Errorf("[Err] Expected status, got %d %+v %v", res.StatusCode, status, err)
+	}
+}
+
+func TestRESTErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       auth.Role
+		method     string
+		path       string
+		body       string
+		wantHTTP   int
+		wantStatus int16
+	}{
+		{"NegativeVolume", auth.RoleAdmin, http.MethodPut, "/api/v1/sinks/alsa_output.speakers/volume", `{"volume": -1}`, http.StatusBadRequest, json.StatusPayloadError},
+		{"MissingField", auth.RoleAdmin, http.MethodPost, "/api/v1/sinks/alsa_output.speakers/mute", ``, http.StatusBadRequest, json.StatusPayloadError},
+		{"InvalidJSON", auth.RoleAdmin, http.MethodPut, "/api/v1/sinks/alsa_output.speakers/volume", `{"volume":`, http.StatusBadRequest, json.StatusErrorInvalidJSON},
+		{"NotObject", auth.RoleAdmin, http.MethodPut, "/api/v1/sinks/alsa_output.speakers/volume", `[30]`, http.StatusBadRequest, json.StatusErrorInvalidJSON},
+		{"NotNumericID", auth.RoleAdmin, http.MethodPut, "/api/v1/sink-inputs/firefox/volume", `{"volume": 30}`, http.StatusBadRequest, json.StatusPayloadError},
+		{"BalanceOutOfRange", auth.RoleAdmin, http.MethodPut, "/api/v1/sinks/alsa_output.speakers/balance", `{"balance": 2}`, http.StatusBadRequest, json.StatusPayloadError},
+		{"NoSuchSink", auth.RoleAdmin, http.MethodPut, "/api/v1/sinks/nope/volume", `{"volume": 30}`, http.StatusNotFound, json.StatusActionError},
+		{"ViewerSetsVolume", auth.RoleViewer, http.MethodPut, "/api/v1/sinks/alsa_output.speakers/volume", `{"volume": 30}`, http.StatusForbidden, json.StatusUnauthorized},
+		{"OperatorSetsDefault", auth.RoleOperator, http.MethodPut, "/api/v1/defaults/sink", `{"name": "bluez_output.headset"}`, http.StatusForbidden, json.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audio, srv := startTestREST(t, tt.role)
+
+			res := doREST(t, srv, tt.method, tt.path, tt.body)
+			if res.StatusCode != tt.wantHTTP {
+				t.Errorf("[Err] Expected HTTP %d, got %d", tt.wantHTTP, res.StatusCode)
+			}
+
+			var body statusResponse
+			if err := stdjson.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatalf("[Err] Response not valid JSON: %v", err)
+			}
+			if body.Status != tt.wantStatus || body.Error == "" {
+				t.Errorf("[Err] Expected status %d with error, got %+v", tt.wantStatus, body)
+			}
+
+			for _, call := range audio.Calls() {
+				if tt.wantHTTP != http.StatusNotFound {
+					t.Errorf("[Err] Rejected request reached backend %v", call)
+				}
+			}
+		})
+	}
+
+	_, srv := startTestREST(t, auth.RoleNone)
+	if res := doREST(t, srv, http.MethodGet, "/api/v1/status", ""); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("[Err] Expected HTTP 401 without role, got %d", res.StatusCode)
+	}
+
+	_, srv = startTestREST(t, auth.RoleAdmin)
+	if res := doREST(t, srv, http.MethodGet, "/api/v1/sinks/alsa_output.speakers/volume", ""); res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("[Err] Expected HTTP 405 for wrong method, got %d", res.StatusCode)
+	}
+}
+
+func TestRESTRejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		origin      string
+		contentType string
+		wantHTTP    int
+	}{
+		{"TextPlain", "192.168.1.20:40000", "", "text/plain", http.StatusUnsupportedMediaType},
+		{"Form", "192.168.1.20:40000", "", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"NoContentType", "192.168.1.20:40000", "", "", http.StatusUnsupportedMediaType},
+		{"ForeignOrigin", "192.168.1.20:40000", "https://evil.example", "application/json", http.StatusForbidden},
+		{"OutsideLocalNetworks", "203.0.113.7:40000", "", "application/json", http.StatusForbidden},
+		{"JSONWithCharset", "192.168.1.20:40000", "", "application/json; charset=utf-8", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audio := fake.New()
+			t.Cleanup(audio.Close)
+			s := NewServer(audio)
+			s.Authorize = func(r *http.Request) (auth.Role, bool) { return auth.RoleAdmin, true }
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/sinks/alsa_output.speakers/mute", strings.NewReader(`{"muted": true}`))
+			req.RemoteAddr = tt.remoteAddr
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rec := httptest.NewRecorder()
+			s.RESTHandler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantHTTP {
+				t.Errorf("[Err] Expected HTTP %d, got %d", tt.wantHTTP, rec.Code)
+			}
+			if calls := audio.Calls(); tt.wantHTTP != http.StatusOK && len(calls) != 0 {
+				t.Errorf("[Err] Rejected request reached backend %v", calls)
+			}
+		})
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	_, srv := startTestREST(t, auth.RoleViewer)
+
+	res := doREST(t, srv, http.MethodGet, "/api/v1/openapi.json", "")
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if err := stdjson.NewDecoder(res.Body).Decode(&doc); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("[Err] Expected OpenAPI document, got %d %v", res.StatusCode, err)
+	}
+
+	operations := map[string]bool{}
+	for _, methods := range doc.Paths {
+		for _, op := range methods {
+			operations[op["operationId"].(string)] = true
+		}
+	}
+	for _, action := range json.AvailableCommands {
+		if _, wsOnly := wsOnlyActions[action]; wsOnly {
+			if operations[string(action)] {
+				t.Errorf("[Err] WebSocket only action %s has REST endpoint", action)
+			}
+			continue
+		}
+		if !operations[string(action)] {
+			t.Errorf("[Err] Action %s has no REST endpoint in OpenAPI document", action)
+		}
+	}
+
+	volume := doc.Paths["/api/v1/sinks/{name}/volume"]["put"]
+	if volume["requestBody"] == nil || volume["parameters"] == nil {
+		t.Errorf("[Err] Expected body and path param of SetSinkVolume, got %v", volume)
+	}
+}
+
+func TestAsyncAPI(t *testing.T) {
+	_, srv := startTestREST(t, auth.RoleViewer)
+
+	res := doREST(t, srv, http.MethodGet, "/api/v1/asyncapi.json", "")
+	var doc struct {
+		AsyncAPI   string `json:"asyncapi"`
+		Components struct {
+			Messages map[string]struct {
+				Payload struct {
+					Properties map[string]struct {
+						Enum       []string       `json:"enum"`
+						Properties map[string]any `json:"properties"`
+					} `json:"properties"`
+				} `json:"payload"`
+			} `json:"messages"`
+		} `json:"components"`
+	}
+	if err := stdjson.NewDecoder(res.Body).Decode(&doc); err != nil || res.StatusCode != http.StatusOK || doc.AsyncAPI == "" {
+		t.Fatalf("[Err] Expected AsyncAPI document, got %d %v", res.StatusCode, err)
+	}
+
+	for _, action := range json.AvailableCommands {
+		message, ok := doc.Components.Messages[string(action)]
+		if !ok {
+			t.Errorf("[Err] Action %s has no message in AsyncAPI document", action)
+			continue
+		}
+		if enum := message.Payload.Properties["action"].Enum; len(enum) != 1 || enum[0] != string(action) {
+			t.Errorf("[Err] Expected action %s in message, got %v", action, enum)
+		}
+	}
+
+	volume := doc.Components.Messages[string(json.ActionSetSinkVolume)].Payload.Properties["payload"].Properties
+	if volume["name"] == nil || volume["volume"] == nil {
+		t.Errorf("[Err] Expected typed payload of SetSinkVolume, got %v", volume)
+	}
+}
@@ -0,0 +1,99 @@
+package ws
+
+import (
+	"strings"
+
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+// subscribe adds topics of client, or removes them with on false. Client without subscription gets every topic,
+// so unsubscribing from one of them leaves all the others. Unsubscribing from one device of subscribed list,
+// fe. sinks:alsa_output.speakers of sinks, keeps it as false, the rest of the list including new devices still comes.
+func (s *Server) subscribe(c *client, topics []string, on bool) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if c.topics == nil {
+		c.topics = map[string]bool{}
+		if !on {
+			for _, t := range json.TopicLists {
+				c.topics[t] = true
+			}
+		}
+	}
+
+	for _, t := range topics {
+		list, _, named := strings.Cut(t, ":")
+		switch {
+		case !named:
+			// Whole list replaces its devices, subscribed or excluded
+			for topic := range c.topics {
+				if strings.HasPrefix(topic, list+":") {
+					delete(c.topics, topic)
+				}
+			}
+			if on {
+				c.topics[t] = true
+			} else {
+				delete(c.topics, t)
+			}
+		case on || c.topics[list]:
+			c.topics[t] = on
+		default:
+			delete(c.topics, t)
+		}
+	}
+}
+
+// clientSeq is version of last status event sent to client. Read it before status, so status is at least as new as seq,
+// and later deltas, being idempotent, apply on top of it.
+func (s *Server) clientSeq(c *client) uint64 {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	return c.seq
+}
+
+// clientStatus is part of status client is subscribed to
+func (s *Server) clientStatus(c *client, status pactl.Status) pactl.Status {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	return filterStatus(status, c.topics)
+}
+
+// filterStatus keeps lists and devices of topics, nil topics keep everything
+func filterStatus(status pactl.Status, topics map[string]bool) pactl.Status {
+	if topics == nil {
+		return status
+	}
+
+	return pactl.Status{
+		Sinks:         filterTopic(status.Sinks, topics, "sinks", func(s pactl.Sink) string { return s.Name }),
+		SinkInputs:    filterTopic(status.SinkInputs, topics, "sinkInputs", nil),
+		Sources:       filterTopic(status.Sources, topics, "sources", func(s pactl.Source) string { return s.Name }),
+		SourceOutputs: filterTopic(status.SourceOutputs, topics, "sourceOutputs", nil),
+		Cards:         filterTopic(status.Cards, topics, "cards", func(c pactl.Card) string { return c.Name }),
+		BuildInfo:     status.BuildInfo,
+	}
+}
+
+// filterTopic keeps whole list of topic without unsubscribed devices, or devices subscribed by name,
+// fe. sinks:alsa_output.speakers
+func filterTopic[T any](list []T, topics map[string]bool, topic string, name func(T) string) []T {
+	whole := topics[topic]
+	if whole && name == nil {
+		return list
+	}
+
+	kept := make([]T, 0)
+	if name == nil {
+		return kept
+	}
+	for _, e := range list {
+		subscribed, ok := topics[topic+":"+name(e)]
+		if subscribed || (whole && !ok) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,119 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/undg/pulse-remote/api/json"
+	"github.com/undg/pulse-remote/api/pactl"
+)
+
+func TestFilterStatus(t *testing.T) {
+	status := pactl.Status{
+		Sinks:      []pactl.Sink{{ID: 55, Name: "alsa_output.speakers"}, {ID: 56, Name: "bluez_output.headset"}},
+		SinkInputs: []pactl.SinkInput{{ID: 91}},
+		Sources:    []pactl.Source{{ID: 58, Name: "alsa_input.mic"}},
+	}
+
+	if got := filterStatus(status, nil); len(got.Sinks) != 2 || len(got.SinkInputs) != 1 || len(got.Sources) != 1 {
+		t.Errorf("[Err] Expected everything without subscription, got %+v", got)
+	}
+
+	got := filterStatus(status, map[string]bool{"sinks:bluez_output.headset": true, "sinkInputs": true})
+	if len(got.Sinks) != 1 || got.Sinks[0].ID != 56 || len(got.SinkInputs) != 1 || len(got.Sources) != 0 {
+		t.Errorf("[Err] Expected headset and apps only, got %+v", got)
+	}
+
+	got = filterStatus(status, map[string]bool{"sinks": true, "sinks:alsa_output.speakers": false})
+	if len(got.Sinks) != 1 || got.Sinks[0].ID != 56 || len(got.SinkInputs) != 0 {
+		t.Errorf("[Err] Expected sinks without speakers, got %+v", got)
+	}
+}
+
+func TestUnsubscribeDevice(t *testing.T) {
+	_, _, conn := startTestServer(t)
+	readStatus(t, conn)
+
+	// Without subscription client gets every topic, speakers are left out of them
+	res := send(t, conn, json.ActionUnsubscribe, map[string]any{"topics": []string{"sinks:alsa_output.speakers"}})
+	if res.Status != json.StatusSuccess || len(res.Payload.Sinks) != 1 || res.Payload.Sinks[0].Name != "bluez_output.headset" || len(res.Payload.SinkInputs) != 1 {
+		t.Fatalf("[Err] Expected every topic without speakers, got %+v", res)
+	}
+
+	res = send(t, conn, json.ActionSubscribe, map[string]any{"topics": []string{"sinks:alsa_output.speakers"}})
+	if len(res.Payload.Sinks) != 2 {
+		t.Errorf("[Err] Expected speakers back after subscribe, got %+v", res.Payload.Sinks)
+	}
+
+	send(t, conn, json.ActionUnsubscribe, map[string]any{"topics": []string{"sinks:alsa_output.speakers"}})
+	res = send(t, conn, json.ActionSubscribe, map[string]any{"topics": []string{"sinks"}})
+	if len(res.Payload.Sinks) != 2 {
+		t.Errorf("[Err] Expected whole list after subscribe to sinks, got %+v", res.Payload.Sinks)
+	}
+
+	res = send(t, conn, json.ActionUnsubscribe, map[string]any{"topics": []string{"sinks", "sources:alsa_input.mic"}})
+	if len(res.Payload.Sinks) != 0 || len(res.Payload.Sources) != 1 || len(res.Payload.Cards) != 1 {
+		t.Errorf("[Err] Expected no sinks and sources without mic, got %+v", res.Payload)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	audio, s, conn := startTestServer(t)
+	readStatus(t, conn)
+
+	res := send(t, conn, json.ActionSubscribe, map[string]any{"topics": []string{"sinks:bluez_output.headset"}})
+	if res.Status != json.StatusSuccess || len(res.Payload.Sinks) != 1 || len(res.Payload.SinkInputs) != 0 {
+		t.Fatalf("[Err] Expected reply with headset only, got %+v", res)
+	}
+
+	for _, topics := range [][]string{{"levels"}, {"sinkInputs:firefox"}, {}} {
+		if res := send(t, conn, json.ActionSubscribe, map[string]any{"topics": topics}); res.Status != json.StatusPayloadError {
+			t.Errorf("[Err] Expected payload error for topics %v, got %+v", topics, res)
+		}
+	}
+	if res := send(t, conn, json.ActionSubscribe, map[string]any{"topics": []string{json.TopicLevels}}); !strings.Contains(res.Error, "no level meters") {
+		t.Errorf("[Err] Expected levels topic explained as not available, got %q", res.Error)
+	}
+
+	s.PollInterval = 10 * time.Millisecond
+	go s.BroadcastUpdates(t.Context())
+
+	events := make(chan statusResponse, 10)
+	go func() {
+		for {
+			var res statusResponse
+			if err := conn.ReadJSON(&res); err != nil {
+				return
+			}
+			events <- res
+		}
+	}()
+
+	// Client got full status on connect, first broadcast sends its subscribed part
+	first := <-events
+
+	audio.Update(pactl.Event{Type: "change", Facility: "sink-input", Index: 91}, func(status *pactl.Status) { status.SinkInputs[0].Muted = true })
+	select {
+	case res := <-events:
+		t.Fatalf("[Err] Expected no event for app, got %+v", res)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	audio.Update(pactl.Event{Type: "change", Facility: "sink", Index: 56}, func(status *pactl.Status) { status.Sinks[1].Muted = true })
+	select {
+	case res := <-events:
+		if res.Seq != first.Seq+1 || len(res.Payload.Sinks) != 1 || !res.Payload.Sinks[0].Muted {
+			t.Errorf("[Err] Expected muted headset with seq %d, got %+v", first.Seq+1, res)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("[Err] No event after headset change")
+	}
+
+	if err := conn.WriteJSON(json.Message{Action: json.ActionUnsubscribe, Payload: map[string]any{"topics": []string{"sinks:bluez_output.headset"}}}); err != nil {
+		t.Fatalf("[Err] WriteJSON: %v", err)
+	}
+	if res = <-events; res.Status != json.StatusSuccess || len(res.Payload.Sinks) != 0 {
+		t.Errorf("[Err] Expected no sinks after unsubscribe, got %+v", res)
+	}
+}
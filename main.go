@@ -1,20 +1,38 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"embed"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/undg/pulse-remote/api/auth"
+	"github.com/undg/pulse-remote/api/backend"
 	"github.com/undg/pulse-remote/api/buildinfo"
+	"github.com/undg/pulse-remote/api/config"
 	prJSON "github.com/undg/pulse-remote/api/json"
 	"github.com/undg/pulse-remote/api/logger"
+	"github.com/undg/pulse-remote/api/pactl"
+	"github.com/undg/pulse-remote/api/qr"
+	"github.com/undg/pulse-remote/api/tlscert"
 	"github.com/undg/pulse-remote/api/utils"
 	"github.com/undg/pulse-remote/api/ws"
 )
 
-// @TODO (undg) 2024-10-06: different port in config, env var or cli flag
-
 const webDist = "_GUI/web/dist"
 
 //go:embed _GUI/web/dist/*
@@ -23,8 +41,15 @@ const webDist = "_GUI/web/dist"
 //go:embed _GUI/web/dist/icons/*
 var prWebDist embed.FS
 
-func startServer(mux *http.ServeMux) {
-	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+func startServer(mux *http.ServeMux, wsServer *ws.Server, a *auth.Auth) {
+	a.Register(mux)
+
+	// WebSocket authorizes itself, unauthorized clients get close code instead of HTTP status
+	wsServer.Authorize = a.Role
+	mux.HandleFunc("/api/v1/ws", wsServer.HandleWebSocket)
+
+	rest := wsServer.RESTHandler()
+	mux.Handle("/api/", a.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case "/api/v1/schema/status":
 			prJSON.ServeStatusSchemaJSON(w, r)
@@ -32,14 +57,10 @@ func startServer(mux *http.ServeMux) {
 			prJSON.ServeMessageSchemaJSON(w, r)
 		case "/api/v1/schema/response":
 			prJSON.ServeResponseSchemaJSON(w, r)
-		case "/api/v1/status":
-			prJSON.ServeStatusRestJSON(w, r)
-		case "/api/v1/ws":
-			ws.HandleWebSocket(w, r)
 		default:
-			http.NotFound(w, r)
+			rest.ServeHTTP(w, r)
 		}
-	})
+	})))
 
 	// Static files
 	fsys := http.FileServer(http.FS(prWebDist))
@@ -47,6 +68,12 @@ func startServer(mux *http.ServeMux) {
 		path := webDist + r.URL.Path
 		_, err := prWebDist.Open(path)
 		if err != nil {
+			// Web app can't log in by itself yet, assets stay public for login page
+			if !a.Authenticate(r) {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+
 			// File not exist, serve index.html
 			w.Header().Set("Content-Type", "text/html")
 			indexFile, _ := prWebDist.Open(webDist + "/index.html")
@@ -60,11 +87,145 @@ func startServer(mux *http.ServeMux) {
 
 }
 
+// allowNetworks refuses clients outside of allowed networks. Loopback is always allowed, empty list allows everyone.
+func allowNetworks(next http.Handler, networks []*net.IPNet) http.Handler {
+	if len(networks) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		ip := net.ParseIP(host)
+
+		if ip != nil && (ip.IsLoopback() || slices.ContainsFunc(networks, func(n *net.IPNet) bool { return n.Contains(ip) })) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger.Warn().Str("client_ip", r.RemoteAddr).Msg("Client outside of allowed_networks")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
+// notifyDesktop shows pairing request on the desktop, when notify-send is available
+func notifyDesktop(url string) func(req auth.PairRequest) {
+	return func(req auth.PairRequest) {
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return
+		}
+
+		body := fmt.Sprintf("%s (%s) wants to pair, code %s.\nConfirm on %s", req.Name, req.ClientIP, req.Code, url)
+		if err := exec.Command("notify-send", "--app-name=pulse-remote", "Pairing request", body).Run(); err != nil {
+			logger.Error().Err(err).Msg("notify-send FAIL")
+		}
+	}
+}
+
+const caPath = "/api/v1/tls/ca.pem"
+
+// serveCA lets phones install local CA, so they trust generated server certificate
+func serveCA(caFile string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+		w.Header().Set("Content-Disposition", `attachment; filename="pulse-remote-ca.crt"`)
+		http.ServeFile(w, r, caFile)
+	}
+}
+
+// redirectHTTPS sends plain HTTP clients to HTTPS port. CA is served without redirect,
+// phone needs it before HTTPS is trusted. Nil ca for user's own certificate.
+func redirectHTTPS(port int, ca http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ca != nil && r.URL.Path == caPath {
+			ca(w, r)
+			return
+		}
+
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		http.Redirect(w, r, "https://"+net.JoinHostPort(host, strconv.Itoa(port))+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	})
+}
+
 func main() {
-	ip, err := utils.GetLocalIP()
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return
+	case errors.Is(err, config.ErrUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if opts.PrintConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Already validated
+	logger.SetLevel(cfg.LogLevel)
+	pactl.MaxVolume = cfg.MaxVolume
+	networks, _ := cfg.Networks()
+	origins, _ := cfg.Origins()
+
+	addr, err := cfg.ListenAddr()
 	if err != nil {
-		logger.Error().Err(err).Msg("can't GetLocalIP()")
+		logger.Fatal().Err(err).Msg("can't ListenAddr()")
+	}
+
+	ip, _, _ := net.SplitHostPort(addr)
+	if ip == "" || net.ParseIP(ip).IsUnspecified() {
+		ip, err = utils.GetLocalIP()
+		if err != nil {
+			logger.Error().Err(err).Msg("can't GetLocalIP()")
+		}
 	}
+	hostPort := net.JoinHostPort(ip, strconv.Itoa(cfg.Port))
+
+	configFile := opts.ConfigFile
+	if configFile == "" {
+		configFile = "none, defaults"
+	}
+
+	wsScheme, httpScheme := "ws", "http"
+	if cfg.TLS.Enabled {
+		wsScheme, httpScheme = "wss", "https"
+	}
+
+	certFile, keyFile, caFile := cfg.TLS.CertFile, cfg.TLS.KeyFile, ""
+	fingerprint := "off"
+	if cfg.TLS.Enabled {
+		if certFile == "" {
+			ips, err := utils.GetLocalIPs()
+			if err != nil {
+				logger.Error().Err(err).Msg("can't GetLocalIPs()")
+			}
+			hosts := tlscert.Hosts(ips)
+			if listenHost, _, _ := net.SplitHostPort(addr); listenHost != "" {
+				hosts = append(hosts, listenHost)
+			}
+
+			files, err := tlscert.Ensure(filepath.Join(cfg.DataDir, "tls"), hosts)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("can't tlscert.Ensure()")
+			}
+			certFile, keyFile, caFile = files.Cert, files.Key, files.CA
+		}
+
+		sha, err := tlscert.Fingerprint(certFile)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("can't tlscert.Fingerprint()")
+		}
+		fingerprint = "SHA-256 " + sha
+	}
+
 	b := buildinfo.Get()
 
 	fmt.Print(`
@@ -79,10 +240,47 @@ func main() {
   GoVersion:  `, b.GoVersion, `
   LogLevel:   `, logger.GetLevel(), `
   DEBUG:      `, logger.DebugEnv, `
+  Config:     `, configFile, `
+  TLS:        `, fingerprint, `
 └───────────────────────────────────────────────────┘
 `)
-	fmt.Println("\n🔥 Igniting server on ws://" + ip + utils.PORT)
-	fmt.Println("🔥 WebApp http://" + ip + utils.PORT + "\n")
+	pairURL := httpScheme + "://" + net.JoinHostPort("localhost", strconv.Itoa(cfg.Port)) + "/pair"
+
+	a, err := auth.New(cfg.Auth, cfg.DataDir)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("can't auth.New()")
+	}
+	a.Notify = notifyDesktop(pairURL)
+	a.WebURL = httpScheme + "://" + hostPort
+
+	fmt.Println("\n🔥 Igniting server on " + wsScheme + "://" + hostPort)
+	fmt.Println("🔥 WebApp " + httpScheme + "://" + hostPort)
+	if cfg.Auth.Mode != config.AuthNone {
+		fmt.Println("🔒 Auth " + cfg.Auth.Mode + ", confirm pairing on " + pairURL)
+	}
+	if caFile != "" {
+		caURL := httpScheme + "://" + hostPort + caPath
+		if cfg.TLS.RedirectPort != 0 {
+			caURL = "http://" + net.JoinHostPort(ip, strconv.Itoa(cfg.TLS.RedirectPort)) + caPath
+		}
+		fmt.Println("🔐 Install local CA on phones once " + caURL)
+	}
+	fmt.Println()
+
+	// Phone camera is faster than typing IP, without local IP there is nothing to scan
+	if ip != "" {
+		if code, err := qr.Terminal(a.PairURL()); err != nil {
+			logger.Error().Err(err).Msg("can't qr.Terminal()")
+		} else {
+			fmt.Print(code)
+			if a.Enabled() {
+				fmt.Println("📱 Scan to pair, code expires in 15 minutes. New one on " + pairURL)
+			} else {
+				fmt.Println("📱 Scan to open WebApp")
+			}
+			fmt.Println()
+		}
+	}
 
 	fmt.Print(`──────────────────────────────────────────────────────────────
 `)
@@ -96,14 +294,96 @@ func main() {
 
 `)
 
+	audio := backend.Pactl{}
+	wsServer := ws.NewServer(audio)
+	wsServer.PollInterval = cfg.PollInterval.Duration
+	wsServer.PingInterval = cfg.PingInterval.Duration
+	wsServer.PongTimeout = cfg.PongTimeout.Duration
+	wsServer.WriteTimeout = cfg.WriteTimeout.Duration
+	wsServer.AllowedOrigins = origins
+
 	mux := http.NewServeMux()
 
-	startServer(mux)
+	startServer(mux, wsServer, a)
+
+	var ca http.HandlerFunc
+	if caFile != "" {
+		ca = serveCA(caFile)
+		mux.HandleFunc("GET "+caPath, ca)
+	}
+
+	// Broadcaster and subscribe process stop with ctx
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	broadcasting := make(chan struct{})
+	go func() {
+		defer close(broadcasting)
+		wsServer.BroadcastUpdates(ctx)
+	}()
 
-	go ws.BroadcastUpdates()
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   allowNetworks(mux, networks),
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	servers := []*http.Server{server}
+
+	go func() {
+		var err error
+		if cfg.TLS.Enabled {
+			err = server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal().Err(err).Msg("server failed to start")
+		}
+	}()
+
+	if cfg.TLS.Enabled && cfg.TLS.RedirectPort != 0 {
+		listenHost, _, _ := net.SplitHostPort(addr)
+		redirect := &http.Server{
+			Addr:    net.JoinHostPort(listenHost, strconv.Itoa(cfg.TLS.RedirectPort)),
+			Handler: allowNetworks(redirectHTTPS(cfg.Port, ca), networks),
+		}
+		servers = append(servers, redirect)
 
-	errListenAndServe := http.ListenAndServe(utils.PORT, mux)
-	if errListenAndServe != nil {
-		logger.Fatal().Err(errListenAndServe).Msg("server failed to start")
+		go func() {
+			if err := redirect.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal().Err(err).Msg("redirect server failed to start")
+			}
+		}()
 	}
+
+	<-ctx.Done()
+	// Second Ctrl+C kills right away
+	stop()
+	shutdown(servers, wsServer, broadcasting, cfg.ShutdownTimeout.Duration)
+}
+
+// shutdown stops accepting connections, closes WebSocket clients with going away and waits for requests and actions
+// in flight, so no pactl command is killed halfway. broadcasting is closed when pactl subscribe is gone.
+// Gives up after timeout.
+func shutdown(servers []*http.Server, wsServer *ws.Server, broadcasting <-chan struct{}, timeout time.Duration) {
+	logger.Info().Dur("timeout", timeout).Msg("Shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error().Err(err).Str("addr", server.Addr).Msg("can't server.Shutdown()")
+		}
+	}
+	if err := wsServer.Shutdown(ctx); err != nil {
+		logger.Error().Err(err).Msg("can't wsServer.Shutdown()")
+	}
+	select {
+	case <-broadcasting:
+	case <-ctx.Done():
+		logger.Error().Err(ctx.Err()).Msg("subscribe didn't stop")
+	}
+
+	logger.Info().Msg("Server stopped")
 }